@@ -0,0 +1,420 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/brewfile"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/services"
+)
+
+// coreTap is the tap of formulae that are named without their tap.
+const coreTap = "homebrew/core"
+
+// BundleInstall represents the action and its options.
+type BundleInstall struct {
+	Install
+
+	File string // path to the Brewfile
+}
+
+// Run runs the action.
+func (action *BundleInstall) Run(ctx context.Context) error {
+	bf, err := brewfile.Load(action.File)
+	if err != nil {
+		return err
+	}
+	bf = bf.ForPlatform(platform.SystemPlatform())
+
+	// Install the locked bottles if the Brewfile has been locked
+	if action.Lock == "" {
+		if _, err := os.Stat(brewfile.LockPath(action.File)); err == nil {
+			action.Lock = brewfile.LockPath(action.File)
+		}
+	}
+
+	// Resolve the Brewfile to the locked tags
+	if action.Lock != "" {
+		if _, err := action.loadLock(action.Lock); err != nil {
+			return err
+		}
+	}
+
+	formulae, err := action.fetchFromArgs(ctx, bf.Formula, platform.SystemPlatform())
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*brewfile.Brew, len(bf.Brew))
+	for _, brew := range bf.Brew {
+		name := entryName(brew)
+		entries[name] = brew
+		warnUnsupportedOptions(name, brew)
+	}
+
+	missing, installed, err := prefix.FilterInstalled(action.Prefix(), formulae)
+	if err != nil {
+		return err
+	}
+
+	// Keep the tags requested in the Brewfile
+	args := make([]string, 0, len(missing))
+	for _, f := range missing {
+		arg := f.Name()
+		if tag := action.alternateTags[f.Name()]; tag != "" {
+			arg += ":" + tag
+		}
+		args = append(args, arg)
+	}
+
+	for _, f := range installed {
+		fmt.Println("Using " + f.Name())
+		err = action.changeLink(ctx, f, entries[f.Name()])
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		for _, f := range missing {
+			err = action.applyOptions(ctx, f, entries[f.Name()])
+			if err != nil {
+				return err
+			}
+		}
+
+		err = action.Install.Run(ctx, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !action.DryRun {
+		err = action.startServices(ctx, bf.Brew, formula.Names(missing))
+		if err != nil {
+			return err
+		}
+
+		dword := "dependencies"
+		if len(formulae) == 1 {
+			dword = "dependency"
+		}
+		o.Hai(fmt.Sprintf("`hops bundle` complete! %d Brewfile %s now installed.", len(formulae), dword))
+	}
+	return nil
+}
+
+// entryName produces the name of the formula of a Brewfile entry, without its tap or tag.
+func entryName(brew *brewfile.Brew) string {
+	name, _ := parseArg(brew.Name)
+	return path.Base(name)
+}
+
+// applyOptions applies the options of a Brewfile entry to the install of its formula.
+func (action *BundleInstall) applyOptions(ctx context.Context, f formula.PlatformFormula, brew *brewfile.Brew) error {
+	if brew == nil {
+		return nil
+	}
+
+	// Unlink conflicting formulae so this formula can be linked
+	for _, name := range brew.ConflictsWith {
+		linked, err := action.Prefix().LinkedKeg(name)
+		if err != nil {
+			return err
+		}
+		if linked == "" {
+			continue
+		}
+		err = (&Unlink{Hops: action.Hops, DryRun: action.DryRun}).Run(ctx, name)
+		if err != nil {
+			return fmt.Errorf("unlinking %s, which conflicts with %s: %w", name, f.Name(), err)
+		}
+	}
+
+	switch {
+	// Link the formula as usual
+	case brew.Link == nil:
+	// Link the formula even if it is keg-only
+	case *brew.Link:
+		if action.linked == nil {
+			action.linked = map[string]bool{}
+		}
+		action.linked[f.Name()] = true
+	// Install the formula without linking it
+	default:
+		if action.unlinked == nil {
+			action.unlinked = map[string]bool{}
+		}
+		action.unlinked[f.Name()] = true
+	}
+
+	if brew.Overwrite {
+		if action.overwrite == nil {
+			action.overwrite = map[string]bool{}
+		}
+		action.overwrite[f.Name()] = true
+	}
+	return nil
+}
+
+// changeLink links or unlinks an installed formula as its Brewfile entry requests.
+func (action *BundleInstall) changeLink(ctx context.Context, f formula.PlatformFormula, brew *brewfile.Brew) error {
+	if brew == nil || brew.Link == nil {
+		return nil
+	}
+
+	linked, err := action.Prefix().LinkedKeg(f.Name())
+	if err != nil {
+		return err
+	}
+
+	switch {
+	// Link the formula, even if it is keg-only
+	case *brew.Link && linked == "":
+		return (&Link{
+			Hops:      action.Hops,
+			Overwrite: action.Overwrite || brew.Overwrite,
+			DryRun:    action.DryRun,
+			Force:     true,
+		}).Run(ctx, []string{f.Name()})
+	// Unlink the formula
+	case !*brew.Link && linked != "":
+		return (&Unlink{Hops: action.Hops, DryRun: action.DryRun}).Run(ctx, f.Name())
+	default:
+		return nil
+	}
+}
+
+// startServices starts and restarts the services of Brewfile entries as
+// their options request.
+func (action *BundleInstall) startServices(ctx context.Context, brews []*brewfile.Brew, changed []string) error {
+	manager := services.SystemManager()
+	for _, brew := range brews {
+		name := entryName(brew)
+
+		restart := brew.RestartService == brewfile.RestartAlways ||
+			brew.RestartService == brewfile.RestartChanged && slices.Contains(changed, name)
+		if !restart && !brew.StartService {
+			continue
+		}
+
+		svcs, err := action.services(ctx, []string{name})
+		if err != nil {
+			return err
+		}
+
+		for _, svc := range svcs {
+			status, err := manager.Status(ctx, svc)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case restart:
+				err = manager.Restart(ctx, svc)
+				if err == nil {
+					o.Hai(fmt.Sprintf("Restarted service `%s`", name))
+				}
+			case status == services.StatusNone || status == services.StatusStopped:
+				err = manager.Start(ctx, svc)
+				if err == nil {
+					o.Hai(fmt.Sprintf("Started service `%s`", name))
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// warnUnsupportedOptions warns about the options of a Brewfile entry that
+// cannot be honored.
+func warnUnsupportedOptions(name string, brew *brewfile.Brew) {
+	if len(brew.Args) > 0 {
+		slog.Warn("Ignoring Brewfile args, bottles are installed as they were built",
+			slog.String("formula", name), slog.Any("args", brew.Args))
+	}
+}
+
+// BundleCheck represents the action and its options.
+type BundleCheck struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+
+	File string // path to the Brewfile
+}
+
+// Run runs the action.
+func (action *BundleCheck) Run(ctx context.Context) error {
+	bf, err := brewfile.Load(action.File)
+	if err != nil {
+		return err
+	}
+	bf = bf.ForPlatform(platform.SystemPlatform())
+
+	graph, err := action.resolve(ctx, bf.Formula, platform.SystemPlatform(), &action.DependencyOptions)
+	if err != nil {
+		return err
+	}
+
+	missing, _, err := prefix.FilterInstalled(action.Prefix(), slices.Concat(graph.Roots(), graph.Dependencies()))
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		lines := make([]string, 0, len(missing))
+		for _, f := range missing {
+			lines = append(lines, "→ Formula "+f.Name()+" needs to be installed.")
+		}
+		return errors.New("hops bundle can't satisfy your Brewfile's dependencies.\n" + strings.Join(lines, "\n") +
+			"\nSatisfy missing dependencies with `hops bundle install`.")
+	}
+
+	fmt.Println("The Brewfile's dependencies are satisfied.")
+	return nil
+}
+
+// BundleDump represents the action and its options.
+type BundleDump struct {
+	*Hops
+
+	File  string // path to the Brewfile, "-" writes to standard output
+	Force bool   // overwrite an existing Brewfile
+}
+
+// Run runs the action.
+func (action *BundleDump) Run(ctx context.Context) error {
+	leaves, err := action.leaves(ctx)
+	if err != nil {
+		return err
+	}
+
+	taps := []string{}
+	brews := []string{}
+	for _, f := range leaves {
+		kegs, err := action.Prefix().InstalledKegs(f)
+		if err != nil {
+			return err
+		}
+		if len(kegs) == 0 {
+			continue
+		}
+
+		r, err := receipt.Load(kegs[len(kegs)-1].String())
+		if err != nil {
+			return err
+		}
+
+		name := f.Name()
+		switch {
+		// No receipt to consult, assume the leaf was requested
+		case r == nil:
+		// Leaves installed as dependencies are left to their dependents
+		case !r.InstalledOnRequest:
+			continue
+		// Formulae from other taps are named with their tap
+		case r.Source.Tap != "" && r.Source.Tap != coreTap:
+			if !slices.Contains(taps, r.Source.Tap) {
+				taps = append(taps, r.Source.Tap)
+			}
+			name = r.Source.Tap + "/" + name
+		}
+		brews = append(brews, name)
+	}
+	slices.Sort(taps)
+	slices.Sort(brews)
+
+	content := &strings.Builder{}
+	for _, t := range taps {
+		content.WriteString("tap " + strconv.Quote(t) + "\n")
+	}
+	for _, b := range brews {
+		content.WriteString("brew " + strconv.Quote(b) + "\n")
+	}
+
+	if action.File == "-" {
+		_, err = io.WriteString(os.Stdout, content.String())
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !action.Force {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(action.File, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", action.File)
+	} else if err != nil {
+		return fmt.Errorf("writing Brewfile: %w", err)
+	}
+
+	_, err = io.WriteString(file, content.String())
+	return errors.Join(err, file.Close())
+}
+
+// BundleCleanup represents the action and its options.
+type BundleCleanup struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+
+	File  string // path to the Brewfile
+	Force bool   // uninstall the formulae instead of listing them
+}
+
+// Run runs the action.
+func (action *BundleCleanup) Run(ctx context.Context) error {
+	bf, err := brewfile.Load(action.File)
+	if err != nil {
+		return err
+	}
+	bf = bf.ForPlatform(platform.SystemPlatform())
+
+	// Formulae in the Brewfile and their dependencies are kept
+	graph, err := action.resolve(ctx, bf.Formula, platform.SystemPlatform(), &action.DependencyOptions)
+	if err != nil {
+		return err
+	}
+	keep := formula.Names(slices.Concat(graph.Roots(), graph.Dependencies()))
+
+	kegs, err := action.Prefix().Kegs()
+	if err != nil {
+		return err
+	}
+	names := formula.Names(kegs)
+	slices.Sort(names)
+	names = slices.Compact(names)
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(keep, name)
+	})
+
+	switch {
+	// Nothing to clean up
+	case len(names) == 0:
+		return nil
+	// List the formulae that would be uninstalled
+	case !action.Force:
+		o.Hai("Would uninstall formulae:\n" + strings.Join(names, "\n"))
+		fmt.Println("Run `hops bundle cleanup --force` to make these changes.")
+		return nil
+	// Uninstall the formulae
+	default:
+		o.Hai("Uninstalling formulae:\n" + strings.Join(names, "\n"))
+		return action.uninstall(ctx, names)
+	}
+}
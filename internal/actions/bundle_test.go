@@ -0,0 +1,33 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/prefix"
+)
+
+func TestBundleInstall_tapEntry(t *testing.T) {
+	action := &BundleInstall{Install: Install{Hops: testHops(t)}}
+	p := action.Prefix()
+	makeKegs(t, p, "foo", "1.0")
+	if _, _, err := p.Link("foo", "1.0", &prefix.LinkOptions{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	info := &brewv1.Info{PlatformInfo: brewv1.PlatformInfo{Name: "foo", Versions: brewv1.Versions{Stable: "1.0"}}}
+	action.brewformulary = testAPIFormulary{"foo": info, "user/tap/foo": info}
+
+	// The options of an entry named with its tap apply to the formula
+	action.File = filepath.Join(t.TempDir(), "Brewfile")
+	if err := os.WriteFile(action.File, []byte("tap \"user/tap\"\nbrew \"user/tap/foo\", link: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := action.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNotExist(t, p.LinkedKegRecord("foo"), filepath.Join(p.String(), "bin", "foo"))
+}
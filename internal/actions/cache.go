@@ -0,0 +1,222 @@
+package actions
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	hopsv1 "github.com/act3-ai/hops/internal/apis/config.hops.io/v1beta1"
+	"github.com/act3-ai/hops/internal/cache"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/utils"
+)
+
+// CacheInfo represents the action and its options.
+type CacheInfo struct {
+	*Hops
+
+	JSON bool // Print output in JSON format
+}
+
+// cacheUsage is the size of a part of the cache.
+type cacheUsage struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+	Size    int64  `json:"size"`
+}
+
+// cacheInfo is the output of CacheInfo.
+type cacheInfo struct {
+	Dir       string       `json:"dir"`
+	Downloads string       `json:"downloads"`
+	Size      int64        `json:"size"`
+	Areas     []cacheUsage `json:"areas"`
+	Formulae  []cacheUsage `json:"formulae"`
+}
+
+// Run runs the action.
+func (action *CacheInfo) Run(ctx context.Context) error {
+	c := action.cache()
+	entries, err := c.Entries(ctx)
+	if err != nil {
+		return err
+	}
+
+	info := cacheInfo{
+		Dir:       c.Dir,
+		Downloads: c.DownloadsDir(),
+		Areas:     []cacheUsage{},
+		Formulae:  []cacheUsage{},
+	}
+	areas := map[string]*cacheUsage{}
+	formulae := map[string]*cacheUsage{}
+	for _, e := range entries {
+		info.Size += e.Size
+		addUsage(areas, string(e.Area), e.Size)
+		if e.Formula != "" {
+			addUsage(formulae, e.Formula, e.Size)
+		}
+	}
+	for _, area := range []cache.Area{cache.AreaDownloads, cache.AreaOCI, cache.AreaAPI} {
+		if u, ok := areas[string(area)]; ok {
+			info.Areas = append(info.Areas, *u)
+		}
+	}
+	for _, u := range formulae {
+		info.Formulae = append(info.Formulae, *u)
+	}
+	// Largest first
+	slices.SortFunc(info.Formulae, func(a, b cacheUsage) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Name, b.Name))
+	})
+
+	if action.JSON {
+		return printJSON(info)
+	}
+
+	fmt.Println("Cache:     " + o.StyleBold(info.Dir))
+	fmt.Println("Downloads: " + o.StyleBold(info.Downloads))
+	fmt.Println("Size:      " + o.StyleBold(utils.PrettyBytes(info.Size)))
+
+	if len(info.Areas) > 0 {
+		o.H1("Areas")
+		if err := printCacheUsage(info.Areas); err != nil {
+			return err
+		}
+	}
+	if len(info.Formulae) > 0 {
+		o.H1("Formulae")
+		if err := printCacheUsage(info.Formulae); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addUsage adds an entry to the usage of a part of the cache.
+func addUsage(usage map[string]*cacheUsage, name string, size int64) {
+	u, ok := usage[name]
+	if !ok {
+		u = &cacheUsage{Name: name}
+		usage[name] = u
+	}
+	u.Entries++
+	u.Size += size
+}
+
+// printCacheUsage prints the sizes of parts of the cache.
+func printCacheUsage(usage []cacheUsage) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, u := range usage {
+		word := "entries"
+		if u.Entries == 1 {
+			word = "entry"
+		}
+		fmt.Fprintln(w, u.Name+"\t"+utils.PrettyBytes(u.Size)+"\t"+strconv.Itoa(u.Entries)+" "+word)
+	}
+	return w.Flush()
+}
+
+// CachePrune represents the action and its options.
+type CachePrune struct {
+	*Hops
+
+	MaxAgeDays int    // prune entries unused for more days than this unless 0, defaults to the configured value if negative
+	MaxSize    string // prune least recently used entries until the cache fits, defaults to the configured value if empty
+	All        bool   // prune every entry
+	DryRun     bool   // show what would be pruned without removing anything
+}
+
+// Run runs the action.
+func (action *CachePrune) Run(ctx context.Context) error {
+	cfg := action.Config().Cleanup
+	if action.MaxAgeDays >= 0 {
+		cfg.MaxAgeDays = &action.MaxAgeDays
+	}
+	if action.MaxSize != "" {
+		cfg.MaxSize = action.MaxSize
+	}
+
+	opts, err := pruneOptions(&cfg)
+	if err != nil {
+		return err
+	}
+	opts.All = action.All
+	opts.DryRun = action.DryRun
+
+	freed, err := action.pruneCache(ctx, opts)
+	if err != nil {
+		return err
+	}
+	printFreed(freed, action.DryRun)
+	return nil
+}
+
+// pruneOptions produces the options to prune the cache with the configured thresholds.
+func pruneOptions(cfg *hopsv1.CleanupConfig) (*cache.PruneOptions, error) {
+	maxSize, err := cfg.ParseMaxSize()
+	if err != nil {
+		return nil, err
+	}
+	opts := &cache.PruneOptions{MaxSize: maxSize}
+	if cfg.MaxAgeDays != nil {
+		opts.MaxAge = time.Duration(*cfg.MaxAgeDays) * 24 * time.Hour
+	}
+	return opts, nil
+}
+
+// cache locates the caches.
+func (action *Hops) cache() *cache.Cache {
+	return &cache.Cache{
+		Dir:       action.Config().Cache,
+		Downloads: action.Config().Homebrew.Cache,
+	}
+}
+
+// pruneCache prunes the cache, printing what was removed.
+// The disk space freed is returned.
+func (action *Hops) pruneCache(ctx context.Context, opts *cache.PruneOptions) (int64, error) {
+	// Bottles being downloaded must not be pruned
+	release, err := action.lockCache(ctx, !opts.DryRun)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	result, err := action.cache().Prune(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	verb := "Removing"
+	if opts.DryRun {
+		verb = "Would remove"
+	}
+	for _, e := range result.Removed {
+		fmt.Printf("%s: %s (%s)\n", verb, e.Path, utils.PrettyBytes(e.Size))
+	}
+	if n := len(result.Garbage); n > 0 {
+		word := "blobs"
+		if n == 1 {
+			word = "blob"
+		}
+		fmt.Printf("%s: %d unreferenced %s from OCI layouts\n", verb, n, word)
+	}
+	return result.Freed, nil
+}
+
+// printFreed prints a summary of the disk space freed.
+func printFreed(freed int64, dryRun bool) {
+	switch {
+	case freed == 0:
+	case dryRun:
+		fmt.Printf("This operation would free approximately %s of disk space.\n", utils.PrettyBytes(freed))
+	default:
+		fmt.Printf("This operation has freed approximately %s of disk space.\n", utils.PrettyBytes(freed))
+	}
+}
@@ -2,33 +2,312 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils"
 )
 
 // Cleanup represents the action and its options.
 type Cleanup struct {
 	*Hops
+
+	Prune  string // prune cache entries unused for more than this many days, or "all" for every entry
+	DryRun bool   // show what would be removed without removing anything
+}
+
+// cleanupResult counts what was removed from the prefix.
+type cleanupResult struct {
+	links int   // broken symlinks and stale records
+	dirs  int   // empty racks
+	freed int64 // size of the removed kegs
 }
 
 // Run runs the action.
-func (action *Cleanup) Run(_ context.Context) error {
-	broken, err := action.Prefix().BrokenLinks()
+func (action *Cleanup) Run(ctx context.Context, names ...string) error {
+	all := len(names) == 0
+	if all {
+		var err error
+		names, err = action.cleanupNames()
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, name := range names {
+			if !action.hasRackOrRecords(name) {
+				return action.Prefix().NewErrNoSuchKeg(name)
+			}
+		}
+	}
+
+	release, err := action.lockPrefix(ctx, names...)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	result := &cleanupResult{}
+	removed := map[string]bool{}
+	for _, name := range names {
+		if err := action.cleanupKegs(name, result); err != nil {
+			return err
+		}
+		if err := action.cleanupRecords(name, result, removed); err != nil {
+			return err
+		}
+		if err := action.cleanupRack(name, result); err != nil {
+			return err
+		}
+	}
 
-	for _, bl := range broken {
-		fmt.Println("Removing " + bl)
-		err = os.Remove(bl)
+	// Broken links are only pruned from the whole prefix
+	if all {
+		broken, err := action.Prefix().BrokenLinks()
 		if err != nil {
 			return err
 		}
+		for _, bl := range broken {
+			if removed[bl] {
+				continue // stale record
+			}
+			if err := action.remove(bl); err != nil {
+				return err
+			}
+			result.links++
+		}
 	}
 
-	// TODO: remove outdated, unlinked kegs
+	verb := "Pruned"
+	if action.DryRun {
+		verb = "Would prune"
+	}
+	fmt.Printf("%s %d symbolic links and %d directories from %s\n", verb, result.links, result.dirs, action.Prefix())
 
-	fmt.Printf("Pruned %d symbolic links and %d directories from %s\n", len(broken), 0, action.Prefix())
+	cacheFreed, err := action.cleanupCache(ctx, names, all)
+	if err != nil {
+		return err
+	}
 
+	printFreed(result.freed+cacheFreed, action.DryRun)
 	return nil
 }
+
+// cleanupNames lists every formula with a rack or a record in the prefix,
+// including empty racks and records of kegs that are gone.
+func (action *Cleanup) cleanupNames() ([]string, error) {
+	p := action.Prefix()
+	names := []string{}
+	for _, dir := range []string{p.Cellar(), p.Opt(), p.LinkedKegRecords(), p.PinnedKegRecords()} {
+		entries, err := os.ReadDir(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("listing %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue // hidden files and dirs
+			}
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// hasRackOrRecords reports whether the named formula has a rack or any record in the prefix.
+func (action *Cleanup) hasRackOrRecords(name string) bool {
+	p := action.Prefix()
+	for _, path := range []string{filepath.Join(p.Cellar(), name), p.OptRecord(name), p.LinkedKegRecord(name), p.PinRecord(name)} {
+		if _, err := os.Lstat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupKegs removes the old kegs of the named formula.
+//
+// The newest keg is kept, as are the kegs that are linked, opt-linked, or pinned.
+func (action *Cleanup) cleanupKegs(name string, result *cleanupResult) error {
+	p := action.Prefix()
+
+	// Old names of migrated formulae link to the rack of the new name
+	if info, err := os.Lstat(filepath.Join(p.Cellar(), name)); err == nil && info.Mode().Type() == fs.ModeSymlink {
+		return nil
+	}
+
+	kegs, err := p.InstalledKegsByName(name)
+	if err != nil {
+		return err
+	}
+	if len(kegs) == 0 {
+		return nil
+	}
+
+	keep := []prefix.Keg{kegs[len(kegs)-1]} // newest
+	for _, record := range []func(string) (prefix.Keg, error){p.LinkedKeg, p.OptKeg, p.PinnedKeg} {
+		keg, err := record(name)
+		if err != nil {
+			return err
+		}
+		if keg != "" {
+			keep = append(keep, keg)
+		}
+	}
+
+	old := []string{}
+	for _, keg := range kegs {
+		if !slices.Contains(keep, keg) {
+			old = append(old, keg.String())
+		}
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	if !action.DryRun {
+		// Remove any symlinks into the old kegs
+		if _, err := p.Unlink(nil, old...); err != nil {
+			return err
+		}
+	}
+
+	for _, keg := range old {
+		files, size, err := utils.CountDir(keg)
+		if err != nil {
+			return err
+		}
+		if action.DryRun {
+			fmt.Printf("Would remove: %s (%d files, %s)\n", keg, files, utils.PrettyBytes(size))
+		} else {
+			fmt.Printf("Removing: %s... (%d files, %s)\n", keg, files, utils.PrettyBytes(size))
+			if err := os.RemoveAll(keg); err != nil {
+				return fmt.Errorf("removing %s: %w", keg, err)
+			}
+		}
+		result.freed += size
+	}
+	return nil
+}
+
+// cleanupRecords removes the opt, linked, and pinned records of the named
+// formula that point to kegs that are gone.
+func (action *Cleanup) cleanupRecords(name string, result *cleanupResult, removed map[string]bool) error {
+	p := action.Prefix()
+	for _, record := range []string{p.OptRecord(name), p.LinkedKegRecord(name), p.PinRecord(name)} {
+		info, err := os.Lstat(record)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return err
+		case info.Mode().Type() != fs.ModeSymlink:
+			continue
+		}
+
+		_, err = os.Stat(record)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			continue // keg exists
+		}
+
+		if err := action.remove(record); err != nil {
+			return err
+		}
+		removed[record] = true
+		result.links++
+	}
+	return nil
+}
+
+// cleanupRack removes the rack of the named formula if it is empty,
+// or the link from an old name to a rack that is gone.
+func (action *Cleanup) cleanupRack(name string, result *cleanupResult) error {
+	rack := filepath.Join(action.Prefix().Cellar(), name)
+	info, err := os.Lstat(rack)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case info.Mode().Type() == fs.ModeSymlink:
+		if _, err := os.Stat(rack); !errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err := action.remove(rack); err != nil {
+			return err
+		}
+		result.links++
+		return nil
+	case !info.IsDir():
+		return nil
+	}
+
+	kegs, err := os.ReadDir(rack)
+	switch {
+	case err != nil:
+		return fmt.Errorf("checking for kegs in %s: %w", rack, err)
+	case len(kegs) > 0:
+		return nil
+	}
+
+	if err := action.remove(rack); err != nil {
+		return err
+	}
+	result.dirs++
+	return nil
+}
+
+// remove removes a symlink or empty directory, unless this is a dry run.
+func (action *Cleanup) remove(path string) error {
+	if action.DryRun {
+		fmt.Println("Would remove: " + path)
+		return nil
+	}
+	fmt.Println("Removing: " + path)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+	return nil
+}
+
+// cleanupCache prunes the cache with the configured thresholds, unless overridden by --prune.
+// Only the entries of the named formulae are pruned unless all formulae are cleaned up.
+// The disk space freed is returned.
+func (action *Cleanup) cleanupCache(ctx context.Context, names []string, all bool) (int64, error) {
+	opts, err := pruneOptions(&action.Config().Cleanup)
+	if err != nil {
+		return 0, err
+	}
+	opts.DryRun = action.DryRun
+	if !all {
+		opts.Formulae = names
+	}
+
+	switch action.Prune {
+	case "":
+	case "all", "0":
+		opts.All = true
+	default:
+		days, err := strconv.Atoi(action.Prune)
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid value for --prune %q: must be a number of days or \"all\"", action.Prune)
+		}
+		opts.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+
+	return action.pruneCache(ctx, opts)
+}
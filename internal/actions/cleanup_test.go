@@ -0,0 +1,183 @@
+package actions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+// testCleanup creates a Cleanup action for an empty prefix.
+func testCleanup(t *testing.T, dryRun bool) *Cleanup {
+	t.Helper()
+	return &Cleanup{Hops: testHops(t), DryRun: dryRun}
+}
+
+// makeKegs creates a keg with a single executable for each version of the named formula.
+func makeKegs(t *testing.T, p prefix.Prefix, name string, versions ...string) {
+	t.Helper()
+	for _, version := range versions {
+		bin := filepath.Join(p.KegPath(name, version), "bin")
+		if err := os.MkdirAll(bin, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// makeRecord links a record to a keg of the named formula.
+func makeRecord(t *testing.T, p prefix.Prefix, record, name, version string) {
+	t.Helper()
+	if err := symlink.Relative(p.KegPath(name, version), record, &symlink.Options{MkdirParent: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertExist checks that every path exists, without following symlinks.
+func assertExist(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s does not exist: %v", path, err)
+		}
+	}
+}
+
+// assertNotExist checks that none of the paths exist.
+func assertNotExist(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, err = %v", path, err)
+		}
+	}
+}
+
+func TestCleanupKegs(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		name := "remove"
+		if dryRun {
+			name = "dry-run"
+		}
+		t.Run(name, func(t *testing.T) {
+			action := testCleanup(t, dryRun)
+			p := action.Prefix()
+			makeKegs(t, p, "foo", "1.0", "2.0", "3.0", "4.0", "5.0")
+			makeRecord(t, p, p.LinkedKegRecord("foo"), "foo", "1.0")
+			makeRecord(t, p, p.OptRecord("foo"), "foo", "2.0")
+			makeRecord(t, p, p.PinRecord("foo"), "foo", "3.0")
+
+			result := &cleanupResult{}
+			if err := action.cleanupKegs("foo", result); err != nil {
+				t.Fatal(err)
+			}
+
+			// Linked, opt-linked, pinned, and newest kegs are kept
+			assertExist(t, p.KegPath("foo", "1.0"), p.KegPath("foo", "2.0"), p.KegPath("foo", "3.0"), p.KegPath("foo", "5.0"))
+			if dryRun {
+				assertExist(t, p.KegPath("foo", "4.0"))
+			} else {
+				assertNotExist(t, p.KegPath("foo", "4.0"))
+			}
+			if result.freed == 0 {
+				t.Errorf("cleanupKegs() freed nothing")
+			}
+		})
+	}
+
+	t.Run("old name", func(t *testing.T) {
+		action := testCleanup(t, false)
+		p := action.Prefix()
+		makeKegs(t, p, "bar", "1.0", "2.0")
+		if err := os.Symlink("bar", filepath.Join(p.Cellar(), "foo")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := action.cleanupKegs("foo", &cleanupResult{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The kegs are only cleaned up by the new name
+		assertExist(t, p.KegPath("bar", "1.0"), p.KegPath("bar", "2.0"))
+	})
+}
+
+func TestCleanupRecords(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		name := "remove"
+		if dryRun {
+			name = "dry-run"
+		}
+		t.Run(name, func(t *testing.T) {
+			action := testCleanup(t, dryRun)
+			p := action.Prefix()
+			makeKegs(t, p, "foo", "2.0")
+			makeRecord(t, p, p.LinkedKegRecord("foo"), "foo", "1.0")
+			makeRecord(t, p, p.OptRecord("foo"), "foo", "2.0")
+			makeRecord(t, p, p.PinRecord("foo"), "foo", "1.0")
+
+			result := &cleanupResult{}
+			removed := map[string]bool{}
+			if err := action.cleanupRecords("foo", result, removed); err != nil {
+				t.Fatal(err)
+			}
+
+			assertExist(t, p.OptRecord("foo"))
+			if dryRun {
+				assertExist(t, p.LinkedKegRecord("foo"), p.PinRecord("foo"))
+			} else {
+				assertNotExist(t, p.LinkedKegRecord("foo"), p.PinRecord("foo"))
+			}
+			if result.links != 2 || !removed[p.LinkedKegRecord("foo")] || !removed[p.PinRecord("foo")] {
+				t.Errorf("cleanupRecords() = %d links, removed %v, want the linked and pinned records", result.links, removed)
+			}
+		})
+	}
+}
+
+func TestCleanupRack(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		name := "remove"
+		if dryRun {
+			name = "dry-run"
+		}
+		t.Run(name, func(t *testing.T) {
+			action := testCleanup(t, dryRun)
+			p := action.Prefix()
+			for _, dir := range []string{filepath.Join(p.Cellar(), "empty"), p.KegPath("full", "1.0")} {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Old names link to the racks of the new names
+			if err := os.Symlink("full", filepath.Join(p.Cellar(), "renamed")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("gone", filepath.Join(p.Cellar(), "dangling")); err != nil {
+				t.Fatal(err)
+			}
+
+			result := &cleanupResult{}
+			for _, name := range []string{"empty", "full", "renamed", "dangling", "missing"} {
+				if err := action.cleanupRack(name, result); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			assertExist(t, p.KegPath("full", "1.0"), filepath.Join(p.Cellar(), "renamed"))
+			if dryRun {
+				assertExist(t, filepath.Join(p.Cellar(), "empty"), filepath.Join(p.Cellar(), "dangling"))
+			} else {
+				assertNotExist(t, filepath.Join(p.Cellar(), "empty"), filepath.Join(p.Cellar(), "dangling"))
+			}
+			if result.dirs != 1 || result.links != 1 {
+				t.Errorf("cleanupRack() = %d dirs and %d links, want 1 and 1", result.dirs, result.links)
+			}
+		})
+	}
+}
@@ -22,7 +22,9 @@ import (
 	brewformulary "github.com/act3-ai/hops/internal/brew/formulary"
 	"github.com/act3-ai/hops/internal/brewfile"
 	"github.com/act3-ai/hops/internal/dependencies"
+	"github.com/act3-ai/hops/internal/errdef"
 	"github.com/act3-ai/hops/internal/formula"
+	hopsreg "github.com/act3-ai/hops/internal/hops/registry"
 	"github.com/act3-ai/hops/internal/o"
 	"github.com/act3-ai/hops/internal/platform"
 	"github.com/act3-ai/hops/internal/utils/logutil"
@@ -32,6 +34,7 @@ import (
 type copiedBottle struct {
 	repo      oras.GraphTarget
 	info      *formula.V1
+	locked    string // locked digest of the bottle index, if any
 	indexDesc ocispec.Descriptor
 	index     *ocispec.Index
 }
@@ -42,6 +45,7 @@ type Copy struct {
 	DependencyOptions formula.DependencyTags
 
 	Brewfile []string // path to Brewfile specifying formulae
+	Lock     string   // path to a Brewfile lock file whose bottles must be copied
 
 	From          hopsv1.RegistryConfig // source registry for bottles
 	FromAPIDomain string                // HOMEBREW_API_DOMAIN to source metadata from
@@ -66,7 +70,20 @@ func (action *Copy) Run(ctx context.Context, args []string) error {
 		if err != nil {
 			return err
 		}
-		args = append(args, bf.Formula...)
+		args = append(args, bf.ForPlatform(platform.All).Formula...)
+	}
+
+	// Resolve the locked bottles, copying all of them if nothing else is requested
+	var lock *brewfile.Lock
+	if action.Lock != "" {
+		var err error
+		lock, err = action.loadLock(action.Lock)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			args = lock.Formulae()
+		}
 	}
 
 	o.H1("Copying:\n" + strings.Join(args, " "))
@@ -88,9 +105,14 @@ func (action *Copy) Run(ctx context.Context, args []string) error {
 	switch action.FromAPIDomain {
 	// Source API data from the source registry (assumes Hops-style bottles)
 	case "":
+		renames, err := brewformulary.LoadRenames(action.Config().Cache)
+		if err != nil {
+			return err
+		}
 		formulary = hopsClient(
 			filepath.Join(action.Config().Cache, "oci"),
 			action.alternateTags,
+			renames,
 			action.MaxGoroutines(),
 			srcReg)
 	// Use the API to source metadata
@@ -128,6 +150,14 @@ func (action *Copy) Run(ctx context.Context, args []string) error {
 		}
 	}
 
+	// Fail before copying if the source no longer matches the lock file
+	if lock != nil {
+		err = checkLockedCopies(ctx, lock, srcReg, copiedBottles)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = action.copy(ctx, sources, copiedBottles)
 	if err != nil {
 		return err
@@ -145,7 +175,7 @@ func (action *Copy) resolve(ctx context.Context, formulary formula.Formulary, na
 	}
 
 	o.H1("Fetching dependencies...")
-	graph, err := dependencies.WalkAll(ctx, formulary, all, &action.DependencyOptions)
+	graph, err := dependencies.WalkAll(ctx, formulary, all, &action.DependencyOptions, action.MaxGoroutines())
 	if err != nil {
 		return nil, err
 	}
@@ -175,6 +205,29 @@ func (action *Copy) resolve(ctx context.Context, formulary formula.Formulary, na
 	return metadata, nil
 }
 
+// checkLockedCopies verifies that each bottle to copy is locked and still
+// matches the lock file in the source registry.
+func checkLockedCopies(ctx context.Context, lock *brewfile.Lock, src hopsreg.Registry, copiedBottles []*copiedBottle) error {
+	var errs error
+	for _, f := range copiedBottles {
+		locked, err := checkLockedVersion(lock, f.info)
+		switch {
+		case err != nil:
+		// Locked from the Homebrew API, the bottles are checked by their digests
+		case locked.Digest == "":
+			err = checkLockedBottles(locked, f.info)
+		default:
+			err = checkLockedIndex(ctx, src, f.info.Name(), locked)
+		}
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		f.locked = locked.Digest
+	}
+	return errs
+}
+
 func (action *Copy) copy(ctx context.Context, sources []oras.GraphTarget, copiedBottles []*copiedBottle) error { //nolint:revive
 	// Initialize Goroutine pool to reuse for each stage of the copy
 	routines := pool.New().
@@ -189,6 +242,10 @@ func (action *Copy) copy(ctx context.Context, sources []oras.GraphTarget, copied
 			if err != nil {
 				return fmt.Errorf("[%s] %w", f.info.Name(), err)
 			}
+			// The tag may have moved since it was checked
+			if f.locked != "" && f.indexDesc.Digest.String() != f.locked {
+				return errdef.NewLockMismatchError(f.info.Name(), "Bottle index digest", f.locked, f.indexDesc.Digest.String())
+			}
 			return nil
 		})
 	}
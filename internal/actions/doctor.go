@@ -0,0 +1,200 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	brewformulary "github.com/act3-ai/hops/internal/brew/formulary"
+	"github.com/act3-ai/hops/internal/doctor"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Doctor represents the action and its options.
+type Doctor struct {
+	*Hops
+
+	JSON bool // Print output in JSON format
+}
+
+// Checks produces the checks that diagnose the configured prefix.
+func (action *Doctor) Checks() []doctor.Check {
+	p := action.Prefix()
+	cfg := action.Config()
+
+	checks := []doctor.Check{
+		&doctor.Config{Files: action.ConfigFiles, Config: cfg},
+		&doctor.Path{Prefix: p, Path: os.Getenv("PATH")},
+		&doctor.Permissions{Prefix: p},
+		&doctor.BrokenLinks{Prefix: p},
+		&doctor.MissingReceipts{Prefix: p},
+		&doctor.MissingDependencies{Prefix: p},
+		&doctor.UnlinkedKegs{Prefix: p, Formulary: action.Formulary, Platform: platform.SystemPlatform()},
+	}
+
+	if cfg.Registry.Prefix == "" {
+		checks = append(checks, &doctor.APICache{
+			Dir:        cfg.Cache,
+			AutoUpdate: &cfg.Homebrew.API.AutoUpdate,
+			MaxAge:     doctor.DefaultCacheMaxAge,
+		}, &doctor.RenamedFormulae{
+			Prefix: p,
+			Renames: func(ctx context.Context) (map[string]string, error) {
+				index, err := action.brewFormulary(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return index.Renames(), nil
+			},
+		})
+	} else if reg, err := hopsRegistry(&cfg.Registry, action.UserAgent()); err == nil {
+		// Invalid registry settings are reported by the config check
+		if pinger, ok := reg.(doctor.Pinger); ok {
+			checks = append(checks, &doctor.Registry{Prefix: cfg.Registry.Prefix, Registry: pinger})
+		}
+	}
+
+	return checks
+}
+
+// ListChecks prints the available checks.
+func (action *Doctor) ListChecks() {
+	for _, check := range action.Checks() {
+		fmt.Println(o.StyleBold(check.Name()) + ": " + check.Description())
+	}
+}
+
+// Run runs the action.
+func (action *Doctor) Run(ctx context.Context, names ...string) error {
+	checks := action.Checks()
+
+	// Run only the named checks
+	if len(names) > 0 {
+		selected := make([]doctor.Check, 0, len(names))
+		for _, name := range names {
+			i := slices.IndexFunc(checks, func(c doctor.Check) bool { return c.Name() == name })
+			if i < 0 {
+				return fmt.Errorf("unknown check %q, see `hops doctor --list-checks`", name)
+			}
+			selected = append(selected, checks[i])
+		}
+		checks = selected
+	}
+
+	return action.run(ctx, checks)
+}
+
+// run runs the checks and prints their results.
+// Problems found and checks that could not run are both reported as an error.
+func (action *Doctor) run(ctx context.Context, checks []doctor.Check) error {
+	// Checks run concurrently, so the formulary they share is loaded before they run
+	action.loadFormulary(ctx, checks)
+
+	results := doctor.Run(ctx, checks, action.MaxGoroutines())
+
+	problems, failed := 0, 0
+	for _, r := range results {
+		problems += len(r.Problems)
+		if r.Error != "" {
+			failed++
+		}
+	}
+
+	if action.JSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		printDoctorResults(results)
+	}
+
+	var errs error
+	if problems > 0 {
+		word := "problems"
+		if problems == 1 {
+			word = "problem"
+		}
+		errs = errors.Join(errs, fmt.Errorf("found %d %s", problems, word))
+	}
+	if failed > 0 {
+		word := "checks"
+		if failed == 1 {
+			word = "check"
+		}
+		errs = errors.Join(errs, fmt.Errorf("%d %s could not run", failed, word))
+	}
+	return errs
+}
+
+// loadFormulary loads the formulary once for the checks that use it.
+//
+// A formulary that could not be loaded is reported by each of those checks.
+func (action *Doctor) loadFormulary(ctx context.Context, checks []doctor.Check) {
+	var (
+		loaded    bool
+		formulary formula.Formulary
+		err       error
+	)
+	load := func() {
+		if !loaded {
+			formulary, err = action.Formulary(ctx)
+			loaded = true
+		}
+	}
+
+	for _, check := range checks {
+		switch c := check.(type) {
+		case *doctor.UnlinkedKegs:
+			load()
+			c.Formulary = func(context.Context) (formula.Formulary, error) {
+				return formulary, err
+			}
+		case *doctor.RenamedFormulae:
+			load()
+			c.Renames = func(context.Context) (map[string]string, error) {
+				if err != nil {
+					return nil, err
+				}
+				index, ok := formulary.(brewformulary.PreloadedFormulary)
+				if !ok {
+					return nil, errors.New("formulary does not record renamed formulae")
+				}
+				return index.Renames(), nil
+			}
+		}
+	}
+}
+
+// printDoctorResults prints the problems found by each check.
+func printDoctorResults(results []doctor.Result) {
+	ok := true
+	for _, r := range results {
+		if r.Error != "" {
+			ok = false
+			o.Poo(fmt.Sprintf("Could not run the %s check: %s", r.Check, r.Error))
+		}
+		for _, p := range r.Problems {
+			ok = false
+			msg := p.Message
+			if len(p.Details) > 0 {
+				msg += "\n  " + strings.Join(p.Details, "\n  ")
+			}
+			msg += "\n" + p.Fix + "\n"
+			switch p.Severity {
+			case doctor.SeverityError:
+				o.Noe(msg)
+			default:
+				o.Poo(msg)
+			}
+		}
+	}
+
+	if ok {
+		fmt.Println("Your system is ready to brew.")
+	}
+}
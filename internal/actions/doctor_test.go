@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/doctor"
+)
+
+// testCheck is a doctor.Check with fixed results.
+type testCheck struct {
+	problems []doctor.Problem
+	err      error
+}
+
+func (c *testCheck) Name() string        { return "test" }
+func (c *testCheck) Description() string { return "Test check" }
+func (c *testCheck) Run(context.Context) ([]doctor.Problem, error) {
+	return c.problems, c.err
+}
+
+func TestDoctor_run(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   *testCheck
+		wantErr bool
+	}{
+		{"ok", &testCheck{}, false},
+		{"problem", &testCheck{problems: []doctor.Problem{{Message: "broken"}}}, true},
+		{"error", &testCheck{err: errors.New("could not check")}, true},
+	}
+	for _, tt := range tests {
+		for _, json := range []bool{false, true} {
+			action := &Doctor{Hops: testHops(t), JSON: json}
+			err := action.run(context.Background(), []doctor.Check{tt.check})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%s: run() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		}
+	}
+}
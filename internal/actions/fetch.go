@@ -0,0 +1,173 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/sourcegraph/conc/iter"
+
+	"github.com/act3-ai/hops/internal/brewfile"
+	"github.com/act3-ai/hops/internal/dependencies"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/formula/bottle"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Fetch represents the action and its options.
+type Fetch struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+
+	Platform platform.Platform // platform to fetch bottles for, "all" for every supported platform
+	Deps     bool              // also fetch bottles for dependencies
+	Brewfile string            // path to a Brewfile specifying formulae
+}
+
+// fetchedBottle is the outcome of downloading one bottle.
+type fetchedBottle struct {
+	formula formula.PlatformFormula
+	cached  bool
+	err     error
+}
+
+// Run runs the action.
+func (action *Fetch) Run(ctx context.Context, args ...string) error {
+	plat := action.Platform
+	if plat == "" {
+		plat = platform.SystemPlatform()
+	}
+	if !platform.IsValid(plat.String()) {
+		return fmt.Errorf("no bottles for unsupported platform %q: %w", plat, platform.ErrInvalidPlatform)
+	}
+
+	// Add Brewfile formulae if requested
+	if action.Brewfile != "" {
+		bf, err := brewfile.Load(action.Brewfile)
+		if err != nil {
+			return err
+		}
+		args = append(args, bf.ForPlatform(plat).Formula...)
+
+		// Fetch the locked bottles if the Brewfile has been locked
+		if _, err := os.Stat(brewfile.LockPath(action.Brewfile)); err == nil {
+			if _, err := action.loadLock(brewfile.LockPath(action.Brewfile)); err != nil {
+				return err
+			}
+		}
+	}
+
+	formulae, err := action.bottledFormulae(ctx, args, plat)
+	if err != nil {
+		return err
+	}
+
+	// Keep the cache from being pruned while bottles are written to it
+	releaseCache, err := action.lockCache(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer releaseCache()
+
+	reg, err := action.BottleRegistry()
+	if err != nil {
+		return err
+	}
+
+	mapper := iter.Mapper[formula.PlatformFormula, fetchedBottle]{MaxGoroutines: action.MaxGoroutines()}
+	results := mapper.Map(formulae, func(f *formula.PlatformFormula) fetchedBottle {
+		cached, err := bottle.Download(ctx, reg, *f)
+		return fetchedBottle{formula: *f, cached: cached, err: err}
+	})
+
+	downloaded, cached := 0, 0
+	var errs []error
+	for _, r := range results {
+		file := formula.BottleFileName(r.formula)
+		switch {
+		case r.err != nil:
+			errs = append(errs, fmt.Errorf("[%s] %w", file, r.err))
+		case r.cached:
+			cached++
+			fmt.Println("Already downloaded: " + file)
+		default:
+			downloaded++
+			fmt.Println("Downloaded: " + o.StyleBold(file))
+		}
+	}
+
+	word := "bottles"
+	if downloaded+cached == 1 {
+		word = "bottle"
+	}
+	o.Hai(fmt.Sprintf("Fetched %d %s: %d downloaded, %d already cached", downloaded+cached, word, downloaded, cached))
+
+	if len(errs) > 0 {
+		word := "bottles"
+		if len(errs) == 1 {
+			word = "bottle"
+		}
+		return fmt.Errorf("failed to fetch %d %s: %w", len(errs), word, errors.Join(errs...))
+	}
+	return nil
+}
+
+// bottledFormulae resolves the formulae to fetch for each requested platform,
+// skipping platforms without a bottle when fetching for all platforms.
+func (action *Fetch) bottledFormulae(ctx context.Context, args []string, plat platform.Platform) ([]formula.PlatformFormula, error) {
+	result := []formula.PlatformFormula{}
+	bottled := map[string]bool{} // formulae with a bottle on any platform
+	seen := map[string]bool{}    // bottles already added
+	for _, p := range plat.Computed() {
+		formulae, err := action.fetchFromArgs(ctx, args, p)
+		if err != nil {
+			return nil, err
+		}
+
+		if action.Deps {
+			store, err := action.Formulary(ctx)
+			if err != nil {
+				return nil, err
+			}
+			graph, err := dependencies.Walk(ctx, store, formulae, p, &action.DependencyOptions, action.MaxGoroutines())
+			if err != nil {
+				return nil, err
+			}
+			formulae = slices.Concat(graph.Roots(), graph.Dependencies())
+		}
+
+		for _, f := range formulae {
+			btl := f.Bottle()
+			if btl == nil {
+				if _, ok := bottled[f.Name()]; !ok {
+					bottled[f.Name()] = false
+				}
+				continue
+			}
+			bottled[f.Name()] = true
+
+			// Bottles for "all" platforms are cached once for each platform
+			key := f.Name() + "@" + string(f.Platform())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, f)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(bottled)) {
+		if !bottled[name] {
+			if plat == platform.All {
+				return nil, fmt.Errorf("no bottle available for %s", name)
+			}
+			return nil, fmt.Errorf("no bottle available for %s on %s", name, plat)
+		}
+	}
+
+	return result, nil
+}
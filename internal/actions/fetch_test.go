@@ -0,0 +1,50 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+func TestFetch_Run(t *testing.T) {
+	action := &Fetch{Hops: testHops(t), Platform: "windows"}
+	err := action.Run(context.Background(), "foo")
+	if !errors.Is(err, platform.ErrInvalidPlatform) || !strings.Contains(err.Error(), "windows") {
+		t.Errorf("Run() = %v, want an error naming the unsupported platform", err)
+	}
+}
+
+func TestFetch_bottledFormulae(t *testing.T) {
+	ctx := context.Background()
+	action := &Fetch{Hops: testHops(t)}
+	action.brewformulary = testAPIFormulary{
+		"foo": testAPIInfo("foo", "1.0", map[platform.Platform]string{platform.X8664Linux: "linux"}),
+		"bar": testAPIInfo("bar", "1.0", map[platform.Platform]string{platform.Arm64Sonoma: "sonoma"}),
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		plat    platform.Platform
+		want    int
+		wantErr bool
+	}{
+		{name: "bottled", args: []string{"foo"}, plat: platform.X8664Linux, want: 1},
+		{name: "no bottle for platform", args: []string{"foo", "bar"}, plat: platform.X8664Linux, wantErr: true},
+		{name: "all platforms", args: []string{"foo", "bar"}, plat: platform.All, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := action.bottledFormulae(ctx, tt.args, tt.plat)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bottledFormulae() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("bottledFormulae() = %d formulae, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
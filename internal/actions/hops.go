@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -30,12 +31,16 @@ type Hops struct {
 	EnvFiles    []string // load environment variables from these files
 	Concurrency int      // sets the maximum threads for any parallel tasks
 
+	Wait        bool          // wait for locks held by other processes
+	WaitTimeout time.Duration // stop waiting for locks after this long
+
 	// callback functions to override runtime-loaded configuration
 	configOverrides []func(cfg *hopsv1.Configuration)
 
 	// cache for runtime-loaded objects
 	cfg           *hopsv1.Configuration
 	alternateTags map[string]string
+	lockedTags    map[string]string // tags from a lock file, used unless another tag is requested
 	hopsclient    hops.Client
 	brewformulary brewformulary.PreloadedFormulary
 	brewregistry  brewreg.Registry
@@ -68,6 +73,71 @@ func (action *Hops) MaxGoroutines() int {
 	return action.Concurrency
 }
 
+// LockOptions produces the options for taking locks in the prefix.
+func (action *Hops) LockOptions() *prefix.LockOptions {
+	return &prefix.LockOptions{
+		Wait:    action.Wait,
+		Timeout: action.WaitTimeout,
+	}
+}
+
+// lockFormulae locks the named formulae against other hops and brew processes.
+// The returned function releases the locks.
+func (action *Hops) lockFormulae(ctx context.Context, names ...string) (func(), error) {
+	lock, err := action.Prefix().LockFormulae(ctx, action.LockOptions(), names...)
+	if err != nil {
+		return nil, err
+	}
+	return func() { unlock(lock) }, nil
+}
+
+// lockLinks takes the prefix-wide link lock.
+// The returned function releases the lock.
+func (action *Hops) lockLinks(ctx context.Context) (func(), error) {
+	lock, err := action.Prefix().LockLinks(ctx, action.LockOptions())
+	if err != nil {
+		return nil, err
+	}
+	return func() { unlock(lock) }, nil
+}
+
+// lockPrefix locks the named formulae and then takes the link lock,
+// for actions that only change links and kegs that are already installed.
+// The returned function releases the locks.
+func (action *Hops) lockPrefix(ctx context.Context, names ...string) (func(), error) {
+	releaseFormulae, err := action.lockFormulae(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+	releaseLinks, err := action.lockLinks(ctx)
+	if err != nil {
+		releaseFormulae()
+		return nil, err
+	}
+	return func() {
+		releaseLinks()
+		releaseFormulae()
+	}, nil
+}
+
+// lockCache locks the cache against other hops processes. Bottles are written
+// to the cache under a shared lock, and the cache is pruned under an exclusive lock.
+// The returned function releases the lock.
+func (action *Hops) lockCache(ctx context.Context, exclusive bool) (func(), error) {
+	lock, err := prefix.LockFile(ctx, action.LockOptions(), action.cache().LockFile(), !exclusive)
+	if err != nil {
+		return nil, err
+	}
+	return func() { unlock(lock) }, nil
+}
+
+// unlock releases a lock, logging failures.
+func unlock(lock *prefix.Lock) {
+	if err := lock.Unlock(); err != nil {
+		slog.Warn("Releasing lock", logutil.ErrAttr(err))
+	}
+}
+
 // Prefix produces the configured prefix.
 func (action *Hops) Prefix() prefix.Prefix {
 	return prefix.Prefix(action.Config().Prefix)
@@ -130,16 +200,28 @@ func (action *Hops) Config() *hopsv1.Configuration {
 	return action.cfg
 }
 
-// SetAlternateVersions sets alternate tags from a list of arguments,
+// SetAlternateTags sets alternate tags from a list of arguments,
 // and returns the isolated names from the arguments.
+//
+// The map is refilled in place, so a client that was already created sees the new tags.
 func (action *Hops) SetAlternateTags(args []string) (names []string) {
-	action.alternateTags = map[string]string{}
+	if action.alternateTags == nil {
+		action.alternateTags = map[string]string{}
+	}
+	clear(action.alternateTags)
 	names = make([]string, 0, len(args))
 	for _, arg := range args {
 		name, version := parseArg(arg)
 		action.alternateTags[name] = version
 		names = append(names, name)
 	}
+
+	// Fall back to locked tags, including for dependencies
+	for name, tag := range action.lockedTags {
+		if action.alternateTags[name] == "" {
+			action.alternateTags[name] = tag
+		}
+	}
 	return names
 }
 
@@ -176,9 +258,20 @@ func (action *Hops) hopsClient() (hops.Client, error) {
 			return nil, err
 		}
 
+		// Old names are resolved with the renames of the cached Homebrew API index
+		renames, err := brewformulary.LoadRenames(action.Config().Cache)
+		if err != nil {
+			return nil, err
+		}
+
+		// The client keeps the map, which SetAlternateTags refills in place
+		if action.alternateTags == nil {
+			action.alternateTags = map[string]string{}
+		}
 		action.hopsclient = hopsClient(
 			filepath.Join(action.Config().Cache, "oci"),
 			action.alternateTags,
+			renames,
 			action.MaxGoroutines(),
 			reg)
 	}
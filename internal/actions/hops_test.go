@@ -0,0 +1,36 @@
+package actions
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	hopsv1 "github.com/act3-ai/hops/internal/apis/config.hops.io/v1beta1"
+)
+
+// testHops creates a Hops for an empty prefix.
+func testHops(t *testing.T) *Hops {
+	t.Helper()
+	return &Hops{
+		version: "test",
+		cfg:     &hopsv1.Configuration{Prefix: t.TempDir()},
+	}
+}
+
+func TestHops_SetAlternateTags(t *testing.T) {
+	action := &Hops{lockedTags: map[string]string{"bar": "1.0", "foo": "2.0"}}
+
+	// A client created before the tags are set keeps the same map
+	action.SetAlternateTags(nil)
+	tags := action.alternateTags
+
+	names := action.SetAlternateTags([]string{"foo:3.0", "baz"})
+	if want := []string{"foo", "baz"}; !slices.Equal(names, want) {
+		t.Errorf("SetAlternateTags() = %v, want %v", names, want)
+	}
+
+	want := map[string]string{"foo": "3.0", "bar": "1.0", "baz": ""}
+	if !maps.Equal(tags, want) {
+		t.Errorf("alternate tags = %v, want %v", tags, want)
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	brewfmt "github.com/act3-ai/hops/internal/brew/fmt"
 	"github.com/act3-ai/hops/internal/brewfile"
+	"github.com/act3-ai/hops/internal/errdef"
 	"github.com/act3-ai/hops/internal/formula"
 	hopsreg "github.com/act3-ai/hops/internal/hops/registry"
 	"github.com/act3-ai/hops/internal/o"
@@ -24,8 +25,11 @@ type Images struct {
 	DependencyOptions formula.DependencyTags
 
 	File      string // path to a Brewfile specifying formulae dependencies
+	Lock      string // path to a Brewfile lock file whose bottles must be listed
 	NoResolve bool   // disable tag resolution
 	NoVerify  bool   // disable tag verification
+
+	lock *brewfile.Lock // loaded lock file
 }
 
 // Run runs the action.
@@ -36,7 +40,19 @@ func (action *Images) Run(ctx context.Context, args ...string) error {
 		if err != nil {
 			return err
 		}
-		args = append(args, bf.Formula...)
+		args = append(args, bf.ForPlatform(platform.All).Formula...)
+	}
+
+	// Resolve the locked bottles, listing all of them if nothing else is requested
+	if action.Lock != "" {
+		var err error
+		action.lock, err = action.loadLock(action.Lock)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			args = action.lock.Formulae()
+		}
 	}
 
 	slog.Debug("finding images for", slog.Any("formulae", args))
@@ -109,8 +125,25 @@ func (action *Images) listImages(ctx context.Context, formulae []formula.Platfor
 func (action *Images) resolve(ctx context.Context, reg hopsreg.Registry, f formula.Formula) (string, error) {
 	image := strings.TrimSuffix(action.Config().Registry.Prefix, "/") + "/" + brewfmt.Repo(f.Name()) + ":" + formula.Tag(f)
 
-	if action.NoVerify {
-		// Skip any resolving or verifying
+	var locked *brewfile.LockedFormula
+	if action.lock != nil {
+		var err error
+		locked, err = checkLockedVersion(action.lock, f)
+		if err != nil {
+			return "", err
+		}
+		// Images are pinned by the digest of the bottle index
+		if locked.Digest == "" {
+			return "", errNoLockedIndex(f.Name())
+		}
+	}
+
+	switch {
+	// Use the locked digest without verifying it
+	case action.NoVerify && locked != nil && !action.NoResolve:
+		return image + "@" + locked.Digest, nil
+	// Skip any resolving or verifying
+	case action.NoVerify:
 		return image, nil
 	}
 
@@ -128,6 +161,10 @@ func (action *Images) resolve(ctx context.Context, reg hopsreg.Registry, f formu
 		return "", fmt.Errorf("verifying bottle tag: %w", err)
 	}
 
+	if locked != nil && desc.Digest.String() != locked.Digest {
+		return "", errdef.NewLockMismatchError(f.Name(), "Bottle index digest", locked.Digest, desc.Digest.String())
+	}
+
 	if action.NoResolve {
 		// Add the image without the appending digest
 		return image, nil
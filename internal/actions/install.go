@@ -6,22 +6,27 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/muesli/reflow/wordwrap"
-	"github.com/sourcegraph/conc/iter"
 
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/brewfile"
 	"github.com/act3-ai/hops/internal/dependencies"
 	"github.com/act3-ai/hops/internal/errdef"
 	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/formula/bottle"
+	hopsreg "github.com/act3-ai/hops/internal/hops/registry"
 	"github.com/act3-ai/hops/internal/o"
 	"github.com/act3-ai/hops/internal/platform"
 	"github.com/act3-ai/hops/internal/prefix"
 	"github.com/act3-ai/hops/internal/pretty"
-	"github.com/act3-ai/hops/internal/utils/iterutil"
+	"github.com/act3-ai/hops/internal/utils/logutil"
+	"github.com/act3-ai/hops/internal/utils/symlink"
 )
 
 // Install represents the action and its options.
@@ -30,7 +35,11 @@ type Install struct {
 
 	DependencyOptions formula.DependencyTags
 
-	platform platform.Platform // store target platform
+	platform  platform.Platform // store target platform
+	requested []string          // names of directly requested formulae
+	unlinked  map[string]bool   // names of formulae that will not be linked
+	linked    map[string]bool   // names of keg-only formulae that will be linked
+	overwrite map[string]bool   // names of formulae that overwrite existing files while linking
 
 	// Install formulae without checking for previously installed keg-only
 	// or non-migrated versions.
@@ -54,18 +63,53 @@ type Install struct {
 
 	// Delete files that already exist in the prefix while linking
 	Overwrite bool
+
+	// Install formulae that conflict with linked formulae without linking them
+	Unlinked bool
+
+	// Path to a Brewfile lock file whose bottles must be installed
+	Lock string
 }
 
 // Run runs the action.
 func (action *Install) Run(ctx context.Context, args ...string) error {
 	action.platform = platform.SystemPlatform()
+
+	// Resolve the locked bottles, installing all of them if nothing else is requested
+	var lock *brewfile.Lock
+	if action.Lock != "" {
+		var err error
+		lock, err = action.loadLock(action.Lock)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			args = lock.Formulae()
+		}
+	}
+
 	names := action.SetAlternateTags(args)
 
+	// Undo changes left behind by interrupted installs
+	if !action.DryRun {
+		if err := action.Prefix().Recover(); err != nil {
+			return err
+		}
+	}
+
 	installs, err := action.resolveInstalls(ctx, names)
 	if err != nil {
 		return err
 	}
 
+	// Fail if the bottles no longer match the lock file
+	if lock != nil {
+		err = action.checkLock(ctx, lock, installs)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Exit here for dry run
 	if action.DryRun {
 		return nil
@@ -76,34 +120,64 @@ func (action *Install) Run(ctx context.Context, args ...string) error {
 		return nil
 	}
 
+	// Check for conflicts before anything is downloaded
+	err = action.checkConflicts(installs)
+	if err != nil {
+		return err
+	}
+
+	// Lock the formulae against other hops and brew processes
+	release, err := action.lockFormulae(ctx, formula.Names(installs)...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Verify that all bottles can be poured
 	err = action.Prefix().CanPourBottles(ctx, installs)
 	if err != nil {
 		return err
 	}
 
+	// Keep the cache from being pruned while bottles are written to it
+	releaseCache, err := action.lockCache(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer releaseCache()
+
 	// Get bottle registry
 	reg, err := action.BottleRegistry()
 	if err != nil {
 		return err
 	}
 
-	// Download all bottles
-	bottles, err := bottle.FetchAll(ctx, reg, installs)
+	// Record changes to the prefix so a failed install can be undone
+	tx, err := action.Prefix().Begin()
 	if err != nil {
 		return err
 	}
 
-	// Install the downloaded bottles
-	routines := iter.Iterator[formula.PlatformFormula]{MaxGoroutines: action.MaxGoroutines()}
-	err = iterutil.ForEachIdxErr(routines, installs, func(i int, pf *formula.PlatformFormula) error {
-		// var err error // create local err variable to avoid race
-		btl := bottles[i]
+	// Stream each bottle into the Cellar as it downloads
+	err = pourAll(ctx, installs, &action.DependencyOptions, action.MaxGoroutines(), func(ctx context.Context, f formula.PlatformFormula) error {
+		btl, err := bottle.Fetch(ctx, reg, f)
+		if err != nil {
+			return err
+		}
 		return errors.Join(
-			action.run(ctx, *pf, btl),
+			action.run(ctx, tx, f, btl),
 			btl.Close(),
 		)
 	})
+	if err == nil {
+		err = ctx.Err() // interrupted after the last bottle was poured
+	}
+	if err != nil {
+		o.Poo("Rolling back installation")
+		return errors.Join(err, tx.Rollback())
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return err
 	}
@@ -140,10 +214,25 @@ func (action *Install) resolveInstalls(ctx context.Context, names []string) ([]f
 	if err != nil {
 		return nil, err
 	}
+	action.requested = formula.Names(roots)
 
-	// Direct user to the reinstall command
+	// Direct user to the upgrade or reinstall command
 	for _, f := range reinstalls {
 		version := formula.PkgVersion(f)
+		outdated, err := action.Prefix().FormulaOutdated(f)
+		if err != nil {
+			return nil, err
+		}
+		if outdated {
+			o.Poo(heredoc.Docf(`
+				%s is already installed but outdated
+				To upgrade to %s, run:
+				  hops upgrade %s`,
+				f.Name(),
+				version,
+				f.Name()))
+			continue
+		}
 		o.Poo(heredoc.Docf(`
 			%s
 			To reinstall %s, run:
@@ -165,7 +254,7 @@ func (action *Install) resolveInstalls(ctx context.Context, names []string) ([]f
 	}
 
 	// Build dependency graph
-	graph, err := dependencies.Walk(ctx, formulary, roots, action.platform, &action.DependencyOptions)
+	graph, err := dependencies.Walk(ctx, formulary, roots, action.platform, &action.DependencyOptions, action.MaxGoroutines())
 	if err != nil {
 		return nil, err
 	}
@@ -196,37 +285,203 @@ func (action *Install) resolveInstalls(ctx context.Context, names []string) ([]f
 	return slices.Concat(missingDeps, graph.Roots()), nil
 }
 
+// checkLock verifies that the formulae resolved to the bottles in the lock file.
+func (action *Install) checkLock(ctx context.Context, lock *brewfile.Lock, installs []formula.PlatformFormula) error {
+	// Bottle indexes can only be checked in a registry
+	var reg hopsreg.Registry
+	if action.Config().Registry.Prefix != "" {
+		var err error
+		reg, err = hopsRegistry(&action.Config().Registry, action.UserAgent())
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs error
+	for _, f := range installs {
+		locked, err := checkLockedVersion(lock, f)
+		if err == nil {
+			err = checkLockedBottle(locked, f)
+		}
+		if err == nil && reg != nil {
+			err = checkLockedIndex(ctx, reg, f.Name(), locked)
+		}
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
+
+// checkConflicts verifies that no formula conflicts with a linked formula
+// or with another formula that will be linked by this install.
+//
+// With the Unlinked option, conflicting formulae are installed without
+// being linked instead.
+func (action *Install) checkConflicts(installs []formula.PlatformFormula) error {
+	// Formulae this install will link
+	linking := map[string]bool{}
+	for _, f := range installs {
+		if action.links(f) {
+			linking[f.Name()] = true
+		}
+	}
+
+	var errs error
+	for _, f := range installs {
+		if !action.links(f) {
+			continue
+		}
+
+		conflicts, reasons := []string{}, []string{}
+		for _, c := range f.Conflicts() {
+			linked, err := action.Prefix().LinkedKeg(c.Name)
+			if err != nil {
+				return err
+			}
+			if linked != "" || linking[c.Name] {
+				conflicts = append(conflicts, c.Name)
+				reasons = append(reasons, c.Reason)
+			}
+		}
+
+		switch {
+		// No conflicts
+		case len(conflicts) == 0:
+		// Install without linking
+		case action.Unlinked:
+			o.Poo(fmt.Sprintf("%s conflicts with %s and will not be linked", f.Name(), strings.Join(conflicts, ", ")))
+			if action.unlinked == nil {
+				action.unlinked = map[string]bool{}
+			}
+			action.unlinked[f.Name()] = true
+			delete(linking, f.Name())
+		// Fail before anything is downloaded
+		default:
+			errs = errors.Join(errs, errdef.NewFormulaConflictError(f.Name(), conflicts, reasons))
+		}
+	}
+
+	return errs
+}
+
+// links reports whether the formula's keg will be linked into the prefix.
+func (action *Install) links(f formula.PlatformFormula) bool {
+	return (!f.IsKegOnly() || action.Force || action.linked[f.Name()]) && !action.unlinked[f.Name()]
+}
+
+// pourAll pours each formula once all of its dependencies in the list have been poured.
+//
+// Up to maxGoroutines formulae are poured at once. A failure skips the formulae
+// that depend on the failed formula.
+func pourAll(ctx context.Context, formulae []formula.PlatformFormula, tags *formula.DependencyTags, maxGoroutines int, pour func(context.Context, formula.PlatformFormula) error) error {
+	graph, err := dependencies.New(formulae, tags)
+	if err != nil {
+		return err
+	}
+	return graph.Schedule(ctx, maxGoroutines, pour)
+}
+
 // run is the meat.
-func (action *Install) run(_ context.Context, f formula.PlatformFormula, btl io.Reader) error {
+func (action *Install) run(ctx context.Context, tx *prefix.Transaction, f formula.PlatformFormula, btl io.Reader) error {
 	l := slog.Default().With(slog.String("formula", f.Name()))
 
 	// 2. Pour bottle to the Cellar
 	l.Info("Pouring bottle", slog.String("file", formula.BottleFileName(f)))
 	// slog.Info("Pouring " + b.ArchiveName()) // ex: Pouring cowsay--3.04_1.arm64_sonoma.bottle.tar.gz
-	err := action.Prefix().Pour(btl)
+	// The keg is relocated and its receipt written while it is staged,
+	// so it only replaces the installed keg once it is complete
+	err := tx.Pour(ctx, btl, func(keg string) error {
+		// Replace install location placeholders in the keg
+		l.Debug("Relocating keg")
+		if err := action.Prefix().Relocate(f, keg); err != nil {
+			return err
+		}
+
+		// Record the installation in the keg
+		l.Debug("Writing install receipt")
+		return action.writeReceipt(ctx, f, keg)
+	})
 	if err != nil {
 		return err
 	}
 
 	// 3. Link keg to the prefix
-	if !f.IsKegOnly() || action.Force {
+	release, err := action.lockLinks(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if action.links(f) {
 		l.Info("Linking keg", slog.String("keg", action.Prefix().FormulaKegPath(f))) // ex: Linking cowsay
 
 		lnopts := &prefix.LinkOptions{
 			Name:      f.Name(),
-			Overwrite: action.Overwrite,
+			Overwrite: action.Overwrite || action.overwrite[f.Name()],
 			DryRun:    action.DryRun,
+			Recorder:  tx,
 		}
 
 		_, _, err = action.Prefix().FormulaLink(f, lnopts)
 		if err != nil {
 			return err
 		}
+	} else {
+		// Unlinked formulae are still linked into opt for their dependents
+		l.Debug("Linking unlinked formula into opt")
+		err = action.Prefix().OptLink(f.Name(), formula.PkgVersion(f), &symlink.Options{
+			Overwrite: true,
+			Recorder:  tx,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// writeReceipt writes the install receipt for a poured keg.
+func (action *Install) writeReceipt(ctx context.Context, f formula.PlatformFormula, keg string) error {
+	l := slog.Default().With(slog.String("formula", f.Name()))
+
+	// Use the receipt included in the bottle as the base
+	base, err := receipt.Load(keg)
+	if err != nil {
+		l.Warn("Ignoring bottle install receipt", logutil.ErrAttr(err))
+	}
+
+	reg, err := action.BottleRegistry()
+	if err != nil {
+		return err
+	}
+
+	// The receipt is still usable without the Tab
+	t, err := bottle.FetchTab(ctx, reg, f)
+	if err != nil {
+		l.Warn("Could not fetch bottle tab", logutil.ErrAttr(err))
+	}
+
+	info := &brewv1.PlatformInfo{
+		Name:     f.Name(),
+		Versions: brewv1.Versions{Stable: f.Version().Upstream()},
+	}
+	if src, ok := f.(formula.PlatformV1); ok {
+		info = src.SourceV1()
+	}
+
+	requested := slices.Contains(action.requested, f.Name())
+	return receipt.NewInstallReceipt(base, info, t, requested, action.sourcePath(info), action.Version()).Write(keg)
+}
+
+// sourcePath produces the path to the formula's Ruby source in its tap,
+// or an empty string if the formula has no tap.
+func (action *Hops) sourcePath(info *brewv1.PlatformInfo) string {
+	if user, repo, ok := strings.Cut(info.Tap, "/"); ok && info.RubySourcePath != "" {
+		return filepath.Join(action.Prefix().Taps(), user, "homebrew-"+repo, info.RubySourcePath)
+	}
+	return ""
+}
+
 func printFormulae(roots []string, dryrun bool) {
 	fword := "formulae"
 	flist := wordwrap.String(strings.Join(roots, " "), o.Width)
@@ -1,13 +1,36 @@
 package actions
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/sourcegraph/conc/iter"
 
 	brewenv "github.com/act3-ai/hops/internal/apis/config.brew.sh"
 	hopsv1 "github.com/act3-ai/hops/internal/apis/config.hops.io/v1beta1"
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	brewreg "github.com/act3-ai/hops/internal/brew/registry"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/formula/bottle"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/iterutil"
 )
 
 func BenchmarkInstall(b *testing.B) {
@@ -44,3 +67,184 @@ func BenchmarkInstall(b *testing.B) {
 		}
 	}
 }
+
+func TestInstallDigestMismatch(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	content := benchBottle(t, "foo", 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	// The served bottle does not match the digest in the formula
+	sum := sha256.Sum256([]byte("other content"))
+	f := formula.PlatformFromV1(platform.X8664Linux, &brewv1.PlatformInfo{
+		Name:     "foo",
+		Versions: brewv1.Versions{Stable: "1.0"},
+		Bottle: map[string]*brewv1.Bottle{
+			brewv1.Stable: {
+				RootURL: srv.URL,
+				Files: map[platform.Platform]*brewv1.BottleFile{
+					platform.X8664Linux: {Cellar: ":any_skip_relocation", Sha256: hex.EncodeToString(sum[:])},
+				},
+			},
+		},
+	})
+
+	action := &Install{
+		Hops: &Hops{
+			version: "test",
+			cfg: &hopsv1.Configuration{
+				Prefix: filepath.Join(tmp, "HOMEBREW_PREFIX"),
+				Cache:  filepath.Join(tmp, "HOPS_CACHE"),
+				Homebrew: brewenv.Configuration{
+					Cache: filepath.Join(tmp, "HOMEBREW_CACHE"),
+				},
+			},
+		},
+	}
+	action.brewregistry = brewreg.NewBottleRegistry(nil, srv.Client(), action.Config().Homebrew.Cache, 1, "", "")
+
+	tx, err := action.Prefix().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	btl, err := bottle.Fetch(ctx, action.brewregistry, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = errors.Join(action.run(ctx, tx, f, btl), btl.Close())
+	if err == nil {
+		t.Fatal("Install.run() succeeded with a mismatched digest")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{action.Prefix().FormulaKegPath(f), action.Prefix().OptRecord("foo")} {
+		if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists after rollback, err = %v", path, err)
+		}
+	}
+}
+
+// benchRegistry serves generated bottles at a limited rate to simulate downloads.
+type benchRegistry struct {
+	bottles map[string][]byte
+}
+
+// FetchBottle implements bottle.Registry.
+func (reg *benchRegistry) FetchBottle(_ context.Context, f formula.PlatformFormula) (io.ReadCloser, error) {
+	return io.NopCloser(&slowReader{r: bytes.NewReader(reg.bottles[f.Name()])}), nil
+}
+
+// FetchBottles implements bottle.ConcurrentRegistry by downloading all bottles before returning.
+func (reg *benchRegistry) FetchBottles(ctx context.Context, formulae []formula.PlatformFormula) ([]io.ReadCloser, error) {
+	fetchers := iter.Mapper[formula.PlatformFormula, io.ReadCloser]{}
+	return fetchers.MapErr(formulae, func(fp *formula.PlatformFormula) (io.ReadCloser, error) {
+		r, err := reg.FetchBottle(ctx, *fp)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		return io.NopCloser(bytes.NewReader(data)), err
+	})
+}
+
+// slowReader reads in small chunks with a delay to simulate network transfer.
+type slowReader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader.
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return s.r.Read(p[:min(len(p), 16<<10)])
+}
+
+// benchBottle creates a gzipped bottle archive for a keg.
+func benchBottle(b testing.TB, name string, size int) []byte {
+	b.Helper()
+	data := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(int64(len(name)))).Read(data) //nolint:gosec
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, hdr := range []*tar.Header{
+		{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/1.0/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/1.0/bin/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/1.0/bin/" + name, Typeflag: tar.TypeReg, Mode: 0o755, Size: int64(size)},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := tw.Write(data); err != nil {
+		b.Fatal(err)
+	}
+	if err := errors.Join(tw.Close(), gzw.Close()); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkPourBottles compares downloading all bottles before pouring them
+// to streaming each bottle into the Cellar as it downloads.
+func BenchmarkPourBottles(b *testing.B) {
+	// Four chains of four formulae, each depending on the previous
+	reg := &benchRegistry{bottles: map[string][]byte{}}
+	formulae := []formula.PlatformFormula{}
+	for chain := range 4 {
+		for link := range 4 {
+			name := fmt.Sprintf("chain%d-link%d", chain, link)
+			deps := []string{}
+			if link > 0 {
+				deps = append(deps, fmt.Sprintf("chain%d-link%d", chain, link-1))
+			}
+			formulae = append(formulae, formula.PlatformFromV1(platform.X8664Linux, &brewv1.PlatformInfo{
+				Name:         name,
+				Versions:     brewv1.Versions{Stable: "1.0"},
+				Dependencies: deps,
+			}))
+			reg.bottles[name] = benchBottle(b, name, 256<<10)
+		}
+	}
+	tags := &formula.DependencyTags{}
+	routines := runtime.NumCPU()
+
+	b.Run("FetchAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			p := prefix.Prefix(filepath.Join(b.TempDir(), strconv.Itoa(i)))
+			bottles, err := bottle.FetchAll(context.Background(), reg, formulae)
+			if err != nil {
+				b.Fatal(err)
+			}
+			iterator := iter.Iterator[formula.PlatformFormula]{MaxGoroutines: routines}
+			err = iterutil.ForEachIdxErr(iterator, formulae, func(i int, _ *formula.PlatformFormula) error {
+				return p.Pour(bottles[i])
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Stream", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			p := prefix.Prefix(filepath.Join(b.TempDir(), strconv.Itoa(i)))
+			err := pourAll(context.Background(), formulae, tags, routines, func(ctx context.Context, f formula.PlatformFormula) error {
+				btl, err := bottle.Fetch(ctx, reg, f)
+				if err != nil {
+					return err
+				}
+				return errors.Join(p.Pour(btl), btl.Close())
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -19,19 +19,23 @@ type Leaves struct {
 
 // Run runs the action.
 func (action *Leaves) Run(ctx context.Context) error {
-	// List all racks
-	kegs, err := action.Prefix().Kegs()
+	leaves, err := action.leaves(ctx)
 	if err != nil {
 		return err
 	}
 
-	kegNames := formula.Names(kegs)
-	slices.Sort(kegNames)
-	kegNames = slices.Compact(kegNames)
+	for _, f := range leaves {
+		fmt.Println(f.Name())
+	}
 
-	formulae, err := action.fetchFromArgs(ctx, kegNames, platform.SystemPlatform())
+	return nil
+}
+
+// leaves lists the installed formulae that are not dependencies of another installed formula.
+func (action *Hops) leaves(ctx context.Context) ([]formula.PlatformFormula, error) {
+	formulae, err := action.installedFormulae(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	foundDependents := []string{}
@@ -51,12 +55,23 @@ func (action *Leaves) Run(ctx context.Context) error {
 		}
 	}
 
-	// Iterate over every installed formula, if it is not a dependency, print the name
-	for _, f := range formulae {
-		if !slices.Contains(foundDependents, f.Name()) {
-			fmt.Println(f.Name())
-		}
+	// Every installed formula that is not a dependency is a leaf
+	return slices.DeleteFunc(formulae, func(f formula.PlatformFormula) bool {
+		return slices.Contains(foundDependents, f.Name())
+	}), nil
+}
+
+// installedFormulae fetches every installed formula.
+func (action *Hops) installedFormulae(ctx context.Context) ([]formula.PlatformFormula, error) {
+	// List all racks
+	kegs, err := action.Prefix().Kegs()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	kegNames := formula.Names(kegs)
+	slices.Sort(kegNames)
+	kegNames = slices.Compact(kegNames)
+
+	return action.fetchFromArgs(ctx, kegNames, platform.SystemPlatform())
 }
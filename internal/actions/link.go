@@ -54,6 +54,14 @@ func (action *Link) Run(ctx context.Context, args []string) error {
 		}
 	}
 
+	if !action.DryRun {
+		release, err := action.lockPrefix(ctx, formula.Names(formulae)...)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
 	for _, f := range formulae {
 		if action.DryRun {
 			o.Hai(fmt.Sprintf("Would create the following links for %s:", f.Name()))
@@ -124,6 +132,12 @@ func (action *Unlink) Run(ctx context.Context, args ...string) error {
 		return nil
 	}
 
+	release, err := action.lockPrefix(ctx, formula.Names(formulae)...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	for _, l := range links {
 		err = os.Remove(l)
 		if err != nil {
@@ -154,5 +168,5 @@ func (action *Hops) resolve(ctx context.Context, args []string, plat platform.Pl
 		return nil, err
 	}
 
-	return dependencies.Walk(ctx, formulary, formulae, plat, tags)
+	return dependencies.Walk(ctx, formulary, formulae, plat, tags, action.MaxGoroutines())
 }
@@ -3,8 +3,10 @@ package actions
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/o"
 	"github.com/act3-ai/hops/internal/platform"
 	"github.com/act3-ai/hops/internal/prefix"
@@ -30,10 +32,10 @@ type List struct {
 	// installed
 	Multiple bool
 
-	// // List only pinned formulae, or only the
-	// // specified (pinned) formulae if formula are
-	// // provided. See also pin, unpin
-	// Pinned bool
+	// List only pinned formulae, or only the
+	// specified (pinned) formulae if formula are
+	// provided. See also pin, unpin
+	Pinned bool
 
 	// // Force output to be one entry per line. This
 	// // is the default when output is not to a
@@ -61,6 +63,8 @@ type List struct {
 // Run runs the action.
 func (action *List) Run(ctx context.Context, names ...string) error {
 	switch {
+	case action.Pinned:
+		return action.pinned(ctx, names)
 	case len(names) > 0:
 		return action.names(ctx, names)
 	case action.Multiple:
@@ -132,3 +136,35 @@ func (action *List) names(ctx context.Context, args []string) error {
 
 	return nil
 }
+
+// pinned lists pinned formulae, or only the given formulae that are pinned.
+func (action *List) pinned(ctx context.Context, args []string) error {
+	names, err := action.Prefix().PinnedFormulae()
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		formulae, err := action.fetchFromArgs(ctx, args, platform.SystemPlatform())
+		if err != nil {
+			return err
+		}
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return !slices.Contains(formula.Names(formulae), name)
+		})
+	}
+
+	for _, name := range names {
+		if !action.Versions {
+			fmt.Println(name)
+			continue
+		}
+		keg, err := action.Prefix().PinnedKeg(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name + " " + keg.Version())
+	}
+
+	return nil
+}
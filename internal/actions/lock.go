@@ -0,0 +1,284 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/conc/iter"
+
+	"github.com/act3-ai/hops/internal/brewfile"
+	"github.com/act3-ai/hops/internal/errdef"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/hops/regbottle"
+	hopsreg "github.com/act3-ai/hops/internal/hops/registry"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Lock represents the action and its options.
+type Lock struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+
+	File   string // path to the Brewfile to lock
+	Update bool   // resolve the newest bottles instead of keeping locked versions
+}
+
+// Run runs the action.
+func (action *Lock) Run(ctx context.Context) error {
+	bf, err := brewfile.Load(action.File)
+	if err != nil {
+		return err
+	}
+	bf = bf.ForPlatform(platform.All)
+
+	path := brewfile.LockPath(action.File)
+	previous, err := brewfile.LoadLockIfExists(path)
+	if err != nil {
+		return err
+	}
+
+	// Keep the locked versions unless updating
+	if previous != nil && !action.Update {
+		action.useLock(previous)
+	}
+
+	// Without a registry, bottles are locked by the digests in the Homebrew API
+	var reg hopsreg.Registry
+	if action.Config().Registry.Prefix != "" {
+		reg, err = hopsRegistry(&action.Config().Registry, action.UserAgent())
+		if err != nil {
+			return err
+		}
+	}
+	formulary, err := action.Formulary(ctx)
+	if err != nil {
+		return err
+	}
+
+	o.H1("Resolving dependencies...")
+	graph, err := action.Hops.resolve(ctx, bf.Formula, platform.All, &action.DependencyOptions)
+	if err != nil {
+		return err
+	}
+	formulae := slices.Concat(graph.Dependencies(), graph.Roots())
+
+	lock := brewfile.NewLock()
+	mu := sync.Mutex{}
+	mapper := iter.Mapper[formula.PlatformFormula, struct{}]{MaxGoroutines: action.MaxGoroutines()}
+	_, err = mapper.MapErr(formulae, func(f *formula.PlatformFormula) (struct{}, error) {
+		var locked *brewfile.LockedFormula
+		var err error
+		if reg != nil {
+			locked, err = lockFormula(ctx, reg, *f)
+		} else {
+			locked, err = lockAPIFormula(ctx, formulary, *f)
+		}
+		if err != nil {
+			return struct{}{}, fmt.Errorf("[%s] %w", (*f).Name(), err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		lock.Entries.Brew[(*f).Name()] = locked
+		return struct{}{}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	printLockChanges(previous, lock)
+
+	if err := lock.Write(path); err != nil {
+		return err
+	}
+	o.Hai(fmt.Sprintf("Locked %d formulae in %s", len(lock.Entries.Brew), path))
+	return nil
+}
+
+// lockFormula resolves the bottles of a formula in the registry.
+func lockFormula(ctx context.Context, reg hopsreg.Registry, f formula.Formula) (*brewfile.LockedFormula, error) {
+	repo, err := reg.Repository(ctx, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	btl, err := regbottle.ResolveVersion(ctx, repo, formula.Tag(f))
+	if err != nil {
+		return nil, err
+	}
+
+	plats, err := btl.Platforms(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := &brewfile.LockedFormula{
+		Version: formula.PkgVersion(f),
+		Tag:     formula.Tag(f),
+		Digest:  btl.Digest.String(),
+		Bottles: make(map[platform.Platform]*brewfile.LockedBottle, len(plats)),
+	}
+	for _, plat := range plats {
+		manifest, err := btl.ResolveManifest(ctx, repo, plat)
+		if err != nil {
+			return nil, err
+		}
+		blob, err := btl.ResolveBottle(ctx, repo, plat)
+		if err != nil {
+			return nil, err
+		}
+		locked.Bottles[plat] = &brewfile.LockedBottle{
+			Manifest: manifest.Digest.String(),
+			Blob:     blob.Digest.String(),
+		}
+	}
+
+	return locked, nil
+}
+
+// lockAPIFormula records the bottle digests of a formula from the Homebrew API.
+func lockAPIFormula(ctx context.Context, formulary formula.Formulary, f formula.Formula) (*brewfile.LockedFormula, error) {
+	mf, err := formulary.FetchFormula(ctx, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	locked := &brewfile.LockedFormula{
+		Version: formula.PkgVersion(f),
+		Bottles: map[platform.Platform]*brewfile.LockedBottle{},
+	}
+	for _, plat := range platform.SupportedPlatforms {
+		pf, err := mf.ForPlatform(plat)
+		if err != nil {
+			return nil, err
+		}
+		btl := pf.Bottle()
+		if btl == nil || btl.Sha256 == "" {
+			continue
+		}
+		// Platforms without their own bottle share the "all" bottle
+		locked.Bottles[btl.Platform] = &brewfile.LockedBottle{Blob: "sha256:" + btl.Sha256}
+	}
+
+	return locked, nil
+}
+
+// printLockChanges prints the formulae added to, changed in, and removed from the lock.
+func printLockChanges(previous, lock *brewfile.Lock) {
+	if previous == nil {
+		return
+	}
+
+	lines := []string{}
+	for _, name := range lock.Formulae() {
+		old, ok := previous.Entries.Brew[name]
+		switch {
+		case !ok:
+			lines = append(lines, "+ "+name+" "+lock.Entries.Brew[name].Version)
+		case old.Version != lock.Entries.Brew[name].Version, old.Digest != lock.Entries.Brew[name].Digest:
+			lines = append(lines, fmt.Sprintf("~ %s %s -> %s", name, old.Version, lock.Entries.Brew[name].Version))
+		}
+	}
+	for _, name := range previous.Formulae() {
+		if _, ok := lock.Entries.Brew[name]; !ok {
+			lines = append(lines, "- "+name+" "+previous.Entries.Brew[name].Version)
+		}
+	}
+
+	if len(lines) > 0 {
+		o.Hai("Changes to the lock file:\n" + strings.Join(lines, "\n"))
+	}
+}
+
+// loadLock loads a lock file and makes formulae resolve to its bottles.
+func (action *Hops) loadLock(path string) (*brewfile.Lock, error) {
+	lock, err := brewfile.LoadLock(path)
+	if err != nil {
+		return nil, err
+	}
+	action.useLock(lock)
+	return lock, nil
+}
+
+// useLock makes formulae resolve to the bottle tags recorded in the lock,
+// unless another tag is requested.
+func (action *Hops) useLock(lock *brewfile.Lock) {
+	action.lockedTags = lock.Tags()
+}
+
+// errNoLockedIndex reports a formula locked from the Homebrew API, which has no bottle index to pin in a registry.
+func errNoLockedIndex(name string) error {
+	return fmt.Errorf("%s was locked from the Homebrew API without a bottle index, run `hops lock` with a registry configured to lock registry bottles", name)
+}
+
+// checkLockedVersion verifies that a formula resolved to its locked version.
+func checkLockedVersion(lock *brewfile.Lock, f formula.Formula) (*brewfile.LockedFormula, error) {
+	locked, err := lock.Formula(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	if version := formula.PkgVersion(f); version != locked.Version {
+		return nil, errdef.NewLockMismatchError(f.Name(), "Version", locked.Version, version)
+	}
+	return locked, nil
+}
+
+// checkLockedBottle verifies that a formula's bottle is the locked bottle for its platform.
+func checkLockedBottle(locked *brewfile.LockedFormula, f formula.PlatformFormula) error {
+	btl := f.Bottle()
+	if btl == nil || btl.Sha256 == "" {
+		return nil // verified by digest in the registry
+	}
+
+	lockedBottle, ok := locked.Bottles[f.Platform()]
+	if !ok {
+		lockedBottle, ok = locked.Bottles[btl.Platform]
+	}
+	if !ok {
+		return fmt.Errorf("no bottle for %s on %s in the lock file", f.Name(), f.Platform())
+	}
+
+	if blob := "sha256:" + btl.Sha256; blob != lockedBottle.Blob {
+		return errdef.NewLockMismatchError(f.Name(), "Bottle digest", lockedBottle.Blob, blob)
+	}
+	return nil
+}
+
+// checkLockedBottles verifies that the formula's bottle for every supported platform is the locked bottle.
+func checkLockedBottles(locked *brewfile.LockedFormula, f formula.MultiPlatformFormula) error {
+	var errs error
+	for _, plat := range platform.SupportedPlatforms {
+		pf, err := f.ForPlatform(plat)
+		if err != nil {
+			return err
+		}
+		errs = errors.Join(errs, checkLockedBottle(locked, pf))
+	}
+	return errs
+}
+
+// checkLockedIndex verifies that the formula's bottle index in the registry still has its locked digest.
+func checkLockedIndex(ctx context.Context, reg hopsreg.Registry, name string, locked *brewfile.LockedFormula) error {
+	if locked.Digest == "" {
+		return errNoLockedIndex(name)
+	}
+
+	repo, err := reg.Repository(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	desc, err := repo.Resolve(ctx, locked.Tag)
+	if err != nil {
+		return fmt.Errorf("resolving locked tag %s for %s: %w", locked.Tag, name, err)
+	}
+
+	if desc.Digest.String() != locked.Digest {
+		return errdef.NewLockMismatchError(name, "Bottle index digest", locked.Digest, desc.Digest.String())
+	}
+	return nil
+}
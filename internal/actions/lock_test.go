@@ -0,0 +1,119 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/brewfile"
+	"github.com/act3-ai/hops/internal/errdef"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// testAPIFormulary serves formulae from the Homebrew API by name, with old names keyed to the renamed formula.
+type testAPIFormulary map[string]*brewv1.Info
+
+// FetchFormula implements formula.Formulary.
+func (store testAPIFormulary) FetchFormula(_ context.Context, name string) (formula.MultiPlatformFormula, error) {
+	info, ok := store[name]
+	if !ok {
+		return nil, errdef.NewFormulaNotFoundError(name)
+	}
+	return formula.FromV1(info), nil
+}
+
+// ListNames implements brewformulary.PreloadedFormulary.
+func (store testAPIFormulary) ListNames() []string {
+	return slices.Sorted(maps.Keys(store))
+}
+
+// Renames implements brewformulary.PreloadedFormulary.
+func (store testAPIFormulary) Renames() map[string]string {
+	renames := map[string]string{}
+	for name, info := range store {
+		if name != info.Name {
+			renames[name] = info.Name
+		}
+	}
+	return renames
+}
+
+// testAPIInfo creates Homebrew API metadata for a formula with bottles for the given platforms.
+func testAPIInfo(name, version string, files map[platform.Platform]string) *brewv1.Info {
+	bottle := &brewv1.Bottle{Files: map[platform.Platform]*brewv1.BottleFile{}}
+	for plat, sha := range files {
+		bottle.Files[plat] = &brewv1.BottleFile{Sha256: sha}
+	}
+	return &brewv1.Info{PlatformInfo: brewv1.PlatformInfo{
+		Name:     name,
+		Versions: brewv1.Versions{Stable: version, Bottle: true},
+		Bottle:   map[string]*brewv1.Bottle{brewv1.Stable: bottle},
+	}}
+}
+
+func TestLockAPIFormula(t *testing.T) {
+	ctx := context.Background()
+	store := testAPIFormulary{
+		"foo": testAPIInfo("foo", "1.0", map[platform.Platform]string{
+			platform.X8664Linux:  "linux",
+			platform.Arm64Sonoma: "sonoma",
+		}),
+		"bar": testAPIInfo("bar", "2.0", map[platform.Platform]string{
+			platform.All: "all",
+		}),
+	}
+
+	t.Run("platform bottles", func(t *testing.T) {
+		f, err := store["foo"].ForPlatform(platform.X8664Linux)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locked, err := lockAPIFormula(ctx, store, formula.PlatformFromV1(platform.X8664Linux, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked.Version != "1.0" || locked.Digest != "" || len(locked.Bottles) != 2 {
+			t.Fatalf("lockAPIFormula() = %+v, want version 1.0 with 2 bottles and no index", locked)
+		}
+		for plat, want := range map[platform.Platform]string{platform.X8664Linux: "sha256:linux", platform.Arm64Sonoma: "sha256:sonoma"} {
+			if got := locked.Bottles[plat]; got == nil || got.Blob != want {
+				t.Errorf("bottle for %s = %+v, want %s", plat, got, want)
+			}
+		}
+
+		// The locked bottles verify until a bottle changes
+		if err := checkLockedBottles(locked, formula.FromV1(store["foo"])); err != nil {
+			t.Errorf("checkLockedBottles() = %v", err)
+		}
+		store["foo"].Bottle[brewv1.Stable].Files[platform.Arm64Sonoma].Sha256 = "rebuilt"
+		var mismatch errdef.LockMismatchError
+		if err := checkLockedBottles(locked, formula.FromV1(store["foo"])); !errors.As(err, &mismatch) {
+			t.Errorf("checkLockedBottles() = %v, want a lock mismatch", err)
+		}
+	})
+
+	t.Run("all bottle", func(t *testing.T) {
+		f := formula.PlatformFromV1(platform.X8664Linux, &store["bar"].PlatformInfo)
+		locked, err := lockAPIFormula(ctx, store, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := locked.Bottles[platform.All]; len(locked.Bottles) != 1 || got == nil || got.Blob != "sha256:all" {
+			t.Fatalf("lockAPIFormula() bottles = %v, want only the all bottle", locked.Bottles)
+		}
+		if err := checkLockedBottle(locked, f); err != nil {
+			t.Errorf("checkLockedBottle() = %v", err)
+		}
+	})
+
+	t.Run("registry index", func(t *testing.T) {
+		locked := &brewfile.LockedFormula{Version: "1.0"}
+		if err := checkLockedIndex(ctx, nil, "foo", locked); err == nil {
+			t.Error("checkLockedIndex() verified a formula locked without a bottle index")
+		}
+	})
+}
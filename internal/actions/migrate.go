@@ -0,0 +1,285 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+// Migrate represents the action and its options.
+type Migrate struct {
+	*Hops
+
+	DryRun bool // Show what would be migrated, but do not actually migrate anything
+}
+
+// Run runs the action.
+func (action *Migrate) Run(ctx context.Context, args ...string) error {
+	names := action.SetAlternateTags(args)
+	store, err := action.Formulary(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Fetch each name on its own to know which names were requested for each formula
+	formulae := []formula.PlatformFormula{}
+	requested := map[string][]string{}
+	for _, name := range names {
+		f, err := formula.FetchPlatform(ctx, store, name, platform.SystemPlatform())
+		if err != nil {
+			return err
+		}
+		if _, ok := requested[f.Name()]; !ok {
+			formulae = append(formulae, f)
+		}
+		requested[f.Name()] = append(requested[f.Name()], name)
+	}
+
+	var errs error
+	for _, f := range formulae {
+		oldNames := action.installedOldNames(f, requested[f.Name()]...)
+		switch {
+		case len(oldNames) > 0:
+		case len(f.Info().OldNames) == 0 && !slices.ContainsFunc(requested[f.Name()], func(name string) bool { return name != f.Name() }):
+			errs = errors.Join(errs, errors.New(f.Name()+" doesn't replace any formula"))
+			continue
+		default:
+			errs = errors.Join(errs, errors.New(f.Name()+" doesn't replace any installed formula"))
+			continue
+		}
+
+		for _, oldName := range oldNames {
+			errs = errors.Join(errs, action.migrate(ctx, f, oldName))
+		}
+	}
+
+	return errs
+}
+
+// installedOldNames lists the old names of the formula that are installed in
+// their own rack, including the names it was requested by.
+func (action *Migrate) installedOldNames(f formula.PlatformFormula, requested ...string) []string {
+	names := slices.Clone(f.Info().OldNames)
+	for _, name := range requested {
+		if name != f.Name() && !slices.Contains(names, name) {
+			names = append(names, name) // requested by an old name that is not listed
+		}
+	}
+
+	installed := []string{}
+	for _, name := range names {
+		// Racks that were already migrated link to the new rack
+		info, err := os.Lstat(filepath.Join(action.Prefix().Cellar(), name))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if kegs, err := action.Prefix().InstalledKegsByName(name); err == nil && len(kegs) > 0 {
+			installed = append(installed, name)
+		}
+	}
+	return installed
+}
+
+// migrate moves the rack of an old name to the formula's name and relinks it.
+func (action *Migrate) migrate(ctx context.Context, f formula.PlatformFormula, oldName string) error {
+	p := action.Prefix()
+	name := f.Name()
+
+	release, err := action.lockPrefix(ctx, oldName, name)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	kegs, err := p.InstalledKegsByName(oldName)
+	if err != nil {
+		return err
+	}
+
+	// Versions installed under both names cannot be merged
+	for _, k := range kegs {
+		if _, err := os.Lstat(p.KegPath(name, k.Version())); err == nil {
+			return fmt.Errorf("%s %s is installed as both %s and %s, uninstall one of them to migrate", name, k.Version(), oldName, name)
+		}
+	}
+
+	if action.DryRun {
+		o.Hai(fmt.Sprintf("Would migrate %s to %s", oldName, name))
+		for _, k := range kegs {
+			fmt.Printf("Would move: %s -> %s\n", k, p.KegPath(name, k.Version()))
+		}
+		return nil
+	}
+
+	o.Hai(fmt.Sprintf("Migrating formula %s to %s", oldName, name))
+
+	// Put everything back under the old name if the migration fails
+	tx, err := p.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = action.migrateKegs(tx, f, oldName, kegs)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// migrateKegs moves the kegs of an old name into the formula's rack and relinks them,
+// along with the records of the old name.
+func (action *Migrate) migrateKegs(tx *prefix.Transaction, f formula.PlatformFormula, oldName string, kegs []prefix.Keg) error {
+	p := action.Prefix()
+	name := f.Name()
+	oldRack := filepath.Join(p.Cellar(), oldName)
+	newRack := filepath.Join(p.Cellar(), name)
+
+	linked, err := p.LinkedKeg(oldName)
+	if err != nil {
+		return err
+	}
+	opt, err := p.OptKeg(oldName)
+	if err != nil {
+		return err
+	}
+	pinned, err := p.PinnedKeg(oldName)
+	if err != nil {
+		return err
+	}
+
+	// Remove the links into the old kegs, including their records
+	paths := make([]string, 0, len(kegs))
+	for _, k := range kegs {
+		paths = append(paths, k.String())
+	}
+	links, err := p.Unlink(tx, paths...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Unlinking %s... %d symlinks removed.\n", oldName, len(links))
+
+	// Move the kegs into the new rack
+	o.Hai(fmt.Sprintf("Moving %s versions to %s", oldName, newRack))
+	for _, k := range kegs {
+		if err := tx.Move(k.String(), p.KegPath(name, k.Version())); err != nil {
+			return err
+		}
+		if err := action.migrateReceipt(tx, p.KegPath(name, k.Version()), f); err != nil {
+			return err
+		}
+	}
+	// Rolling back the moves restores the rack
+	if err := os.Remove(oldRack); err != nil {
+		return fmt.Errorf("removing rack: %w", err)
+	}
+
+	// Paths under the old name keep working through a link to the new rack
+	if err := symlink.Relative(newRack, oldRack, &symlink.Options{Recorder: tx}); err != nil {
+		return err
+	}
+
+	active, err := action.relink(tx, name, kegs, linked, opt)
+	if err != nil {
+		return err
+	}
+
+	// Dependents built against the old name find the keg through its opt record
+	err = symlink.Relative(active.String(), p.OptRecord(oldName), &symlink.Options{Overwrite: true, Recorder: tx})
+	if err != nil {
+		return err
+	}
+
+	// Move the pin to the new name
+	if pinned != "" {
+		err := symlink.Relative(p.KegPath(name, pinned.Version()), p.PinRecord(name), &symlink.Options{
+			MkdirParent: true,
+			Overwrite:   true,
+			Recorder:    tx,
+		})
+		if err != nil {
+			return fmt.Errorf("pinning %s: %w", name, err)
+		}
+		if err := tx.RemoveLink(p.PinRecord(oldName)); err != nil {
+			return fmt.Errorf("unpinning %s: %w", oldName, err)
+		}
+	}
+
+	return nil
+}
+
+// relink links the migrated keg that was linked under the old name, returning the keg in use.
+//
+// Kegs already installed under the new name stay in use.
+func (action *Migrate) relink(tx *prefix.Transaction, name string, kegs []prefix.Keg, linked, opt prefix.Keg) (prefix.Keg, error) {
+	p := action.Prefix()
+
+	current, err := p.OptKeg(name)
+	if err != nil {
+		return "", err
+	}
+	if current != "" {
+		if _, err := os.Stat(current.String()); err == nil {
+			return current, nil
+		}
+	}
+
+	// Use the keg that was linked, or else the one in opt, or else the newest
+	active := kegs[len(kegs)-1]
+	switch {
+	case linked != "":
+		active = linked
+	case opt != "":
+		active = opt
+	}
+	keg := prefix.Keg(p.KegPath(name, active.Version()))
+
+	// Keg-only formulae are only linked into opt
+	if linked == "" {
+		return keg, p.OptLink(name, keg.Version(), &symlink.Options{Overwrite: true, Recorder: tx})
+	}
+
+	o.Hai("Linking " + name)
+	created, _, err := p.Link(name, keg.Version(), &prefix.LinkOptions{Name: name, Recorder: tx})
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Linking %s... %d symlinks created.\n", keg, created)
+	return keg, nil
+}
+
+// migrateReceipt rewrites the install receipt of a migrated keg for the formula's name.
+// The previous receipt is restored if the Transaction is rolled back.
+func (action *Migrate) migrateReceipt(tx *prefix.Transaction, keg string, f formula.PlatformFormula) error {
+	r, err := receipt.Load(keg)
+	switch {
+	case err != nil:
+		return err
+	case r == nil:
+		return nil // no receipt to rewrite
+	}
+
+	r.Aliases = slices.Clone(f.Info().Aliases)
+	if r.Aliases == nil {
+		r.Aliases = []string{}
+	}
+	if src, ok := f.(formula.PlatformV1); ok {
+		if path := action.sourcePath(src.SourceV1()); path != "" {
+			r.Source.Path = path
+		}
+	}
+	if err := tx.Backup(filepath.Join(keg, receipt.InstallReceiptFile)); err != nil {
+		return err
+	}
+	return r.Write(keg)
+}
@@ -0,0 +1,218 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+)
+
+// testMigrate creates a Migrate action for a prefix with "foo" 1.0 pinned and 2.0 linked,
+// and the formula "foo" was renamed to.
+func testMigrate(t *testing.T) (*Migrate, formula.PlatformFormula) {
+	t.Helper()
+	action := &Migrate{Hops: testHops(t)}
+	p := action.Prefix()
+	makeKegs(t, p, "foo", "1.0", "2.0")
+	for _, v := range []string{"1.0", "2.0"} {
+		r := &receipt.InstallReceipt{Aliases: []string{"foo"}}
+		if err := r.Write(p.KegPath("foo", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := p.Link("foo", "2.0", &prefix.LinkOptions{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	makeRecord(t, p, p.PinRecord("foo"), "foo", "1.0")
+
+	f := formula.PlatformFromV1(platform.X8664Linux, &brewv1.PlatformInfo{
+		Name:     "bar",
+		OldNames: []string{"foo"},
+		Aliases:  []string{"bar-alias"},
+		Versions: brewv1.Versions{Stable: "2.0"},
+	})
+	return action, f
+}
+
+// assertRecords checks the keg each record of the named formula points to.
+func assertRecords(t *testing.T, p prefix.Prefix, name string, opt, linked, pinned string) {
+	t.Helper()
+	for _, r := range []struct {
+		record func(string) (prefix.Keg, error)
+		want   string
+	}{
+		{p.OptKeg, opt},
+		{p.LinkedKeg, linked},
+		{p.PinnedKeg, pinned},
+	} {
+		got, err := r.record(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != r.want {
+			t.Errorf("%s record points to %q, want %q", name, got, r.want)
+		}
+	}
+}
+
+// assertLinked checks that the executable in the prefix links into the keg.
+func assertLinked(t *testing.T, p prefix.Prefix, name, keg string) {
+	t.Helper()
+	got, err := filepath.EvalSymlinks(filepath.Join(p.String(), "bin", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := filepath.EvalSymlinks(filepath.Join(keg, "bin", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("%s links to %s, want %s", name, got, want)
+	}
+}
+
+// assertAliases checks the aliases in the install receipt of a keg.
+func assertAliases(t *testing.T, keg string, want ...string) {
+	t.Helper()
+	r, err := receipt.Load(keg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || !slices.Equal(r.Aliases, want) {
+		t.Errorf("receipt of %s = %+v, want aliases %v", keg, r, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("migrate", func(t *testing.T) {
+		action, f := testMigrate(t)
+		p := action.Prefix()
+		if err := action.migrate(ctx, f, "foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The old rack links to the new rack
+		if target, err := os.Readlink(filepath.Join(p.Cellar(), "foo")); err != nil || target != "bar" {
+			t.Errorf("old rack links to %q, %v, want %q", target, err, "bar")
+		}
+		assertExist(t, p.KegPath("bar", "1.0"), p.KegPath("bar", "2.0"))
+
+		keg := p.KegPath("bar", "2.0")
+		assertRecords(t, p, "bar", keg, keg, p.KegPath("bar", "1.0"))
+		assertRecords(t, p, "foo", keg, "", "")
+		assertRecords(t, p, "bar-alias", keg, "", "")
+		assertLinked(t, p, "foo", keg)
+		assertAliases(t, keg, "bar-alias")
+		assertFinished(t, p)
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		action, f := testMigrate(t)
+		action.DryRun = true
+		p := action.Prefix()
+		if err := action.migrate(ctx, f, "foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		assertNotExist(t, filepath.Join(p.Cellar(), "bar"))
+		assertRecords(t, p, "foo", p.KegPath("foo", "2.0"), p.KegPath("foo", "2.0"), p.KegPath("foo", "1.0"))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		action, f := testMigrate(t)
+		p := action.Prefix()
+		// Moving the pin fails when the record cannot be overwritten
+		if err := os.MkdirAll(filepath.Join(p.PinRecord("bar"), "dir"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := action.migrate(ctx, f, "foo"); err == nil {
+			t.Fatal("migrate() succeeded")
+		}
+
+		// Everything is back under the old name
+		keg := p.KegPath("foo", "2.0")
+		if info, err := os.Lstat(filepath.Join(p.Cellar(), "foo")); err != nil || !info.IsDir() {
+			t.Errorf("old rack is not a directory: %v", err)
+		}
+		assertExist(t, p.KegPath("foo", "1.0"), keg)
+		assertNotExist(t, p.KegPath("bar", "1.0"), p.KegPath("bar", "2.0"), p.OptRecord("bar"), p.OptRecord("bar-alias"), p.LinkedKegRecord("bar"))
+		assertRecords(t, p, "foo", keg, keg, p.KegPath("foo", "1.0"))
+		assertLinked(t, p, "foo", keg)
+		assertAliases(t, keg, "foo")
+		assertAliases(t, p.KegPath("foo", "1.0"), "foo")
+		assertFinished(t, p)
+	})
+
+	t.Run("installed as both", func(t *testing.T) {
+		action, f := testMigrate(t)
+		p := action.Prefix()
+		makeKegs(t, p, "bar", "2.0")
+		if err := action.migrate(ctx, f, "foo"); err == nil {
+			t.Fatal("migrate() merged kegs of the same version")
+		}
+		assertExist(t, p.KegPath("foo", "2.0"))
+	})
+}
+
+func TestMigrate_Run(t *testing.T) {
+	ctx := context.Background()
+	// The old name is only known from the renames
+	info := &brewv1.Info{PlatformInfo: brewv1.PlatformInfo{Name: "bar", Versions: brewv1.Versions{Stable: "2.0"}}}
+
+	t.Run("old name with tag", func(t *testing.T) {
+		action, _ := testMigrate(t)
+		action.brewformulary = testAPIFormulary{"bar": info, "foo": info}
+		p := action.Prefix()
+		if err := action.Run(ctx, "bar", "foo:2.0"); err != nil {
+			t.Fatal(err)
+		}
+
+		keg := p.KegPath("bar", "2.0")
+		assertRecords(t, p, "bar", keg, keg, p.KegPath("bar", "1.0"))
+		assertRecords(t, p, "foo", keg, "", "")
+	})
+
+	t.Run("no old names", func(t *testing.T) {
+		action, _ := testMigrate(t)
+		action.brewformulary = testAPIFormulary{"bar": info}
+		if err := action.Run(ctx, "bar:2.0"); err == nil {
+			t.Fatal("Run() migrated a formula without old names")
+		}
+		assertExist(t, action.Prefix().KegPath("foo", "2.0"))
+	})
+}
+
+func TestMigrate_installedOldNames(t *testing.T) {
+	action, f := testMigrate(t)
+	p := action.Prefix()
+	makeKegs(t, p, "baz", "1.0")
+	// Already migrated racks link to the new rack
+	if err := os.Symlink("bar", filepath.Join(p.Cellar(), "qux")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		requested string
+		want      []string
+	}{
+		{"bar", []string{"foo"}},
+		{"foo", []string{"foo"}},
+		{"baz", []string{"foo", "baz"}},
+		{"qux", []string{"foo"}},
+	}
+	for _, tt := range tests {
+		if got := action.installedOldNames(f, tt.requested); !slices.Equal(got, tt.want) {
+			t.Errorf("installedOldNames(%q) = %v, want %v", tt.requested, got, tt.want)
+		}
+	}
+}
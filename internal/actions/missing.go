@@ -0,0 +1,86 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/dependencies"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Missing represents the action and its options.
+type Missing struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+}
+
+// Run runs the action.
+func (action *Missing) Run(ctx context.Context, args ...string) error {
+	plat := platform.SystemPlatform()
+
+	var formulae []formula.PlatformFormula
+	var err error
+	if len(args) == 0 {
+		formulae, err = action.installedFormulae(ctx)
+	} else {
+		formulae, err = action.fetchFromArgs(ctx, args, plat)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range formulae {
+		if !action.Prefix().AnyInstalled(f) {
+			return action.Prefix().NewErrNoSuchKeg(f.Name())
+		}
+	}
+
+	store, err := action.Formulary(ctx)
+	if err != nil {
+		return err
+	}
+
+	graph, err := dependencies.Walk(ctx, store, formulae, plat, &action.DependencyOptions, action.MaxGoroutines())
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, f := range graph.Roots() {
+		deps, err := graph.DependenciesOf(f.Name())
+		if err != nil {
+			return err
+		}
+
+		missing := []string{}
+		for _, dep := range deps {
+			kegs, err := action.Prefix().InstalledKegsByName(dep)
+			if err != nil {
+				return err
+			}
+			if len(kegs) == 0 {
+				missing = append(missing, dep)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		failed++
+		if len(formulae) > 1 {
+			fmt.Print(f.Name() + ": ")
+		}
+		fmt.Println(strings.Join(missing, " "))
+	}
+
+	if failed > 0 {
+		word := "formulae have"
+		if failed == 1 {
+			word = "formula has"
+		}
+		return fmt.Errorf("%d %s missing dependencies", failed, word)
+	}
+	return nil
+}
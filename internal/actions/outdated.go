@@ -0,0 +1,178 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/sourcegraph/conc/iter"
+
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/utils/logutil"
+)
+
+// Outdated represents the action and its options.
+type Outdated struct {
+	*Hops
+
+	// Print output in JSON format, either "v1" or "v2"
+	JSON string
+
+	// List only the names of outdated kegs
+	Quiet bool
+}
+
+// outdatedFormula is the JSON representation of an outdated formula.
+type outdatedFormula struct {
+	Name              string   `json:"name"`
+	InstalledVersions []string `json:"installed_versions"`
+	CurrentVersion    string   `json:"current_version"`
+	Pinned            bool     `json:"pinned"`
+	PinnedVersion     *string  `json:"pinned_version"`
+}
+
+// outdatedV2 is the v2 JSON representation of outdated formulae and casks.
+type outdatedV2 struct {
+	Formulae []outdatedFormula `json:"formulae"`
+	Casks    []any             `json:"casks"`
+}
+
+// Run runs the action.
+func (action *Outdated) Run(ctx context.Context, args ...string) error {
+	outdated, err := action.outdated(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	switch action.JSON {
+	// Array of outdated formulae
+	case "v1":
+		return printJSON(outdated)
+	// Outdated formulae and casks
+	case "v2":
+		return printJSON(outdatedV2{Formulae: outdated, Casks: []any{}})
+	case "":
+	default:
+		return fmt.Errorf("unsupported JSON version %q, must be one of v1, v2", action.JSON)
+	}
+
+	for _, f := range outdated {
+		switch {
+		case action.Quiet:
+			fmt.Println(f.Name)
+		case f.Pinned:
+			fmt.Printf("%s (%s) < %s [pinned at %s]\n", f.Name, strings.Join(f.InstalledVersions, ", "), f.CurrentVersion, *f.PinnedVersion)
+		default:
+			fmt.Printf("%s (%s) < %s\n", f.Name, strings.Join(f.InstalledVersions, ", "), f.CurrentVersion)
+		}
+	}
+
+	return nil
+}
+
+// outdated lists the outdated formulae, checking all installed formulae if none are named.
+//
+// Installed formulae that are missing from the formulary are skipped with a warning.
+func (action *Outdated) outdated(ctx context.Context, args []string) ([]outdatedFormula, error) {
+	names := action.SetAlternateTags(args)
+	installed := len(names) == 0
+	if installed {
+		kegs, err := action.Prefix().Kegs()
+		if err != nil {
+			return nil, err
+		}
+
+		// Sort and remove duplicates
+		names = formula.Names(kegs)
+		slices.Sort(names)
+		names = slices.Compact(names)
+	}
+
+	formulary, err := action.Formulary(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// In standalone mode, the current version is the version tagged "latest"
+	var formulae []formula.PlatformFormula
+	if installed {
+		formulae, err = action.fetchInstalled(ctx, formulary, names)
+	} else {
+		formulae, err = formula.FetchAllPlatform(ctx, formulary, names, platform.SystemPlatform())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := []outdatedFormula{}
+	for _, f := range formulae {
+		if !action.Prefix().AnyInstalled(f) {
+			return nil, action.Prefix().NewErrNoSuchKeg(f.Name())
+		}
+
+		kegs, err := action.Prefix().FormulaOutdatedKegs(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(kegs) == 0 {
+			continue
+		}
+
+		entry := outdatedFormula{
+			Name:              f.Name(),
+			InstalledVersions: make([]string, 0, len(kegs)),
+			CurrentVersion:    formula.PkgVersion(f),
+		}
+		for _, k := range kegs {
+			entry.InstalledVersions = append(entry.InstalledVersions, k.Version())
+		}
+
+		pinned, err := action.Prefix().PinnedKeg(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		if pinned != "" {
+			version := pinned.Version()
+			entry.Pinned = true
+			entry.PinnedVersion = &version
+		}
+
+		outdated = append(outdated, entry)
+	}
+
+	return outdated, nil
+}
+
+// fetchInstalled fetches the installed formulae,
+// skipping formulae that could not be fetched.
+func (action *Outdated) fetchInstalled(ctx context.Context, formulary formula.Formulary, names []string) ([]formula.PlatformFormula, error) {
+	formulae := make([]formula.PlatformFormula, len(names))
+	iterator := iter.Iterator[string]{MaxGoroutines: action.MaxGoroutines()}
+	iterator.ForEachIdx(names, func(i int, name *string) {
+		f, err := formula.FetchPlatform(ctx, formulary, *name, platform.SystemPlatform())
+		if err != nil {
+			slog.Warn("Skipping formula missing from the formulary", slog.String("formula", *name), logutil.ErrAttr(err))
+			return
+		}
+		formulae[i] = f
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(formulae, func(f formula.PlatformFormula) bool { return f == nil }), nil
+}
+
+// printJSON prints a value as indented JSON.
+func printJSON(v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
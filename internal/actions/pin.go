@@ -0,0 +1,79 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/act3-ai/hops/internal/o"
+)
+
+// Pin represents the action and its options.
+type Pin struct {
+	*Hops
+}
+
+// Run runs the action.
+func (action *Pin) Run(_ context.Context, args ...string) error {
+	var errs error
+	for _, arg := range args {
+		name, installed, err := action.installedRack(arg)
+		switch {
+		case err != nil:
+			errs = errors.Join(errs, err)
+		// Already pinned
+		case action.Prefix().Pinned(name):
+			o.Poo(name + " already pinned")
+		// Only installed formulae can be pinned
+		case !installed:
+			errs = errors.Join(errs, errors.New(name+" not installed"))
+		default:
+			errs = errors.Join(errs, action.Prefix().Pin(name))
+		}
+	}
+
+	return errs
+}
+
+// Unpin represents the action and its options.
+type Unpin struct {
+	*Hops
+}
+
+// Run runs the action.
+func (action *Unpin) Run(_ context.Context, args ...string) error {
+	var errs error
+	for _, arg := range args {
+		name, installed, err := action.installedRack(arg)
+		switch {
+		case err != nil:
+			errs = errors.Join(errs, err)
+		// Unpin
+		case action.Prefix().Pinned(name):
+			errs = errors.Join(errs, action.Prefix().Unpin(name))
+		// Only installed formulae can be unpinned
+		case !installed:
+			errs = errors.Join(errs, errors.New(name+" not installed"))
+		default:
+			o.Poo(name + " not pinned")
+		}
+	}
+
+	return errs
+}
+
+// installedRack resolves a formula name to the name of its rack in the Cellar,
+// reporting whether any keg is installed in the rack.
+func (action *Hops) installedRack(name string) (string, bool, error) {
+	// Old names of migrated formulae link to the rack of the new name
+	if target, err := os.Readlink(filepath.Join(action.Prefix().Cellar(), name)); err == nil {
+		name = filepath.Base(target)
+	}
+
+	kegs, err := action.Prefix().InstalledKegsByName(name)
+	if err != nil {
+		return "", false, err
+	}
+	return name, len(kegs) > 0, nil
+}
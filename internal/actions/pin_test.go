@@ -0,0 +1,39 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	hops := testHops(t)
+	p := hops.Prefix()
+	makeKegs(t, p, "bar", "1.0", "2.0")
+	// The old name links to the rack of the new name
+	if err := os.Symlink("bar", filepath.Join(p.Cellar(), "foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Installed formulae are pinned without consulting the formulary
+	if err := (&Pin{Hops: hops}).Run(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if keg, err := p.PinnedKeg("bar"); err != nil || keg.Version() != "2.0" {
+		t.Errorf("PinnedKeg() = %s, %v, want version 2.0", keg, err)
+	}
+	assertNotExist(t, p.PinRecord("foo"))
+
+	if err := (&Pin{Hops: hops}).Run(ctx, "baz"); err == nil {
+		t.Error("Run() pinned a formula that is not installed")
+	}
+
+	if err := (&Unpin{Hops: hops}).Run(ctx, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Pinned("bar") {
+		t.Error("bar is still pinned")
+	}
+}
@@ -0,0 +1,375 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/services"
+)
+
+// ServicesList represents the action and its options.
+type ServicesList struct {
+	*Hops
+
+	JSON bool // Print output in JSON format
+}
+
+// serviceEntry is the JSON representation of a service.
+type serviceEntry struct {
+	Name        string          `json:"name"`
+	ServiceName string          `json:"service_name"`
+	Status      services.Status `json:"status"`
+	User        string          `json:"user"`
+	File        string          `json:"file"`
+}
+
+// Run runs the action.
+func (action *ServicesList) Run(ctx context.Context) error {
+	svcs, err := action.services(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	manager := services.SystemManager()
+	entries := make([]serviceEntry, 0, len(svcs))
+	for _, svc := range svcs {
+		entry, err := newServiceEntry(ctx, manager, svc)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	if action.JSON {
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No services available to control with `hops services`")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, o.StyleBold("Name")+"\t"+o.StyleBold("Status")+"\t"+o.StyleBold("User")+"\t"+o.StyleBold("File"))
+	for _, e := range entries {
+		fmt.Fprintln(w, e.Name+"\t"+string(e.Status)+"\t"+e.User+"\t"+e.File)
+	}
+	return w.Flush()
+}
+
+// newServiceEntry produces the listing of a service.
+func newServiceEntry(ctx context.Context, manager services.Manager, svc *services.Service) (serviceEntry, error) {
+	status, err := manager.Status(ctx, svc)
+	if err != nil {
+		return serviceEntry{}, err
+	}
+
+	entry := serviceEntry{
+		Name:        svc.Formula,
+		ServiceName: serviceName(svc),
+		Status:      status,
+	}
+	if status != services.StatusNone {
+		entry.User = os.Getenv("USER")
+		files, err := manager.Files(svc)
+		if err != nil {
+			return serviceEntry{}, err
+		}
+		entry.File = strings.Join(slices.Sorted(maps.Keys(files)), ",")
+	}
+	return entry, nil
+}
+
+// ServicesStart represents the action and its options.
+type ServicesStart struct {
+	*Hops
+
+	All bool // Start all services
+}
+
+// Run runs the action.
+func (action *ServicesStart) Run(ctx context.Context, args ...string) error {
+	svcs, err := action.servicesFromArgs(ctx, args, action.All)
+	if err != nil {
+		return err
+	}
+
+	manager := services.SystemManager()
+	for _, svc := range svcs {
+		if err := manager.Start(ctx, svc); err != nil {
+			return err
+		}
+		o.Hai(fmt.Sprintf("Successfully started `%s` (label: %s)", svc.Formula, serviceName(svc)))
+	}
+	return nil
+}
+
+// ServicesStop represents the action and its options.
+type ServicesStop struct {
+	*Hops
+
+	All bool // Stop all services
+}
+
+// Run runs the action.
+func (action *ServicesStop) Run(ctx context.Context, args ...string) error {
+	svcs, err := action.servicesFromArgs(ctx, args, action.All)
+	if err != nil {
+		return err
+	}
+
+	manager := services.SystemManager()
+	for _, svc := range svcs {
+		status, err := manager.Status(ctx, svc)
+		if err != nil {
+			return err
+		}
+		if status == services.StatusNone {
+			if !action.All {
+				o.Poo(fmt.Sprintf("Service `%s` is not started.", svc.Formula))
+			}
+			continue
+		}
+
+		if err := manager.Stop(ctx, svc); err != nil {
+			return err
+		}
+		o.Hai(fmt.Sprintf("Successfully stopped `%s` (label: %s)", svc.Formula, serviceName(svc)))
+	}
+	return nil
+}
+
+// ServicesRestart represents the action and its options.
+type ServicesRestart struct {
+	*Hops
+
+	All bool // Restart all services
+}
+
+// Run runs the action.
+func (action *ServicesRestart) Run(ctx context.Context, args ...string) error {
+	svcs, err := action.servicesFromArgs(ctx, args, action.All)
+	if err != nil {
+		return err
+	}
+
+	manager := services.SystemManager()
+	for _, svc := range svcs {
+		if err := manager.Restart(ctx, svc); err != nil {
+			return err
+		}
+		o.Hai(fmt.Sprintf("Successfully restarted `%s` (label: %s)", svc.Formula, serviceName(svc)))
+	}
+	return nil
+}
+
+// ServicesInfo represents the action and its options.
+type ServicesInfo struct {
+	*Hops
+
+	JSON bool // Print output in JSON format
+
+	// Print the service definition for "systemd" or "launchd" instead of its status
+	Format string
+}
+
+// serviceInfo is the JSON representation of a service's details.
+type serviceInfo struct {
+	serviceEntry
+	Running      bool     `json:"running"`
+	Loaded       bool     `json:"loaded"`
+	Schedulable  bool     `json:"schedulable"`
+	Command      []string `json:"command"`
+	WorkingDir   string   `json:"working_dir,omitempty"`
+	LogPath      string   `json:"log_path,omitempty"`
+	ErrorLogPath string   `json:"error_log_path,omitempty"`
+	Interval     int      `json:"interval,omitempty"`
+	Cron         string   `json:"cron,omitempty"`
+}
+
+// Run runs the action.
+func (action *ServicesInfo) Run(ctx context.Context, args ...string) error {
+	switch action.Format {
+	case "":
+	case "systemd":
+		return action.printFiles(ctx, args, services.OSLinux, services.NewSystemd())
+	case "launchd":
+		return action.printFiles(ctx, args, services.OSMacOS, services.NewLaunchd())
+	default:
+		return fmt.Errorf("unsupported service format %q, must be one of systemd, launchd", action.Format)
+	}
+
+	svcs, err := action.servicesFromArgs(ctx, args, false)
+	if err != nil {
+		return err
+	}
+
+	manager := services.SystemManager()
+	infos := make([]serviceInfo, 0, len(svcs))
+	for _, svc := range svcs {
+		entry, err := newServiceEntry(ctx, manager, svc)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, serviceInfo{
+			serviceEntry: entry,
+			Running:      entry.Status == services.StatusStarted,
+			Loaded:       entry.Status != services.StatusNone,
+			Schedulable:  svc.Scheduled(),
+			Command:      svc.Args,
+			WorkingDir:   svc.WorkingDir,
+			LogPath:      svc.LogPath,
+			ErrorLogPath: svc.ErrorLogPath,
+			Interval:     svc.Interval,
+			Cron:         svc.Cron,
+		})
+	}
+
+	if action.JSON {
+		return printJSON(infos)
+	}
+
+	for _, info := range infos {
+		o.H1(fmt.Sprintf("%s (%s)", info.Name, info.ServiceName))
+		fmt.Println("Running: " + checkmark(info.Running))
+		fmt.Println("Loaded: " + checkmark(info.Loaded))
+		fmt.Println("Schedulable: " + checkmark(info.Schedulable))
+		if info.User != "" {
+			fmt.Println("User: " + info.User)
+		}
+		fmt.Println("Command: " + strings.Join(info.Command, " "))
+		if info.WorkingDir != "" {
+			fmt.Println("Working directory: " + info.WorkingDir)
+		}
+		if info.LogPath != "" {
+			fmt.Println("Log: " + info.LogPath)
+		}
+		if info.ErrorLogPath != "" {
+			fmt.Println("Error log: " + info.ErrorLogPath)
+		}
+		if info.File != "" {
+			fmt.Println("File: " + info.File)
+		}
+	}
+	return nil
+}
+
+// printFiles prints the files defining the services for a service manager.
+func (action *ServicesInfo) printFiles(ctx context.Context, args []string, osName string, manager services.Manager) error {
+	formulae, err := action.serviceFormulae(ctx, args, false)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range formulae {
+		svc, err := services.New(f, action.Prefix(), osName)
+		if err != nil {
+			return err
+		}
+		files, err := manager.Files(svc)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range slices.Sorted(maps.Keys(files)) {
+			// Name the files when there is more than one
+			if len(files) > 1 || len(formulae) > 1 {
+				o.H1(path)
+			}
+			fmt.Print(files[path])
+		}
+	}
+	return nil
+}
+
+// checkmark formats a boolean as a check or cross mark.
+func checkmark(b bool) string {
+	if b {
+		return o.StyleGreen("✔")
+	}
+	return o.StyleRed("✘")
+}
+
+// serviceName produces the service manager's name for a service.
+func serviceName(svc *services.Service) string {
+	if services.SystemOS() == services.OSMacOS {
+		return services.Label(svc)
+	}
+	return services.UnitName(svc)
+}
+
+// services resolves the services of the named formulae, or of all installed
+// formulae with services if none are named.
+func (action *Hops) services(ctx context.Context, names []string) ([]*services.Service, error) {
+	formulae, err := action.serviceFormulae(ctx, names, len(names) == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	svcs := make([]*services.Service, 0, len(formulae))
+	for _, f := range formulae {
+		svc, err := services.New(f, action.Prefix(), services.SystemOS())
+		if err != nil {
+			return nil, err
+		}
+		svcs = append(svcs, svc)
+	}
+	return svcs, nil
+}
+
+// servicesFromArgs resolves the services of the formulae named by args, or of
+// all installed formulae with services.
+func (action *Hops) servicesFromArgs(ctx context.Context, args []string, all bool) ([]*services.Service, error) {
+	switch {
+	case all && len(args) > 0:
+		return nil, errors.New("formulae cannot be named with --all")
+	case all:
+		return action.services(ctx, nil)
+	case len(args) == 0:
+		return nil, errors.New("a formula or --all is required")
+	default:
+		return action.services(ctx, args)
+	}
+}
+
+// serviceFormulae fetches the named installed formulae, failing if any do not
+// define a service. If all is set, all installed formulae with services are
+// fetched instead.
+func (action *Hops) serviceFormulae(ctx context.Context, names []string, all bool) ([]formula.PlatformFormula, error) {
+	if all {
+		kegs, err := action.Prefix().Kegs()
+		if err != nil {
+			return nil, err
+		}
+		names = formula.Names(kegs)
+		slices.Sort(names)
+		names = slices.Compact(names)
+	}
+
+	formulae, err := action.fetchFromArgs(ctx, names, platform.SystemPlatform())
+	if err != nil {
+		return nil, err
+	}
+
+	withService := make([]formula.PlatformFormula, 0, len(formulae))
+	for _, f := range formulae {
+		switch {
+		case !action.Prefix().AnyInstalled(f):
+			return nil, action.Prefix().NewErrNoSuchKeg(f.Name())
+		case f.Service() != nil:
+			withService = append(withService, f)
+		case !all:
+			return nil, fmt.Errorf("%s: %w", f.Name(), services.ErrNoService)
+		}
+	}
+	return withService, nil
+}
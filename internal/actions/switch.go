@@ -0,0 +1,141 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/formula/version"
+	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+// Switch represents the action and its options.
+type Switch struct {
+	*Hops
+
+	Overwrite bool // Delete files that already exist in the prefix while linking
+}
+
+// Run runs the action.
+func (action *Switch) Run(ctx context.Context, name, v string) error {
+	p := action.Prefix()
+
+	release, err := action.lockPrefix(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	kegs, err := p.InstalledKegsByName(name)
+	if err != nil {
+		return err
+	}
+	if len(kegs) == 0 {
+		return p.NewErrNoSuchKeg(name)
+	}
+
+	keg, err := selectKeg(kegs, v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if p.Pinned(name) {
+		return fmt.Errorf("%s is pinned. You must unpin it to switch versions", name)
+	}
+
+	current, err := p.LinkedKeg(name)
+	if err != nil {
+		return err
+	}
+	opt, err := p.OptKeg(name)
+	if err != nil {
+		return err
+	}
+	if keg == current || (current == "" && keg == opt) {
+		o.Poo(fmt.Sprintf("%s %s is already active", name, keg.Version()))
+		return nil
+	}
+
+	o.Hai(fmt.Sprintf("Switching %s to %s", name, keg.Version()))
+
+	// Restore the links of the current keg if the switch fails
+	tx, err := p.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = action.switchKeg(tx, name, keg, current, kegs)
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// switchKeg unlinks every other keg of the named formula and links keg in their place.
+// Links are only created for keg if the formula is currently linked.
+func (action *Switch) switchKeg(tx *prefix.Transaction, name string, keg, current prefix.Keg, kegs []prefix.Keg) error {
+	p := action.Prefix()
+
+	// Unlink every keg of the formula, including its opt and linked records
+	others := make([]string, 0, len(kegs))
+	for _, k := range kegs {
+		if k != keg {
+			others = append(others, k.String())
+		}
+	}
+	links, err := p.Unlink(tx, others...)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		fmt.Printf("Unlinking %s... %d symlinks removed.\n", current, len(links))
+	}
+
+	// Repoint opt for dependents, which are linked against it
+	err = p.OptLink(name, keg.Version(), &symlink.Options{Overwrite: true, Recorder: tx})
+	if err != nil {
+		return err
+	}
+
+	// Keg-only formulae are not linked, so only opt is switched
+	if current == "" {
+		fmt.Printf("Switched %s to %s\n", p.OptRecord(name), keg)
+		return nil
+	}
+
+	created, _, err := p.Link(name, keg.Version(), &prefix.LinkOptions{
+		Name:      name,
+		Overwrite: action.Overwrite,
+		Recorder:  tx,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Linking %s... %d symlinks created.\n", keg, created)
+	return nil
+}
+
+// selectKeg selects the installed keg of the version.
+//
+// A version without a revision selects the newest revision of the version.
+func selectKeg(kegs []prefix.Keg, v string) (prefix.Keg, error) {
+	var selected prefix.Keg
+	versions := make([]string, 0, len(kegs))
+	for _, k := range kegs {
+		versions = append(versions, k.Version())
+		switch {
+		case k.Version() == v:
+			return k, nil
+		case string(version.ParsePkgVersion(k.Version()).Version) == v:
+			selected = k // kegs are sorted from oldest to newest
+		}
+	}
+	if selected == "" {
+		return "", fmt.Errorf("version %s is not installed, installed versions: %s", v, strings.Join(versions, ", "))
+	}
+	return selected, nil
+}
@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+func TestSelectKeg(t *testing.T) {
+	kegs := []prefix.Keg{"/Cellar/foo/1.0", "/Cellar/foo/1.0_1", "/Cellar/foo/1.0_2", "/Cellar/foo/2.0"}
+	tests := []struct {
+		version string
+		want    prefix.Keg
+		wantErr bool
+	}{
+		{version: "1.0", want: "/Cellar/foo/1.0"},
+		{version: "1.0_1", want: "/Cellar/foo/1.0_1"},
+		{version: "2.0", want: "/Cellar/foo/2.0"},
+		{version: "3.0", wantErr: true},
+		{version: "2.0_1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := selectKeg(kegs, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectKeg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("selectKeg() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Without a revision the newest revision is selected
+	got, err := selectKeg(kegs[1:], "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := prefix.Keg("/Cellar/foo/1.0_2"); got != want {
+		t.Errorf("selectKeg() = %s, want %s", got, want)
+	}
+}
+
+// testSwitch creates a Switch action for a prefix with "foo" 1.0 linked and 2.0 installed.
+func testSwitch(t *testing.T) *Switch {
+	t.Helper()
+	action := &Switch{Hops: testHops(t)}
+	p := action.Prefix()
+	makeKegs(t, p, "foo", "1.0", "2.0")
+	if _, _, err := p.Link("foo", "1.0", &prefix.LinkOptions{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	return action
+}
+
+// assertActive checks that the opt and linked records and the executable of "foo" point to the keg of the version.
+func assertActive(t *testing.T, p prefix.Prefix, version string) {
+	t.Helper()
+	for _, path := range []string{p.OptRecord("foo"), p.LinkedKegRecord("foo"), filepath.Join(p.String(), "bin", "foo")} {
+		got, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		want, err := filepath.EvalSymlinks(p.KegPath("foo", version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != p.OptRecord("foo") && path != p.LinkedKegRecord("foo") {
+			want = filepath.Join(want, "bin", "foo")
+		}
+		if got != want {
+			t.Errorf("%s links to %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("switch", func(t *testing.T) {
+		action := testSwitch(t)
+		if err := action.Run(ctx, "foo", "2.0"); err != nil {
+			t.Fatal(err)
+		}
+		assertActive(t, action.Prefix(), "2.0")
+		assertFinished(t, action.Prefix())
+	})
+
+	t.Run("keg-only", func(t *testing.T) {
+		action := testSwitch(t)
+		p := action.Prefix()
+		if _, err := p.Unlink(nil, p.KegPath("foo", "1.0")); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.OptLink("foo", "1.0", &symlink.Options{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := action.Run(ctx, "foo", "2.0"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the opt record is switched
+		if keg, err := p.OptKeg("foo"); err != nil || keg.Version() != "2.0" {
+			t.Errorf("OptKeg() = %s, %v, want version 2.0", keg, err)
+		}
+		assertNotExist(t, p.LinkedKegRecord("foo"), filepath.Join(p.String(), "bin", "foo"))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		action := testSwitch(t)
+		p := action.Prefix()
+		// Linking fails when a conflicting directory cannot be overwritten
+		action.Overwrite = true
+		if err := os.WriteFile(filepath.Join(p.KegPath("foo", "2.0"), "bin", "tool"), nil, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(p.String(), "bin", "tool", "dir"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := action.Run(ctx, "foo", "2.0"); err == nil {
+			t.Fatal("Run() succeeded")
+		}
+
+		// The links of the current keg are restored
+		assertActive(t, p, "1.0")
+		assertFinished(t, p)
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		action := testSwitch(t)
+		if err := action.Prefix().Pin("foo"); err != nil {
+			t.Fatal(err)
+		}
+		if err := action.Run(ctx, "foo", "1.0"); err == nil {
+			t.Fatal("Run() switched a pinned formula")
+		}
+		assertActive(t, action.Prefix(), "1.0")
+	})
+}
+
+// assertFinished checks that no transaction journals are left in the prefix.
+func assertFinished(t *testing.T, p prefix.Prefix) {
+	t.Helper()
+	if journals, _ := filepath.Glob(filepath.Join(p.Transactions(), "*.journal")); len(journals) > 0 {
+		t.Errorf("leftover journals %v", journals)
+	}
+}
@@ -2,10 +2,12 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/platform"
 )
 
@@ -21,15 +23,26 @@ func (action *Uninstall) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	return action.uninstall(ctx, formula.Names(formulae))
+}
+
+// uninstall uninstalls all kegs of the named formulae.
+func (action *Hops) uninstall(ctx context.Context, names []string) error {
+	release, err := action.lockPrefix(ctx, names...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// List all installed kegs
-	kegs := make([]string, 0, len(args))
-	for _, f := range formulae {
-		fkegs, err := action.Prefix().InstalledKegs(f)
+	kegs := make([]string, 0, len(names))
+	for _, name := range names {
+		fkegs, err := action.Prefix().InstalledKegsByName(name)
 		if err != nil {
 			return err
 		}
 		if len(fkegs) == 0 {
-			return action.Prefix().NewErrNoSuchKeg(f.Name())
+			return action.Prefix().NewErrNoSuchKeg(name)
 		}
 		for _, k := range fkegs {
 			kegs = append(kegs, k.String())
@@ -45,11 +58,19 @@ func (action *Uninstall) Run(ctx context.Context, args []string) error {
 	for _, k := range kegs {
 		kparent := filepath.Dir(k)
 		err = os.Remove(kparent)
-		if err != nil {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
 			return fmt.Errorf("removing prefix directory %s: %w", kparent, err)
 		}
 	}
 
+	// Remove pins of the uninstalled formulae
+	for _, name := range names {
+		err = action.Prefix().Unpin(name)
+		if err != nil {
+			return err
+		}
+	}
+
 	/*
 		Add something like the following:
 			Warning: The following may be dbus configuration files and have not been removed!
@@ -5,14 +5,13 @@ import (
 	"log/slog"
 	"strings"
 
-	"golang.org/x/mod/semver"
-
 	brewenv "github.com/act3-ai/hops/internal/apis/config.brew.sh"
 	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
 	brewapi "github.com/act3-ai/hops/internal/brew/api"
+	brewfmt "github.com/act3-ai/hops/internal/brew/fmt"
 	brewformulary "github.com/act3-ai/hops/internal/brew/formulary"
+	"github.com/act3-ai/hops/internal/formula/version"
 	"github.com/act3-ai/hops/internal/o"
-	"github.com/act3-ai/hops/internal/utils"
 	"github.com/act3-ai/hops/internal/utils/logutil"
 )
 
@@ -73,17 +72,10 @@ func (action *Update) Run(ctx context.Context) error {
 	return nil
 }
 
-// IsNewerThan reports if n is newer than o by comparing their versions.
+// IsNewerThan reports if a is newer than b by comparing their package versions.
 func IsNewerThan(a *brewv1.Info, b *brewv1.Info) bool {
-	return semver.Compare(
-		utils.FmtSemver(a.Versions.Stable),
-		utils.FmtSemver(b.Versions.Stable),
+	return version.Compare(
+		brewfmt.PkgVersion(a.Versions.Stable, a.Revision),
+		brewfmt.PkgVersion(b.Versions.Stable, b.Revision),
 	) > 0
 }
-
-func versionCompare(a, b string) int {
-	return semver.Compare(
-		utils.FmtSemver(a),
-		utils.FmtSemver(b),
-	)
-}
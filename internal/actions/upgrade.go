@@ -2,80 +2,410 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"slices"
 	"strings"
 
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/dependencies"
 	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/formula/bottle"
 	"github.com/act3-ai/hops/internal/o"
 	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/pretty"
+	"github.com/act3-ai/hops/internal/utils"
+	"github.com/act3-ai/hops/internal/utils/logutil"
 )
 
 // Upgrade represents the action and its options.
 type Upgrade struct {
-	*Hops
+	Install
+
+	outdated map[string][]prefix.Keg // outdated kegs of each upgraded formula
 }
 
 // Run runs the action.
 func (action *Upgrade) Run(ctx context.Context, args ...string) error {
-	var toCheck []formula.PlatformFormula
-	var err error
-	switch {
-	case len(args) > 0:
-		toCheck, err = action.fetchFromArgs(ctx, args, platform.SystemPlatform())
-		if err != nil {
+	action.platform = platform.SystemPlatform()
+	names := action.SetAlternateTags(args)
+
+	// Undo changes left behind by interrupted installs
+	if !action.DryRun {
+		if err := action.Prefix().Recover(); err != nil {
 			return err
 		}
-	default:
+	}
+
+	upgrades, missingDeps, err := action.resolveUpgrades(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	action.printUpgrades(upgrades)
+	printDeps(formula.Names(missingDeps), action.DryRun, false)
+
+	// Exit here for dry run
+	if action.DryRun {
+		return nil
+	}
+
+	// Install dependencies and then upgraded formulae
+	installs := slices.Concat(missingDeps, upgrades)
+
+	// Exit if there is nothing to upgrade
+	if len(installs) == 0 {
+		return nil
+	}
+
+	// Upgraded formulae that were not linked stay unlinked
+	err = action.keepUnlinked(upgrades)
+	if err != nil {
+		return err
+	}
+
+	// Check for conflicts before anything is downloaded
+	err = action.checkConflicts(installs)
+	if err != nil {
+		return err
+	}
+
+	// Lock the formulae against other hops and brew processes
+	release, err := action.lockFormulae(ctx, formula.Names(installs)...)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Verify that all bottles can be poured
+	err = action.Prefix().CanPourBottles(ctx, installs)
+	if err != nil {
+		return err
+	}
+
+	// Keep the cache from being pruned while bottles are written to it
+	releaseCache, err := action.lockCache(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer releaseCache()
+
+	// Get bottle registry
+	reg, err := action.BottleRegistry()
+	if err != nil {
+		return err
+	}
+
+	// Record changes to the prefix so a failed upgrade can be undone
+	tx, err := action.Prefix().Begin()
+	if err != nil {
+		return err
+	}
+
+	err = action.unlinkOutdated(ctx, tx)
+	if err == nil {
+		// Stream each bottle into the Cellar as it downloads
+		err = pourAll(ctx, installs, &action.DependencyOptions, action.MaxGoroutines(), func(ctx context.Context, f formula.PlatformFormula) error {
+			btl, err := bottle.Fetch(ctx, reg, f)
+			if err != nil {
+				return err
+			}
+			return errors.Join(
+				action.run(ctx, tx, f, btl),
+				btl.Close(),
+			)
+		})
+	}
+	if err == nil {
+		err = ctx.Err() // interrupted after the last bottle was poured
+	}
+	if err != nil {
+		o.Poo("Rolling back upgrade")
+		return errors.Join(err, tx.Rollback())
+	}
+
+	// Move the outdated kegs aside, they are removed when the upgrade is committed
+	var cleanupErr error
+	if !action.Config().Homebrew.NoInstallCleanup {
+		cleanupErr = action.cleanupOutdated(tx, upgrades)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Print stats on the keg's contents
+	o.Hai(fmt.Sprintf("Installed %d formulae in the Cellar:", len(installs)))
+	pretty.FormulaInstallStats(action.Prefix(), installs)
+
+	// Finish by printing all caveats again
+	for _, f := range installs {
+		if caveats := pretty.Caveats(f, action.Prefix()); caveats != "" {
+			o.Hai(f.Name() + ": Caveats\n" + caveats)
+		}
+	}
+
+	return cleanupErr
+}
+
+// resolveUpgrades resolves the outdated formulae that will be upgraded and
+// the missing dependencies that will be installed.
+//
+// All installed formulae are checked if no names are given.
+func (action *Upgrade) resolveUpgrades(ctx context.Context, names []string) (upgrades, missingDeps []formula.PlatformFormula, err error) {
+	formulary, err := action.Formulary(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requested := len(names) > 0
+	if !requested {
 		kegs, err := action.Prefix().Kegs()
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
-		kegNames := formula.Names(kegs)
 		// Sort and remove duplicates
-		slices.Sort(kegNames)
-		kegNames = slices.Compact(kegNames)
+		names = formula.Names(kegs)
+		slices.Sort(names)
+		names = slices.Compact(names)
+	}
 
-		toCheck, err = action.fetchFromArgs(ctx, kegNames, platform.SystemPlatform())
-		if err != nil {
+	candidates, err := formula.FetchAllPlatform(ctx, formulary, names, action.platform)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	action.outdated = map[string][]prefix.Keg{}
+	pinned := map[string]string{} // outdated pinned formulae and their pinned versions
+	for _, f := range candidates {
+		if !action.Prefix().AnyInstalled(f) {
+			return nil, nil, action.Prefix().NewErrNoSuchKeg(f.Name())
+		}
+
+		ok, err := action.addOutdated(f)
+		switch {
+		case err != nil:
+			return nil, nil, err
+		// Pinned formulae are not upgraded
+		case ok && action.Prefix().Pinned(f.Name()):
+			pinned[f.Name()] = action.skipPinned(f)
+		case ok:
+			upgrades = append(upgrades, f)
+		case requested:
+			o.Poo(fmt.Sprintf("%s %s already installed", f.Name(), formula.PkgVersion(f)))
+		}
+	}
+
+	if len(pinned) > 0 {
+		pword := "packages"
+		if len(pinned) == 1 {
+			pword = "package"
+		}
+		lines := make([]string, 0, len(pinned))
+		for _, name := range slices.Sorted(maps.Keys(pinned)) {
+			lines = append(lines, name+" "+pinned[name])
+		}
+		o.Poo(fmt.Sprintf("Not upgrading %d pinned %s:\n%s", len(pinned), pword, strings.Join(lines, "\n")))
+	}
+
+	// Check the dependencies of named formulae even if they are up-to-date
+	roots := upgrades
+	if requested {
+		roots = candidates
+	}
+
+	graph, err := dependencies.Walk(ctx, formulary, roots, action.platform, &action.DependencyOptions, action.MaxGoroutines())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Upgrade outdated dependencies and install missing ones
+	for _, dep := range graph.Dependencies() {
+		if _, ok := action.outdated[dep.Name()]; ok {
+			continue // already upgrading
+		}
+		if _, ok := pinned[dep.Name()]; ok {
+			continue // already skipped
+		}
+
+		if !action.Prefix().AnyInstalled(dep) {
+			missingDeps = append(missingDeps, dep)
+			continue
+		}
+
+		ok, err := action.addOutdated(dep)
+		switch {
+		case err != nil:
+			return nil, nil, err
+		// Pinned dependencies are not upgraded
+		case ok && action.Prefix().Pinned(dep.Name()):
+			pinned[dep.Name()] = action.skipPinned(dep)
+			o.Poo(fmt.Sprintf("Not upgrading pinned dependency %s %s to %s\nRun `hops unpin %s` to allow it to be upgraded.",
+				dep.Name(), pinned[dep.Name()], formula.PkgVersion(dep), dep.Name()))
+		case ok:
+			upgrades = append(upgrades, dep)
+		}
+	}
+	slog.Debug("Resolved upgrades",
+		slog.Any("upgrades", formula.Names(upgrades)),
+		slog.Any("missing", formula.Names(missingDeps)),
+		action.DependencyOptions.LogAttr(),
+	)
+
+	named := []string{}
+	if requested {
+		named = formula.Names(candidates)
+	}
+
+	return upgrades, missingDeps, action.carryOverRequested(upgrades, named)
+}
+
+// addOutdated records the outdated kegs of a formula,
+// reporting whether the formula is outdated.
+func (action *Upgrade) addOutdated(f formula.PlatformFormula) (bool, error) {
+	outdated, err := action.Prefix().FormulaOutdatedKegs(f)
+	if err != nil || len(outdated) == 0 {
+		return false, err
+	}
+
+	action.outdated[f.Name()] = outdated
+	return true, nil
+}
+
+// skipPinned excludes a pinned formula from the upgrade,
+// returning the version it is pinned to.
+func (action *Upgrade) skipPinned(f formula.PlatformFormula) string {
+	delete(action.outdated, f.Name())
+	keg, err := action.Prefix().PinnedKeg(f.Name())
+	if err != nil {
+		slog.Warn("Reading pin record", slog.String("formula", f.Name()), logutil.ErrAttr(err))
+	}
+	return keg.Version()
+}
+
+// carryOverRequested marks upgraded formulae as installed on request if
+// their outdated keg was installed on request.
+//
+// Formulae with no install receipt are marked as installed on request
+// only if they were named.
+func (action *Upgrade) carryOverRequested(upgrades []formula.PlatformFormula, named []string) error {
+	action.requested = []string{}
+	for _, f := range upgrades {
+		kegs := action.outdated[f.Name()]
+		r, err := receipt.Load(kegs[len(kegs)-1].String())
+		switch {
+		case err != nil:
 			return err
+		case r == nil:
+			slog.Debug("No install receipt in outdated keg", slog.String("formula", f.Name()))
+			if slices.Contains(named, f.Name()) {
+				action.requested = append(action.requested, f.Name())
+			}
+		case r.InstalledOnRequest:
+			action.requested = append(action.requested, f.Name())
 		}
 	}
+	return nil
+}
 
-	toUpgrade := make([]formula.PlatformFormula, 0, len(toCheck))
-	for _, f := range toCheck {
-		kegs, err := action.Prefix().InstalledKegs(f)
+// keepUnlinked marks upgraded formulae that are not linked so their new
+// kegs are not linked either.
+func (action *Upgrade) keepUnlinked(upgrades []formula.PlatformFormula) error {
+	for _, f := range upgrades {
+		linked, err := action.Prefix().LinkedKeg(f.Name())
 		if err != nil {
 			return err
 		}
-
-		outOfDate := true
-		for _, k := range kegs {
-			kegVersion := k.Version()
-			updated := formula.PkgVersion(f)
-			switch versionCompare(updated, kegVersion) {
-			case 0:
-				outOfDate = false
-			case 1:
-				slog.Debug("out of date keg found", slog.String("version", kegVersion))
-			default:
-				slog.Debug("keg has newer version", slog.String("version", kegVersion))
+		if linked == "" {
+			if action.unlinked == nil {
+				action.unlinked = map[string]bool{}
 			}
+			action.unlinked[f.Name()] = true
 		}
+	}
+	return nil
+}
 
-		if !outOfDate {
-			o.Poo(fmt.Sprintf("%s %s already installed", f.Name(), f.Version()))
-			continue
+// unlinkOutdated removes all links into the outdated kegs.
+func (action *Upgrade) unlinkOutdated(ctx context.Context, tx *prefix.Transaction) error {
+	kegs := []string{}
+	for _, outdated := range action.outdated {
+		for _, k := range outdated {
+			kegs = append(kegs, k.String())
 		}
-
-		fmt.Println("Upgrading " + formula.PkgVersion(f))
-		toUpgrade = append(toUpgrade, f)
+	}
+	if len(kegs) == 0 {
+		return nil
 	}
 
-	// TODO do install
-	fmt.Println("Would upgrade the following: " + strings.Join(formula.Names(toUpgrade), ", "))
+	release, err := action.lockLinks(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
+	links, err := action.Prefix().Unlink(tx, kegs...)
+	if err != nil {
+		return err
+	}
+	slog.Info("Unlinked outdated kegs", slog.Int("links", len(links)), slog.Any("kegs", kegs))
 	return nil
 }
+
+// cleanupOutdated removes the outdated kegs of the upgraded formulae as part of the transaction.
+//
+// A keg that cannot be removed does not fail the upgrade.
+func (action *Upgrade) cleanupOutdated(tx *prefix.Transaction, upgrades []formula.PlatformFormula) error {
+	var errs error
+	for _, f := range upgrades {
+		o.Hai("Running `hops cleanup " + f.Name() + "`...")
+		for _, k := range action.outdated[f.Name()] {
+			files, size, err := utils.CountDir(k.String())
+			if err != nil {
+				slog.Warn("Counting keg contents", logutil.ErrAttr(err))
+			}
+			fmt.Printf("Removing: %s... (%d files, %s)\n", k, files, utils.PrettyBytes(size))
+
+			err = tx.RemoveKeg(k.String())
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("removing outdated keg %s: %w", k, err))
+			}
+		}
+	}
+
+	if len(upgrades) > 0 {
+		fmt.Println("Disable this behaviour by setting HOMEBREW_NO_INSTALL_CLEANUP.")
+	}
+
+	return errs
+}
+
+// printUpgrades prints the formulae that will be upgraded.
+func (action *Upgrade) printUpgrades(upgrades []formula.PlatformFormula) {
+	pword := "packages"
+	if len(upgrades) == 1 {
+		pword = "package"
+	}
+
+	lines := make([]string, 0, len(upgrades))
+	for _, f := range upgrades {
+		kegs := action.outdated[f.Name()]
+		lines = append(lines, fmt.Sprintf("%s %s -> %s", f.Name(), kegs[len(kegs)-1].Version(), formula.PkgVersion(f)))
+	}
+
+	switch {
+	// No upgrades
+	case len(upgrades) == 0:
+	// Print upgrades
+	case action.DryRun:
+		o.Hai(fmt.Sprintf("Would upgrade %d outdated %s:\n%s", len(upgrades), pword, strings.Join(lines, "\n")))
+	// Print upgrades
+	default:
+		o.H1(fmt.Sprintf("Upgrading %d outdated %s:\n%s", len(upgrades), pword, strings.Join(lines, "\n")))
+	}
+}
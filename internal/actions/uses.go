@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	brewformulary "github.com/act3-ai/hops/internal/brew/formulary"
+	"github.com/act3-ai/hops/internal/dependencies"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Uses represents the action and its options.
+type Uses struct {
+	*Hops
+	DependencyOptions formula.DependencyTags
+
+	Installed bool // Only list formulae that are installed
+	Recursive bool // Resolve more than one level of dependencies
+}
+
+// Run runs the action.
+func (action *Uses) Run(ctx context.Context, args ...string) error {
+	plat := platform.SystemPlatform()
+
+	// Fetch the named formulae to check that they exist
+	targets, err := action.fetchFromArgs(ctx, args, plat)
+	if err != nil {
+		return err
+	}
+
+	store, err := action.Formulary(ctx)
+	if err != nil {
+		return err
+	}
+
+	var candidates []formula.PlatformFormula
+	if action.Installed {
+		candidates, err = action.installedFormulae(ctx)
+	} else {
+		index, ok := store.(brewformulary.PreloadedFormulary)
+		if !ok {
+			return errors.New("uses is only available with --installed for standalone registry mode")
+		}
+		candidates, err = formula.FetchAllPlatform(ctx, store, index.ListNames(), plat)
+	}
+	if err != nil {
+		return err
+	}
+
+	graph, err := dependencies.Walk(ctx, store, candidates, plat, &action.DependencyOptions, action.MaxGoroutines())
+	if err != nil {
+		return err
+	}
+
+	// List the formulae that use every named formula
+	var uses []string
+	for i, target := range targets {
+		dependents := formula.Names(graph.Dependents(target.Name(), action.Recursive))
+		if i == 0 {
+			uses = dependents
+			continue
+		}
+		uses = slices.DeleteFunc(uses, func(name string) bool {
+			return !slices.Contains(dependents, name)
+		})
+	}
+
+	slices.Sort(uses)
+	for _, name := range uses {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -43,14 +43,14 @@ func hopsRegistry(cfg *hopsv1.RegistryConfig, userAgent string) (hopsreg.Registr
 	}
 }
 
-func hopsClient(cache string, alternateTags map[string]string, maxGoroutines int, reg hopsreg.Registry) hops.Client {
+func hopsClient(cache string, alternateTags, renames map[string]string, maxGoroutines int, reg hopsreg.Registry) hops.Client {
 	// Create OCI layout cache
 	btlcache := hopsreg.NewLocal(cache)
 
 	// Initialize client
 	return hops.NewClient(
 		reg, btlcache,
-		alternateTags, maxGoroutines)
+		alternateTags, renames, maxGoroutines)
 }
 
 // reference: https://github.com/oras-project/oras/blob/main/cmd/oras/internal/option/remote.go#L234
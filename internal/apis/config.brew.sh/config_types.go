@@ -84,6 +84,9 @@ type Configuration struct {
 	// Default: `https://ghcr.io/v2/homebrew/core`.
 	BottleDomain string `json:"bottleDomain,omitempty" yaml:"bottleDomain,omitempty" env:"BOTTLE_DOMAIN"`
 
+	// If set, `brew install`, `brew upgrade` and `brew reinstall` will never automatically cleanup installed/upgraded/reinstalled formulae.
+	NoInstallCleanup bool `json:"noInstallCleanup,omitempty" yaml:"noInstallCleanup,omitempty" env:"NO_INSTALL_CLEANUP"`
+
 	// Docker registry configuration
 	DockerRegistry DockerRegistryConfig `json:"dockerRegistry,omitempty" yaml:"dockerRegistry,omitempty" envPrefix:"DOCKER_REGISTRY_"`
 }
@@ -231,6 +234,8 @@ func ConfigurationEnvOverrides(cfg *Configuration) {
 		})
 	cfg.BottleDomain = env.String(
 		"HOMEBREW_BOTTLE_DOMAIN", cfg.BottleDomain)
+	cfg.NoInstallCleanup = env.Bool(
+		"HOMEBREW_NO_INSTALL_CLEANUP", cfg.NoInstallCleanup)
 	cfg.DockerRegistry.BasicAuthToken = env.String(
 		"HOMEBREW_DOCKER_REGISTRY_BASIC_AUTH_TOKEN", cfg.DockerRegistry.BasicAuthToken)
 	cfg.DockerRegistry.BasicAuthToken = env.String(
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/adrg/xdg"
+	"github.com/dustin/go-humanize"
 
 	"github.com/act3-ai/hops/internal/apis/apiutil"
 	brewenv "github.com/act3-ai/hops/internal/apis/config.brew.sh"
@@ -56,6 +58,20 @@ type Configuration struct {
 
 	// Registry configures a Hops-compatible registry for Bottles.
 	Registry RegistryConfig `json:"registry,omitempty" yaml:"registry,omitempty" envPrefix:"REGISTRY_"`
+
+	// Cleanup configures how `hops cleanup` prunes the cache.
+	Cleanup CleanupConfig `json:"cleanup,omitempty" yaml:"cleanup,omitempty" envPrefix:"CLEANUP_"`
+}
+
+// CleanupConfig configures how `hops cleanup` prunes the cache.
+type CleanupConfig struct {
+	// MaxAgeDays prunes cached bottles that have not been used in this many days. Overrides Homebrew's HOMEBREW_CLEANUP_MAX_AGE_DAYS value.
+	//
+	// Default: 120
+	MaxAgeDays *int `json:"maxAgeDays,omitempty" yaml:"maxAgeDays,omitempty" env:"MAX_AGE_DAYS"`
+
+	// MaxSize prunes the least recently used bottles until the cache is no larger than this size, such as "10GB". The cache size is not limited if unset.
+	MaxSize string `json:"maxSize,omitempty" yaml:"maxSize,omitempty" env:"MAX_SIZE"`
 }
 
 // RegistryConfig configures a Hops-compatible registry for Bottles.
@@ -92,6 +108,11 @@ func ConfigurationDefault(cfg *Configuration) {
 		cfg.Cache = filepath.Join(xdg.CacheHome, "hops")
 	}
 
+	if cfg.Cleanup.MaxAgeDays == nil {
+		cfg.Cleanup.MaxAgeDays = new(int)
+		*(cfg.Cleanup.MaxAgeDays) = brewenv.DefaultCleanupMaxAgeDays
+	}
+
 	// Default Homebrew fields
 	brewenv.ConfigurationDefault(&cfg.Homebrew)
 }
@@ -108,6 +129,15 @@ func ConfigurationEnvOverrides(cfg *Configuration) {
 	// Override registry fields
 	RegistryConfigEnvOverrides(ConfigurationEnvPrefix+"_REGISTRY", &cfg.Registry)
 
+	cfg.Cleanup.MaxAgeDays = env.OneOfOr([]string{
+		ConfigurationEnvPrefix + "_CLEANUP_MAX_AGE_DAYS",
+		"HOMEBREW_CLEANUP_MAX_AGE_DAYS",
+	}, cfg.Cleanup.MaxAgeDays, func(envVal string) (*int, error) {
+		val, err := strconv.Atoi(envVal)
+		return &val, err
+	})
+	cfg.Cleanup.MaxSize = env.String(ConfigurationEnvPrefix+"_CLEANUP_MAX_SIZE", cfg.Cleanup.MaxSize)
+
 	// Override Homebrew fields
 	brewenv.ConfigurationEnvOverrides(&cfg.Homebrew)
 }
@@ -140,6 +170,18 @@ func (cfg *Configuration) LogValue() slog.Value {
 	return slog.StringValue(string(b))
 }
 
+// ParseMaxSize parses the maximum cache size in bytes, or 0 if the size is not limited.
+func (cfg *CleanupConfig) ParseMaxSize() (int64, error) {
+	if cfg.MaxSize == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(cfg.MaxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache size %q: %w", cfg.MaxSize, err)
+	}
+	return int64(size), nil
+}
+
 // ParseHeaders parses the configured HTTP headers.
 func (cfg *RegistryConfig) ParseHeaders() (map[string][]string, error) {
 	headers := map[string][]string{}
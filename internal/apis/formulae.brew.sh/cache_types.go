@@ -7,6 +7,7 @@ import (
 const (
 	CachedFormulaNamesFile   = cached.FormulaNamesFile   // FormulaAliasesFile is the name of the formula name cache.
 	CachedFormulaAliasesFile = cached.FormulaAliasesFile // FormulaAliasesFile is the name of the formula aliases cache.
+	CachedFormulaRenamesFile = cached.FormulaRenamesFile // FormulaRenamesFile is the name of the formula renames cache.
 )
 
 // FormulaNames represents the contents of the formula names file.
@@ -3,7 +3,6 @@ package common
 import (
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/act3-ai/hops/internal/utils"
 )
@@ -48,7 +47,7 @@ type Service struct {
 
 	RunType              ServiceRunType    `json:"run_type"`
 	EnvironmentVariables map[string]string `json:"environment_variables,omitempty"`
-	Interval             time.Duration     `json:"interval,omitempty"`
+	Interval             int               `json:"interval,omitempty"` // seconds
 	Cron                 string            `json:"cron,omitempty"`
 	RequireRoot          bool              `json:"require_root,omitempty"`
 	KeepAlive            KeepAliveConfig   `json:"keep_alive,omitempty"`
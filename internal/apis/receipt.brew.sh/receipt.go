@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"time"
 
 	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
@@ -28,21 +30,29 @@ type InstallReceipt struct {
 	ChangedFiles          []string                    `json:"changed_files"`
 	Time                  uint                        `json:"time"`
 	SourceModifiedTime    uint                        `json:"source_modified_time"`
+	StdLib                *string                     `json:"stdlib"`
 	Compiler              string                      `json:"compiler"`
 	Aliases               []string                    `json:"aliases"`
 	RuntimeDependencies   []*brewv1.RuntimeDependency `json:"runtime_dependencies"`
 	Source                Source                      `json:"source"`
 	Arch                  string                      `json:"arch"`
-	BuiltOn               tab.BuiltOn
+	BuiltOn               tab.BuiltOn                 `json:"built_on"`
 }
 
 // Source section of the receipt.
 type Source struct {
-	Spec       string          `json:"spec"`
-	Versions   brewv1.Versions `json:"versions"`
-	Path       string          `json:"path"`
-	TapGitHead string          `json:"tap_git_head"`
-	Tap        string          `json:"tap"`
+	Spec       string         `json:"spec"`
+	Versions   SourceVersions `json:"versions"`
+	Path       string         `json:"path"`
+	TapGitHead string         `json:"tap_git_head"`
+	Tap        string         `json:"tap"`
+}
+
+// SourceVersions is the versions section of the receipt's source.
+type SourceVersions struct {
+	Stable        string  `json:"stable"`
+	Head          *string `json:"head"`
+	VersionScheme int     `json:"version_scheme"`
 }
 
 // Load loads the INSTALL_RECEIPT.json for a keg.
@@ -65,50 +75,91 @@ func Load(keg string) (*InstallReceipt, error) {
 	return r, nil
 }
 
-// CreateInstallReceipt creates an install receipt for the formula.
-func CreateInstallReceipt(version string, requested []string, info *brewv1.Info) *InstallReceipt {
-	isDep := true
-	for _, r := range requested {
-		for _, n := range info.PossibleNames() {
-			if n == r {
-				isDep = false
-			}
+// NewInstallReceipt creates an install receipt for a formula poured from a bottle.
+//
+// The receipt included in the bottle is used as the base, if given. Build
+// information is taken from the bottle's Tab, if given. The path is the
+// location of the formula's Ruby source.
+func NewInstallReceipt(base *InstallReceipt, info *brewv1.PlatformInfo, t *tab.Tab, requested bool, path, hopsVersion string) *InstallReceipt {
+	r := &InstallReceipt{}
+	if base != nil {
+		*r = *base
+	}
+
+	// Copy build information from the Tab
+	if t != nil {
+		r.HomebrewVersion = t.HomebrewVersion
+		r.ChangedFiles = t.ChangedFiles
+		r.SourceModifiedTime = t.SourceModifiedTime
+		r.Compiler = t.Compiler
+		r.RuntimeDependencies = t.RuntimeDependencies
+		r.BuiltOn = t.BuiltOn
+		if t.StdLib != "" {
+			r.StdLib = &t.StdLib
 		}
 	}
 
-	return &InstallReceipt{
-		HomebrewVersion:       "hops+" + version,
-		BuiltAsBottle:         true,
-		PouredFromBottle:      true,
-		LoadedFromAPI:         true,
-		InstalledAsDependency: isDep,
-		InstalledOnRequest:    !isDep,
-		// Time:                  time.Now(),
-		Aliases: info.Aliases,
+	// Homebrew parses the version to decide whether runtime
+	// dependencies can be trusted, so only use ours as a last resort
+	if r.HomebrewVersion == "" {
+		r.HomebrewVersion = "hops+" + hopsVersion
+	}
+
+	// Homebrew cannot read null options
+	if r.UsedOptions == nil {
+		r.UsedOptions = []any{}
+	}
+	if r.UnusedOptions == nil {
+		r.UnusedOptions = []any{}
 	}
-}
 
-// NewInstallReceipt creates an install receipt for a formula.
-func NewInstallReceipt(info *brewv1.Info, t *tab.Tab, requested bool, hopsVersion string) *InstallReceipt {
-	return &InstallReceipt{
-		HomebrewVersion:       "hops+" + hopsVersion,
-		BuiltAsBottle:         true,
-		PouredFromBottle:      true,
-		LoadedFromAPI:         true,
-		InstalledAsDependency: !requested,
-		InstalledOnRequest:    requested,
-		ChangedFiles:          t.ChangedFiles,
-		Time:                  uint(time.Now().Unix()),
-		SourceModifiedTime:    t.SourceModifiedTime,
-		Compiler:              t.Compiler,
-		Aliases:               info.Aliases,
-		RuntimeDependencies:   t.RuntimeDependencies,
-		Source: Source{
-			Spec:     brewv1.Stable,
-			Versions: info.Versions,
-			Path:     "HOMEBREW_PREFIX/Library/Taps/homebrew/homebrew-core/Formula/g/git.rb",
+	r.BuiltAsBottle = true
+	r.PouredFromBottle = true
+	r.LoadedFromAPI = true
+	r.InstalledAsDependency = !requested
+	r.InstalledOnRequest = requested
+	r.Time = uint(time.Now().Unix())
+	r.Arch = arch()
+	r.Aliases = slices.Clone(info.Aliases)
+	if r.Aliases == nil {
+		r.Aliases = []string{}
+	}
+	r.Source = Source{
+		Spec: brewv1.Stable,
+		Versions: SourceVersions{
+			Stable:        info.Versions.Stable,
+			Head:          info.Versions.Head,
+			VersionScheme: info.VersionScheme,
 		},
-		Arch:    t.Arch,
-		BuiltOn: t.BuiltOn,
+		Path:       path,
+		TapGitHead: info.TapGitHead,
+		Tap:        info.Tap,
+	}
+
+	return r
+}
+
+// Write writes the install receipt to a keg.
+func (r *InstallReceipt) Write(keg string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding install receipt: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(keg, InstallReceiptFile), append(b, '\n'), 0o644)
+	if err != nil {
+		return fmt.Errorf("writing install receipt: %w", err)
+	}
+
+	return nil
+}
+
+// arch produces the CPU architecture in Homebrew's format.
+func arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	default:
+		return runtime.GOARCH
 	}
 }
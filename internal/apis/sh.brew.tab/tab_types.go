@@ -1,6 +1,9 @@
 package tab
 
 import (
+	"encoding/json"
+	"fmt"
+
 	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
 )
 
@@ -32,3 +35,20 @@ type BuiltOn struct {
 	CLT           string `json:"clt"`
 	PreferredPerl string `json:"preferred_perl"`
 }
+
+// FromAnnotations parses the Tab from a set of annotations.
+//
+// A return value of nil, nil signifies that the annotations do not contain a Tab.
+func FromAnnotations(annotations map[string]string) (*Tab, error) {
+	value, ok := annotations[AnnotationTab]
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	t := &Tab{}
+	if err := json.Unmarshal([]byte(value), t); err != nil {
+		return nil, fmt.Errorf("parsing %s annotation: %w", AnnotationTab, err)
+	}
+
+	return t, nil
+}
@@ -17,6 +17,7 @@ import (
 type PreloadedFormulary interface {
 	formula.Formulary
 	ListNames() []string
+	Renames() map[string]string
 }
 
 // V1Cache represents formula data cached from the Homebrew API.
@@ -74,6 +75,12 @@ func (index *V1Cache) Find(name string) *brewv1.Info {
 		return index.Find(rname)
 	}
 
+	// Look up as old name
+	rname, ok = index.renames[name]
+	if ok && rname != name {
+		return index.Find(rname)
+	}
+
 	return nil
 }
 
@@ -108,6 +115,11 @@ func (index *V1Cache) Aliases() map[string]string {
 	return maps.Clone(index.aliases)
 }
 
+// Renames returns the map of old names to current names.
+func (index *V1Cache) Renames() map[string]string {
+	return maps.Clone(index.renames)
+}
+
 func writeAPICache(cached *V1Cache, dir string) error {
 	// Create parent directory
 	err := os.MkdirAll(dir, 0o775)
@@ -125,5 +137,11 @@ func writeAPICache(cached *V1Cache, dir string) error {
 		return err
 	}
 
+	// Renames are written in the same format as aliases
+	err = api.WriteFormulaAliases(cached.Renames(), renamesFile(dir))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -17,7 +17,7 @@ import (
 
 // FetchV1 fetches the v1 index either from the cache or from the API according to its existence and the auto-update configuration.
 func FetchV1(ctx context.Context, apiclient *brewapi.Client, dir string, autoUpdate *brewenv.AutoUpdateConfig) (*V1Cache, error) {
-	_, err := os.Stat(formulaeFile(dir))
+	_, err := os.Stat(FormulaeFile(dir))
 	switch {
 	// File does not exist or is unreadable
 	case err != nil:
@@ -26,7 +26,7 @@ func FetchV1(ctx context.Context, apiclient *brewapi.Client, dir string, autoUpd
 	case autoUpdate == nil:
 		return LoadV1(dir)
 	// File exists but requires updating
-	case autoUpdate.ShouldAutoUpdate(formulaeFile(dir)):
+	case autoUpdate.ShouldAutoUpdate(FormulaeFile(dir)):
 		return fetchV1(ctx, apiclient, dir)
 	// File exists and does not need updated
 	default:
@@ -34,7 +34,8 @@ func FetchV1(ctx context.Context, apiclient *brewapi.Client, dir string, autoUpd
 	}
 }
 
-func formulaeFile(dir string) string {
+// FormulaeFile produces the path of the cached formula index in the cache directory.
+func FormulaeFile(dir string) string {
 	return filepath.Join(dir, "api", "formula.json")
 }
 
@@ -46,6 +47,24 @@ func aliasesFile(dir string) string {
 	return filepath.Join(dir, "api", api.CachedFormulaAliasesFile)
 }
 
+func renamesFile(dir string) string {
+	return filepath.Join(dir, "api", api.CachedFormulaRenamesFile)
+}
+
+// LoadRenames loads the map of old names to current names from a cache directory.
+// No names are renamed if the index was never cached.
+func LoadRenames(dir string) (map[string]string, error) {
+	renames, err := api.LoadFormulaAliases(renamesFile(dir))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return map[string]string{}, nil
+	case err != nil:
+		return nil, err
+	default:
+		return renames, nil
+	}
+}
+
 // readWriteJSON reads from r while writing to a file at path and simultaneously decoding JSON into type T.
 func readWriteJSON[T any](path string, r io.Reader) (*T, error) {
 	// Create parent directory
@@ -75,7 +94,7 @@ func readWriteJSON[T any](path string, r io.Reader) (*T, error) {
 
 // LoadV1 loads the v1 index from a cache directory.
 func LoadV1(dir string) (*V1Cache, error) {
-	file := formulaeFile(dir)
+	file := FormulaeFile(dir)
 	// Check for existing index file
 	f, err := os.Open(file)
 	switch {
@@ -111,7 +130,7 @@ func fetchV1(ctx context.Context, apiclient *brewapi.Client, dir string) (*V1Cac
 	defer r.Close()
 
 	// Parse JSON and cache the response
-	data, err := readWriteJSON[[]*brewv1.Info](formulaeFile(dir), r)
+	data, err := readWriteJSON[[]*brewv1.Info](FormulaeFile(dir), r)
 	if err != nil {
 		return nil, err
 	}
@@ -3,28 +3,34 @@ package brewreg
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/sourcegraph/conc/iter"
 
 	brewfmt "github.com/act3-ai/hops/internal/brew/fmt"
+	"github.com/act3-ai/hops/internal/cache"
+	"github.com/act3-ai/hops/internal/errdef"
 	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/formula/bottle"
 	"github.com/act3-ai/hops/internal/utils/logutil"
-	"github.com/act3-ai/hops/internal/utils/resputil"
 	"github.com/act3-ai/hops/internal/utils/symlink"
 )
 
 // Registry defines the capabilities of Homebrew's registry usage.
 type Registry interface {
 	bottle.ConcurrentRegistry
+	bottle.TabRegistry
+	bottle.Downloader
 }
 
 // registry downloads bottles with an HTTP client.
@@ -43,8 +49,16 @@ type registry struct {
 	maxGoroutines  int
 	bottleDomain   string
 	artifactDomain string
+	retries        int           // number of times to resume a failed download
+	backoff        time.Duration // delay before the first retry, doubled for each retry
 }
 
+// Download retry defaults.
+const (
+	defaultDownloadRetries = 4
+	defaultDownloadBackoff = time.Second
+)
+
 // NewBottleRegistry creates a new BottleRegistry.
 func NewBottleRegistry(
 	headers http.Header,
@@ -66,6 +80,8 @@ func newRegistry(headers http.Header, client *http.Client, cache string, maxGoro
 		maxGoroutines:  maxGoroutines,
 		bottleDomain:   bottleDomain,
 		artifactDomain: artifactDomain,
+		retries:        defaultDownloadRetries,
+		backoff:        defaultDownloadBackoff,
 	}
 }
 
@@ -75,6 +91,11 @@ func (store *registry) Source(f formula.PlatformFormula) (string, error) {
 	if path == "" { // specifies no bottle
 		return "", nil
 	}
+	return store.resolveURL(root, path)
+}
+
+// resolveURL joins a root URL and path, applying the configured domain overrides.
+func (store *registry) resolveURL(root, path string) (string, error) {
 	if store.bottleDomain != "" {
 		// Replace default bottle domain with configured bottle domain
 		root = store.bottleDomain
@@ -117,20 +138,31 @@ func (store *registry) FetchBottles(ctx context.Context, formulae []formula.Plat
 }
 
 // fetchBottle implements formula.BottleRegistry.
+//
+// Bottles that are not cached are streamed from their source as they download.
 func (store *registry) fetchBottle(ctx context.Context, f formula.PlatformFormula) (io.ReadCloser, error) {
-	// Download bottle file
-	path, err := store.download(ctx, f)
+	_, btl, err := store.open(ctx, f)
 	if err != nil {
 		return nil, fmt.Errorf("downloading bottle: %w", err)
 	}
+	return btl, nil
+}
 
-	// Open downloaded bottle file
-	btl, err := os.Open(path)
+// DownloadBottle implements bottle.Downloader.
+func (store *registry) DownloadBottle(ctx context.Context, f formula.PlatformFormula) (bool, error) {
+	_, btl, err := store.open(ctx, f)
 	if err != nil {
-		return nil, fmt.Errorf("opening bottle file %s: %w", path, err)
+		return false, fmt.Errorf("downloading bottle: %w", err)
 	}
+	// Cached files are verified when opened
+	_, downloading := btl.(*downloadStream)
 
-	return btl, nil
+	// Read the bottle to the end to complete the download
+	_, err = io.Copy(io.Discard, btl)
+	if err = errors.Join(err, btl.Close()); err != nil {
+		return false, fmt.Errorf("downloading bottle: %w", err)
+	}
+	return !downloading, nil
 }
 
 // LinkName returns the name of the symlink to the downloaded bottle .tar.gz file for the formula.
@@ -146,57 +178,67 @@ func linkName(f formula.Formula) string {
 	return f.Name() + "--" + formula.PkgVersion(f)
 }
 
-// lookupCachedFile.
-func lookupCachedFile(file, link string) (*os.File, error) {
+// lookupCachedFile creates the cache symlink to a download file, reporting whether the file is already downloaded.
+func lookupCachedFile(file, link string) (bool, error) {
 	// Create parent directories (also will create the cache directory if it does not exist)
 	err := os.MkdirAll(filepath.Dir(file), 0o775)
 	if err != nil {
-		return nil, fmt.Errorf("creating download file: %w", err)
+		return false, fmt.Errorf("creating download file: %w", err)
 	}
 
 	// Create the symlink into the downloads directory
 	err = symlink.Relative(file, link, &symlink.Options{Overwrite: true})
 	if err != nil {
-		return nil, fmt.Errorf("creating cache symlink: %w", err)
+		return false, fmt.Errorf("creating cache symlink: %w", err)
 	}
 
 	_, err = os.Stat(link)
-	if err == nil {
-		// Return here if the file is already downloaded
-		return nil, nil
-	}
-
-	// For any unreadable files, remove the files and redownload
-	if !errors.Is(err, os.ErrNotExist) {
-		// Implies an unreadable file in the cache
-		// Remove files and redownload
+	switch {
+	// File is already downloaded
+	case err == nil:
+		return true, nil
+	// File is not downloaded
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	// Implies an unreadable file in the cache, remove it and redownload
+	default:
 		slog.Warn("Removing unreadable cache file", logutil.ErrAttr(err))
-
 		err = os.RemoveAll(file)
 		if err != nil {
-			return nil, fmt.Errorf("removing unreadable file in cache: %w", err)
+			return false, fmt.Errorf("removing unreadable file in cache: %w", err)
 		}
+		return false, nil
 	}
+}
 
-	// Open the symlink path, creating the cache file
-	bottleFile, err := os.Create(link)
+// download downloads a bottle to the cache, returning the path to the downloaded file.
+func (store *registry) download(ctx context.Context, f formula.PlatformFormula) (string, error) {
+	link, btl, err := store.open(ctx, f)
 	if err != nil {
-		return nil, fmt.Errorf("creating download file: %w", err)
+		return "", err
 	}
 
-	return bottleFile, nil
+	// Read the bottle to the end to complete the download
+	_, err = io.Copy(io.Discard, btl)
+	if err = errors.Join(err, btl.Close()); err != nil {
+		return "", err
+	}
+
+	return link, nil
 }
 
-// Download downloads a bottle.
-func (store *registry) download(ctx context.Context, f formula.PlatformFormula) (string, error) {
+// open opens a bottle from the cache, or streams it from its source while saving it to the cache.
+//
+// Returns the path to the cached file.
+func (store *registry) open(ctx context.Context, f formula.PlatformFormula) (string, io.ReadCloser, error) {
 	btl := f.Bottle()
 	if btl == nil {
-		return "", fmt.Errorf("no bottle provided for Formula %s", f.Name())
+		return "", nil, fmt.Errorf("no bottle provided for Formula %s", f.Name())
 	}
 
 	source, err := store.Source(f)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	bottleFileName := formula.BottleFileName(f)
@@ -205,62 +247,114 @@ func (store *registry) download(ctx context.Context, f formula.PlatformFormula)
 	urlsum := sha256.Sum256([]byte(source))
 	file := filepath.Join(store.cache, "downloads", fmt.Sprintf("%x--%s", urlsum, bottleFileName))
 
+	// Partial downloads are kept next to the download file so they can be resumed
+	// 5c7f66c74fe4c17116808bfac4c2729c32062dbec291e2a897d267567c790ea4--cowsay--3.04_1.arm64_sonoma.bottle.tar.gz.incomplete
+	partial := file + ".incomplete"
+
 	// cowsay--3.04_1
 	link := filepath.Join(store.cache, linkName(f))
 
-	bottleFile, err := lookupCachedFile(file, link)
-	if err == nil && bottleFile == nil {
-		// Return here if the file is already downloaded
-		// this should also validate the existing file's checksum
-		slog.Debug("Already downloaded: " + bottleFileName)
-		return link, nil
-	} else if err != nil {
-		return "", err
+	cached, err := lookupCachedFile(file, link)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Verify the cached file before reuse
+	if cached {
+		err := verifyFile(file, f.Name(), btl.Sha256)
+		switch {
+		// Cached file is intact
+		case err == nil:
+			slog.Debug("Already downloaded: " + bottleFileName)
+			if err := cache.Touch(file); err != nil {
+				slog.Debug("could not mark bottle as used", logutil.ErrAttr(err))
+			}
+			r, err := os.Open(file)
+			if err != nil {
+				return "", nil, fmt.Errorf("opening bottle file %s: %w", file, err)
+			}
+			return link, r, nil
+		// Cached file is corrupt, remove it and download again
+		case errors.As(err, &errdef.DigestMismatchError{}):
+			slog.Warn("Removing corrupt cache file", slog.String("file", file), logutil.ErrAttr(err))
+			if err := os.RemoveAll(file); err != nil {
+				return "", nil, fmt.Errorf("removing corrupt file in cache: %w", err)
+			}
+		// Unknown error
+		default:
+			return "", nil, err
+		}
 	}
-	defer bottleFile.Close()
 
 	u, err := url.Parse(source)
 	if err != nil {
-		return "", fmt.Errorf("parsing bottle source: %w", err)
+		return "", nil, fmt.Errorf("parsing bottle source: %w", err)
 	}
 
 	slog.Debug("starting bottle download",
 		slog.String("url", source),
 		slog.String("ln", link),
-		slog.String("path", file))
-
-	deleteOnFailure := func() error {
-		return errors.Join(
-			os.RemoveAll(file),
-			os.RemoveAll(link),
-		)
-	}
+		slog.String("path", partial))
 
 	switch u.Scheme {
 	case "https", "http":
 		slog.Debug("Downloading bottle")
-		err = downloadBottleHTTP(ctx, *store.HTTP, store.headers, source, bottleFile)
+		w, err := openPartialFile(partial)
 		if err != nil {
-			return "", errors.Join(
-				fmt.Errorf("downloading bottle: %w", err),
-				deleteOnFailure(),
-			)
+			return "", nil, err
 		}
+		return link, &downloadStream{
+			ctx:      ctx,
+			store:    store,
+			ref:      source,
+			name:     f.Name(),
+			expected: btl.Sha256,
+			file:     file,
+			link:     link,
+			w:        w,
+			backoff:  store.backoff,
+		}, nil
 	// case "oci":
 	// 	err := downloadBottleOCI(ctx, store.OCI, strings.TrimPrefix(source, "oci://"), bottleFile)
 	// 	if err != nil {
 	// 		return fmt.Errorf("[%s] downloading bottle: %w", f.Name(), err)
 	// 	}
 	default:
-		return "", errors.Join(
+		return "", nil, errors.Join(
 			fmt.Errorf("unsupported URL scheme %q", u.Scheme),
-			deleteOnFailure(),
+			os.RemoveAll(link),
 		)
 	}
+}
 
-	slog.Debug("Downloaded " + bottleFileName)
+// verifyFile verifies that a file's SHA-256 digest matches the expected digest.
+func verifyFile(path, name, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening bottle file %s: %w", path, err)
+	}
+	defer f.Close()
 
-	return link, nil
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("reading bottle file %s: %w", path, err)
+	}
+
+	return verifyDigest(name, expected, hash)
+}
+
+// verifyDigest verifies that a hash's sum matches the expected SHA-256 digest.
+//
+// Bottles with no expected digest are not verified.
+func verifyDigest(name, expected string, h hash.Hash) error {
+	if expected == "" {
+		slog.Warn("Skipping verification of bottle with no SHA-256 digest", slog.String("formula", name))
+		return nil
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return errdef.NewDigestMismatchError(name, expected, actual)
+	}
+	return nil
 }
 
 /*
@@ -300,33 +394,3 @@ func downloadBottleOCI(ctx context.Context, client remote.Client, ref string, w
 	return nil
 }
 */
-
-// downloadBottleHTTP downloads a bottle using the given.
-func downloadBottleHTTP(ctx context.Context, client http.Client, header http.Header, ref string, w io.Writer) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
-	if err != nil {
-		return fmt.Errorf("preparing request: %w", err)
-	}
-
-	// Set the headers
-	req.Header = header
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check for a non-success status and handle
-	if !resputil.HTTPSuccess(resp) {
-		return resputil.HandleHTTPError(resp)
-	}
-
-	// Copy the response body to the provided writer
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,241 @@
+package brewreg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/errdef"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// bottleServer serves a bottle blob, optionally failing partway through.
+type bottleServer struct {
+	content     []byte
+	failures    int  // number of responses to abort halfway through
+	ignoreRange bool // respond to range requests with the entire file
+
+	mu     sync.Mutex
+	ranges []string // Range header of each request
+}
+
+// ServeHTTP implements http.Handler.
+func (s *bottleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	fail := len(s.ranges) <= s.failures
+	s.mu.Unlock()
+
+	if s.ignoreRange {
+		r.Header.Del("Range")
+	}
+
+	if fail {
+		// Promise the entire file but only send part of it
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(s.content[:len(s.content)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+
+	http.ServeContent(w, r, "bottle.tar.gz", time.Time{}, bytes.NewReader(s.content))
+}
+
+func testBottleFormula(rootURL string, content []byte) formula.PlatformFormula {
+	sum := sha256.Sum256(content)
+	return formula.PlatformFromV1(platform.X8664Linux, &brewv1.PlatformInfo{
+		Name:     "cowsay",
+		Versions: brewv1.Versions{Stable: "3.04"},
+		Bottle: map[string]*brewv1.Bottle{
+			brewv1.Stable: {
+				RootURL: rootURL,
+				Files: map[platform.Platform]*brewv1.BottleFile{
+					platform.X8664Linux: {Sha256: hex.EncodeToString(sum[:])},
+				},
+			},
+		},
+	})
+}
+
+func Test_registry_download(t *testing.T) {
+	content := bytes.Repeat([]byte("hops bottle content\n"), 4096)
+	half := strconv.Itoa(len(content) / 2)
+
+	tests := []struct {
+		name       string
+		server     *bottleServer
+		served     []byte // content served when different from the expected content
+		partial    []byte // partial download left by a previous run
+		cached     []byte // completed download left by a previous run
+		wantRanges []string
+		wantErr    bool // want a digest mismatch
+	}{
+		{
+			name:       "Complete",
+			server:     &bottleServer{},
+			wantRanges: []string{""},
+		},
+		{
+			name:       "ResumeAfterDisconnect",
+			server:     &bottleServer{failures: 1},
+			wantRanges: []string{"", "bytes=" + half + "-"},
+		},
+		{
+			name:       "ResumeAfterRepeatedDisconnects",
+			server:     &bottleServer{failures: 2},
+			wantRanges: []string{"", "bytes=" + half + "-", "bytes=" + half + "-"},
+		},
+		{
+			name:       "ResumePartialFile",
+			server:     &bottleServer{},
+			partial:    content[:len(content)/2],
+			wantRanges: []string{"bytes=" + half + "-"},
+		},
+		{
+			name:       "RangeNotSupported",
+			server:     &bottleServer{ignoreRange: true},
+			partial:    content[:len(content)/2],
+			wantRanges: []string{"bytes=" + half + "-"},
+		},
+		{
+			name:       "CompletePartialFile",
+			server:     &bottleServer{},
+			partial:    content,
+			wantRanges: []string{"bytes=" + strconv.Itoa(len(content)) + "-"},
+		},
+		{
+			name:       "CachedFile",
+			server:     &bottleServer{},
+			cached:     content,
+			wantRanges: nil,
+		},
+		{
+			name:       "CorruptCachedFile",
+			server:     &bottleServer{},
+			cached:     []byte("corrupt"),
+			wantRanges: []string{""},
+		},
+		{
+			name:       "DigestMismatch",
+			server:     &bottleServer{},
+			served:     []byte("not the bottle"),
+			wantRanges: []string{""},
+			wantErr:    true,
+		},
+		{
+			name:       "CorruptPartialFile",
+			server:     &bottleServer{},
+			partial:    []byte("corrupt"),
+			wantRanges: []string{"bytes=7-"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.content = content
+			if tt.served != nil {
+				tt.server.content = tt.served
+			}
+			srv := httptest.NewServer(tt.server)
+			defer srv.Close()
+
+			store := newRegistry(nil, srv.Client(), t.TempDir(), 1, "", "")
+			store.backoff = time.Millisecond
+
+			f := testBottleFormula(srv.URL, content)
+			source, err := store.Source(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			urlsum := sha256.Sum256([]byte(source))
+			file := filepath.Join(store.cache, "downloads", hex.EncodeToString(urlsum[:])+"--"+formula.BottleFileName(f))
+
+			for path, data := range map[string][]byte{file + ".incomplete": tt.partial, file: tt.cached} {
+				if data == nil {
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0o775); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			link, err := store.download(context.Background(), f)
+
+			if !slices.Equal(tt.server.ranges, tt.wantRanges) {
+				t.Errorf("registry.download() requested ranges %q, want %q", tt.server.ranges, tt.wantRanges)
+			}
+
+			if tt.wantErr {
+				if !errors.As(err, &errdef.DigestMismatchError{}) {
+					t.Fatalf("registry.download() error = %v, want errdef.DigestMismatchError", err)
+				}
+				if _, err := os.Stat(file + ".incomplete"); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("registry.download() kept corrupt partial file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("registry.download() error = %v", err)
+			}
+
+			got, err := os.ReadFile(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("registry.download() saved %d bytes, want %d", len(got), len(content))
+			}
+			if _, err := os.Stat(file + ".incomplete"); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("registry.download() kept partial file")
+			}
+		})
+	}
+}
+
+func Test_registry_DownloadBottle(t *testing.T) {
+	content := []byte("hops bottle content\n")
+	server := &bottleServer{content: content}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	store := newRegistry(nil, srv.Client(), t.TempDir(), 1, "", "")
+	f := testBottleFormula(srv.URL, content)
+	link := filepath.Join(store.cache, linkName(f))
+
+	// Downloaded, cached, then downloaded again after the cached file is corrupted
+	for i, want := range []bool{false, true, false} {
+		if i == 2 {
+			if err := os.WriteFile(link, []byte("corrupt"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cached, err := store.DownloadBottle(context.Background(), f)
+		if err != nil {
+			t.Fatalf("registry.DownloadBottle() error = %v", err)
+		}
+		if cached != want {
+			t.Errorf("registry.DownloadBottle() call %d = %v, want %v", i+1, cached, want)
+		}
+	}
+
+	if len(server.ranges) != 2 {
+		t.Errorf("registry.DownloadBottle() made %d requests, want 2", len(server.ranges))
+	}
+}
@@ -0,0 +1,333 @@
+package brewreg
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/act3-ai/hops/internal/utils/logutil"
+	"github.com/act3-ai/hops/internal/utils/resputil"
+)
+
+// downloadStream streams a bottle download, saving it to a partial file as it is read.
+//
+// Interrupted downloads are resumed with range requests when the server
+// supports them. Once the stream has been read to the end, the download is
+// verified and moved into place.
+type downloadStream struct {
+	ctx      context.Context
+	store    *registry
+	ref      string // bottle URL
+	name     string // formula name
+	expected string // expected SHA-256 digest
+	file     string // path the verified download is moved to
+	link     string // cache symlink to file
+
+	w        *partialFile
+	body     io.ReadCloser // current response body
+	sent     int64         // number of bytes returned to the reader
+	complete bool          // the source has sent the entire file
+	closed   bool          // the partial file is closed
+	err      error         // error returned by all future reads
+
+	attempt int           // number of failed attempts
+	backoff time.Duration // delay before the next retry
+}
+
+// Read implements io.Reader.
+func (s *downloadStream) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.read(p)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+func (s *downloadStream) read(p []byte) (int, error) {
+	for {
+		switch {
+		// Replay content saved by a previous attempt or run
+		case s.sent < s.w.size:
+			n, err := s.w.file.ReadAt(p[:min(int64(len(p)), s.w.size-s.sent)], s.sent)
+			s.sent += int64(n)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return n, fmt.Errorf("reading download file: %w", err)
+			}
+			return n, nil
+		// Download is complete
+		case s.complete:
+			return 0, s.finish()
+		// Request the rest of the download
+		case s.body == nil:
+			if err := s.connect(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		n, err := s.body.Read(p)
+		if n > 0 {
+			if _, err := s.w.Write(p[:n]); err != nil {
+				return 0, fmt.Errorf("saving download file: %w", err)
+			}
+			// Skip content the reader already received if the server restarted the download
+			if skip := min(s.sent-(s.w.size-int64(n)), int64(n)); skip > 0 {
+				n = copy(p, p[skip:n])
+			}
+			s.sent += int64(n)
+		}
+
+		switch {
+		// Source has sent the entire file
+		case errors.Is(err, io.EOF):
+			s.complete = true
+			err = s.body.Close()
+			s.body = nil
+			if err != nil {
+				slog.Debug("Closing response body", logutil.ErrAttr(err))
+			}
+		// Connection failed, resume the download on the next read
+		case err != nil:
+			_ = s.body.Close()
+			s.body = nil
+			if err := s.wait(err); err != nil {
+				return n, err
+			}
+		}
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// connect requests the rest of the download, retrying retryable failures.
+func (s *downloadStream) connect() error {
+	for {
+		err := s.request()
+		if err == nil {
+			return nil
+		}
+		if err := s.wait(err); err != nil {
+			return err
+		}
+	}
+}
+
+// request requests the download, starting from the end of the partial file
+// when the server supports range requests.
+func (s *downloadStream) request() error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.ref, nil)
+	if err != nil {
+		return fmt.Errorf("preparing request: %w", err)
+	}
+
+	// Set the headers
+	req.Header = s.store.headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if s.w.size > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", s.w.size))
+	}
+
+	resp, err := s.store.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	switch {
+	// Server resumed the download
+	case resp.StatusCode == http.StatusPartialContent:
+		if start := contentRangeStart(resp.Header.Get("Content-Range")); start != s.w.size {
+			return errors.Join(
+				fmt.Errorf("server resumed download at byte %d instead of %d", start, s.w.size),
+				resp.Body.Close(),
+				s.w.reset(),
+			)
+		}
+		slog.Debug("Resuming bottle download", slog.Int64("offset", s.w.size))
+	// Partial file is already complete
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && s.w.size > 0:
+		s.complete = true
+		return resp.Body.Close()
+	// Server sent the entire file
+	case resputil.HTTPSuccess(resp):
+		if err := s.w.reset(); err != nil {
+			return errors.Join(err, resp.Body.Close())
+		}
+	// Check for a non-success status and handle
+	default:
+		defer resp.Body.Close()
+		return resputil.HandleHTTPError(resp)
+	}
+
+	s.body = resp.Body
+	return nil
+}
+
+// wait waits before the download is retried after a failure.
+// Returns err if the download should not be retried.
+func (s *downloadStream) wait(err error) error {
+	s.attempt++
+	if s.attempt > s.store.retries || !retryable(s.ctx, err) {
+		return err
+	}
+
+	slog.Warn("Retrying bottle download",
+		slog.String("formula", s.name),
+		slog.Int("attempt", s.attempt),
+		slog.Int64("offset", s.w.size),
+		slog.Duration("backoff", s.backoff),
+		logutil.ErrAttr(err))
+
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-time.After(s.backoff):
+	}
+	s.backoff *= 2
+	return nil
+}
+
+// finish verifies the completed download and moves it into place.
+//
+// Returns io.EOF if the download is intact.
+func (s *downloadStream) finish() error {
+	partial := s.w.file.Name()
+	s.closed = true
+	err := errors.Join(
+		verifyDigest(s.name, s.expected, s.w.hash),
+		s.w.Close(),
+	)
+	if err != nil {
+		// A corrupt partial file cannot be resumed
+		return errors.Join(err, os.RemoveAll(partial), os.RemoveAll(s.link))
+	}
+
+	err = os.Rename(partial, s.file)
+	if err != nil {
+		return errors.Join(
+			fmt.Errorf("saving download file: %w", err),
+			os.RemoveAll(partial),
+			os.RemoveAll(s.link),
+		)
+	}
+
+	slog.Debug("Downloaded " + filepath.Base(s.file))
+	return io.EOF
+}
+
+// Close implements io.Closer.
+//
+// The partial file is kept if the download is incomplete so it can be resumed.
+func (s *downloadStream) Close() error {
+	var err error
+	if s.body != nil {
+		err = s.body.Close()
+		s.body = nil
+	}
+	if !s.closed {
+		s.closed = true
+		err = errors.Join(err, s.w.Close())
+	}
+	return err
+}
+
+// retryable reports whether a failed download should be retried.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var httpErr *resputil.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError ||
+			httpErr.StatusCode == http.StatusRequestTimeout ||
+			httpErr.StatusCode == http.StatusTooManyRequests
+	}
+	// Connection errors are retried
+	return true
+}
+
+// contentRangeStart parses the first byte position from a Content-Range header.
+//
+// Returns -1 if the header cannot be parsed.
+func contentRangeStart(header string) int64 {
+	// Content-Range: bytes 200-1000/67589
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// partialFile is a partially downloaded file that hashes its content as it is written.
+type partialFile struct {
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+// openPartialFile opens a partial download file, hashing any existing content.
+func openPartialFile(path string) (*partialFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening download file: %w", err)
+	}
+
+	// Reading to the end leaves the offset positioned for appending
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("reading download file: %w", err), f.Close())
+	}
+
+	return &partialFile{file: f, hash: h, size: n}, nil
+}
+
+// Write implements io.Writer.
+func (w *partialFile) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+// reset discards the content of the partial file.
+func (w *partialFile) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncating download file: %w", err)
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("truncating download file: %w", err)
+	}
+	w.hash.Reset()
+	w.size = 0
+	return nil
+}
+
+// Close implements io.Closer.
+func (w *partialFile) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,78 @@
+package brewreg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	brewannotations "github.com/act3-ai/hops/internal/apis/sh.brew.bottle"
+	tab "github.com/act3-ai/hops/internal/apis/sh.brew.tab"
+	brewfmt "github.com/act3-ai/hops/internal/brew/fmt"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/utils/resputil"
+)
+
+// FetchTab implements bottle.TabRegistry.
+//
+// The Tab is read from the annotations of the bottle's entry in the
+// image index tagged with the bottle's version.
+func (store *registry) FetchTab(ctx context.Context, f formula.PlatformFormula) (*tab.Tab, error) {
+	btl := f.Bottle()
+	if btl == nil {
+		return nil, nil
+	}
+
+	source, err := store.resolveURL(btl.RootURL, "/"+brewfmt.Repo(f.Name())+"/manifests/"+formula.Tag(f))
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := store.fetchIndex(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("fetching bottle index: %w", err)
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Annotations[brewannotations.AnnotationBottleDigest] == btl.Sha256 {
+			return tab.FromAnnotations(desc.Annotations)
+		}
+	}
+
+	return nil, nil
+}
+
+// fetchIndex fetches an OCI image index.
+func (store *registry) fetchIndex(ctx context.Context, ref string) (*ocispec.Index, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+	if err != nil {
+		return nil, fmt.Errorf("preparing request: %w", err)
+	}
+
+	// Set the headers
+	req.Header = store.headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Accept", ocispec.MediaTypeImageIndex)
+
+	resp, err := store.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check for a non-success status and handle
+	if !resputil.HTTPSuccess(resp) {
+		return nil, resputil.HandleHTTPError(resp)
+	}
+
+	index := &ocispec.Index{}
+	if err := json.NewDecoder(resp.Body).Decode(index); err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+
+	return index, nil
+}
@@ -1,19 +1,27 @@
-// Package brewfile implements a simple Brewfile parser.
+// Package brewfile implements a Brewfile parser.
 package brewfile
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"strings"
+	"slices"
+
+	"github.com/act3-ai/hops/internal/platform"
 )
 
 // Brewfile represents a "brew bundle" Brewfile.
+//
+// A parsed Brewfile contains the entries for every platform.
+// Use ForPlatform to select the entries for a target platform.
 type Brewfile struct {
 	Tap     []string // Homebrew Taps
 	Formula []string // Homebrew Formulae
+	Brew    []*Brew  // Homebrew Formulae with their options
+
+	tapWhen []condition // platforms each tap is made on
 	// CaskArgs  []string // Arguments passed to
 	// Cask      []string
 	// MAS       []string
@@ -21,6 +29,46 @@ type Brewfile struct {
 	// VSCode    []string
 }
 
+// Brew represents a formula entry in a Brewfile:
+//
+//	brew "postgresql@16", restart_service: :changed, link: false
+type Brew struct {
+	Name string // formula name
+
+	// Arguments for installing the formula, such as "HEAD"
+	Args []string
+
+	// Whether to link the formula, nil links it unless it is keg-only
+	Link *bool
+
+	// Delete files that already exist in the prefix while linking
+	Overwrite bool
+
+	// Formulae to unlink before installing the formula
+	ConflictsWith []string
+
+	// When to restart the formula's service
+	RestartService RestartService
+
+	// Start the formula's service after installing it
+	StartService bool
+
+	// All options of the entry as written
+	Options map[string]any
+
+	when condition // platforms the entry is made on
+}
+
+// RestartService specifies when a formula's service is restarted.
+type RestartService string
+
+// RestartService values.
+const (
+	RestartNever   RestartService = ""        // never restart the service
+	RestartAlways  RestartService = "always"  // restart the service on every install
+	RestartChanged RestartService = "changed" // restart the service if the formula was installed or upgraded
+)
+
 // Load loads a Brewfile.
 func Load(path string) (*Brewfile, error) {
 	file, err := os.Open(path)
@@ -37,10 +85,26 @@ func Load(path string) (*Brewfile, error) {
 }
 
 // Parse parses a Brewfile from an io.Reader.
+//
+// Brewfiles are Ruby, of which the literal subset used by Brewfiles is
+// supported: strings, symbols, booleans, integers, arrays, hashes, and
+// if/unless conditionals on OS.mac?, OS.linux?, and Hardware::CPU. Entries
+// in conditionals are kept with their conditions for ForPlatform.
 func Parse(r io.Reader) (*Brewfile, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading Brewfile: %w", err)
+	}
+
+	calls, err := parseCalls(string(src))
+	if err != nil {
+		return nil, err
+	}
+
 	bf := &Brewfile{
 		Tap:     []string{},
 		Formula: []string{},
+		Brew:    []*Brew{},
 		// CaskArgs:  []string{},
 		// Cask:      []string{},
 		// MAS:       []string{},
@@ -48,57 +112,179 @@ func Parse(r io.Reader) (*Brewfile, error) {
 		// VSCode:    []string{},
 	}
 
-	// Iterate through lines of the file
-	scanner := bufio.NewScanner(r)
-	i := 0
-	for scanner.Scan() {
-		line := scanner.Text() // read line into string
-		i++
-
-		// Skip empty lines and comment lines
-		if strings.TrimSpace(line) == "" ||
-			strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		args := strings.Fields(line)
-
-		// Skip lines with too few arguments
-		if len(args) < 2 {
-			continue
-		}
-
-		// Identifies lines kind
-		kind := args[0]
-		// Remove trailing comma and quotes
-		name := strings.Trim(strings.TrimSuffix(args[1], ","), `"'`)
-
-		switch kind {
+	for _, c := range calls {
+		switch c.method {
 		case "tap":
+			name, err := c.name()
+			if err != nil {
+				return nil, err
+			}
 			bf.Tap = append(bf.Tap, name)
+			bf.tapWhen = append(bf.tapWhen, c.when)
 		case "brew":
-			bf.Formula = append(bf.Formula, name)
+			brew, err := c.brew()
+			if err != nil {
+				return nil, err
+			}
+			bf.Formula = append(bf.Formula, brew.Name)
+			bf.Brew = append(bf.Brew, brew)
 		case "cask_args", "cask", "mas", "whalebrew", "vscode":
 			// Catch all recognized fields, warn that they are being ignored
-			slog.Warn("skipping unsupported Brewfile entry", slog.String("kind", kind), slog.Int("lineNumber", i), slog.String("line", line))
+			slog.Warn("skipping unsupported Brewfile entry", slog.String("kind", c.method), slog.Int("lineNumber", c.line))
 			continue
-		// case "cask_args":
-		// 	// Append unmodified, don't want to parse it more than this
-		// 	bf.CaskArgs = append(bf.CaskArgs, args[1])
-		// case "cask":
-		// 	bf.Cask = append(bf.Cask, name)
-		// case "mas":
-		// 	bf.MAS = append(bf.MAS, name)
-		// case "whalebrew":
-		// 	bf.Whalebrew = append(bf.Whalebrew, name)
-		// case "vscode":
-		// 	bf.VSCode = append(bf.VSCode, name)
 		default:
-			// slog.Debug("skipping unsupported Brewfile line", slog.String("line", line))
-			// continue
-			return nil, fmt.Errorf("could not parse Brewfile:%d: unrecognized argument \"%s\" in line: %s", i, kind, line)
+			return nil, fmt.Errorf("could not parse Brewfile:%d: unrecognized argument \"%s\"", c.line, c.method)
 		}
 	}
 
 	return bf, nil
 }
+
+// ForPlatform selects the entries made on a platform, or on any supported
+// platform for platform.All.
+//
+// Formulae listed in several branches of a conditional are only selected once,
+// with the options of the first entry selected.
+func (bf *Brewfile) ForPlatform(plat platform.Platform) *Brewfile {
+	plats := plat.Computed()
+	if plats == nil {
+		plats = []platform.Platform{plat}
+	}
+	holds := func(when condition) bool {
+		return when == nil || slices.ContainsFunc(plats, when)
+	}
+
+	selected := &Brewfile{
+		Tap:     []string{},
+		Formula: []string{},
+		Brew:    []*Brew{},
+	}
+	for i, tap := range bf.Tap {
+		if i < len(bf.tapWhen) && !holds(bf.tapWhen[i]) {
+			continue
+		}
+		if !slices.Contains(selected.Tap, tap) {
+			selected.Tap = append(selected.Tap, tap)
+		}
+	}
+	for _, brew := range bf.Brew {
+		if !holds(brew.when) || slices.Contains(selected.Formula, brew.Name) {
+			continue
+		}
+		selected.Formula = append(selected.Formula, brew.Name)
+		selected.Brew = append(selected.Brew, brew)
+	}
+	return selected
+}
+
+// name produces the name given as the first argument of an entry.
+func (c *call) name() (string, error) {
+	if len(c.args) == 0 {
+		return "", c.errorf("missing name")
+	}
+	name, ok := c.args[0].(string)
+	if !ok || name == "" {
+		return "", c.errorf("name must be a string")
+	}
+	return name, nil
+}
+
+// brew produces a formula entry from a brew call.
+func (c *call) brew() (*Brew, error) {
+	name, err := c.name()
+	if err != nil {
+		return nil, err
+	}
+
+	brew := &Brew{
+		Name:    name,
+		Options: c.options,
+		when:    c.when,
+	}
+	if brew.Options == nil {
+		brew.Options = map[string]any{}
+	}
+
+	for key, value := range brew.Options {
+		switch key {
+		case "args":
+			brew.Args, err = stringList(value)
+			if err != nil {
+				return nil, c.errorf("%s: args %s", name, err.Error())
+			}
+		case "conflicts_with":
+			brew.ConflictsWith, err = stringList(value)
+			if err != nil {
+				return nil, c.errorf("%s: conflicts_with %s", name, err.Error())
+			}
+		case "link":
+			switch v := value.(type) {
+			case bool:
+				brew.Link = &v
+			case Symbol:
+				if v != "overwrite" {
+					return nil, c.errorf("%s: link must be true, false, or :overwrite", name)
+				}
+				link := true
+				brew.Link = &link
+				brew.Overwrite = true
+			case nil:
+			default:
+				return nil, c.errorf("%s: link must be true, false, or :overwrite", name)
+			}
+		case "restart_service":
+			switch v := value.(type) {
+			case bool:
+				if v {
+					brew.RestartService = RestartAlways
+				}
+			case Symbol:
+				if v != "changed" && v != "always" {
+					return nil, c.errorf("%s: restart_service must be true, false, :always, or :changed", name)
+				}
+				brew.RestartService = RestartService(v)
+			case nil:
+			default:
+				return nil, c.errorf("%s: restart_service must be true, false, :always, or :changed", name)
+			}
+		case "start_service":
+			v, ok := value.(bool)
+			if !ok && value != nil {
+				return nil, c.errorf("%s: start_service must be true or false", name)
+			}
+			brew.StartService = v
+		}
+	}
+
+	return brew, nil
+}
+
+// errorf produces an error for an entry.
+func (c *call) errorf(format string, a ...any) error {
+	return fmt.Errorf("could not parse Brewfile:%d: %s %s", c.line, c.method, fmt.Sprintf(format, a...))
+}
+
+// stringList converts a string, symbol, or array of them to a list of strings.
+func stringList(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case Symbol:
+		return []string{string(v)}, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			switch s := e.(type) {
+			case string:
+				list = append(list, s)
+			case Symbol:
+				list = append(list, string(s))
+			default:
+				return nil, errors.New("must be a list of strings")
+			}
+		}
+		return list, nil
+	default:
+		return nil, errors.New("must be a list of strings")
+	}
+}
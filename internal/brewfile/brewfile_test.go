@@ -0,0 +1,188 @@
+package brewfile
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+func TestParse(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		src  string
+		want []*Brew
+	}{
+		{
+			name: "Names",
+			src: `
+				tap "homebrew/core"
+				brew "jq"
+				brew 'yq' # comment
+				brew("gh"); brew "go"
+				`,
+			want: []*Brew{{Name: "jq"}, {Name: "yq"}, {Name: "gh"}, {Name: "go"}},
+		},
+		{
+			name: "Options",
+			src: `
+				brew "postgresql@16", restart_service: :changed, link: false
+				brew "mysql", :restart_service => true, "link" => :overwrite
+				brew "vim", args: ["HEAD", :with_lua], conflicts_with: "vi", start_service: true
+				`,
+			want: []*Brew{
+				{Name: "postgresql@16", RestartService: RestartChanged, Link: &no},
+				{Name: "mysql", RestartService: RestartAlways, Link: &yes, Overwrite: true},
+				{Name: "vim", Args: []string{"HEAD", "with_lua"}, ConflictsWith: []string{"vi"}, StartService: true},
+			},
+		},
+		{
+			name: "MultiLineCalls",
+			src: `
+				brew "vim",
+				  args: [
+				    "HEAD",
+				  ],
+				  link: true
+				brew(
+				  "gh",
+				  restart_service: :always
+				)
+				brew "jq", \
+				  link: false
+				`,
+			want: []*Brew{
+				{Name: "vim", Args: []string{"HEAD"}, Link: &yes},
+				{Name: "gh", RestartService: RestartAlways},
+				{Name: "jq", Link: &no},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bf, err := Parse(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(bf.Brew) != len(tt.want) {
+				t.Fatalf("Parse() = %d entries, want %d", len(bf.Brew), len(tt.want))
+			}
+			for i, got := range bf.Brew {
+				// Only compare the interpreted options
+				got.Options, got.when = nil, nil
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("Parse() entry %d = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseConditionals(t *testing.T) {
+	src := `
+		brew "everywhere"
+		if OS.mac?
+		  brew "mac"
+		elsif OS.linux? then brew "linux"
+		else
+		  brew "other"
+		end
+		unless Hardware::CPU.arm?
+		  brew "intel"
+		end
+		brew "arm-mac" if OS.mac? && Hardware::CPU.arm?
+		brew "not-arm-mac" unless (OS.mac? and Hardware::CPU.arm?)
+		brew "never" if false
+		if OS.linux?
+		  if Hardware::CPU.intel?
+		    brew "intel-linux"
+		  end
+		end
+		`
+	bf, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		plat platform.Platform
+		want []string
+	}{
+		{platform.Arm64Sonoma, []string{"everywhere", "mac", "arm-mac"}},
+		{platform.Sonoma, []string{"everywhere", "mac", "intel", "not-arm-mac"}},
+		{platform.X8664Linux, []string{"everywhere", "linux", "intel", "not-arm-mac", "intel-linux"}},
+		{platform.All, []string{"everywhere", "mac", "linux", "intel", "arm-mac", "not-arm-mac", "intel-linux"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plat.String(), func(t *testing.T) {
+			got := bf.ForPlatform(tt.plat)
+			if !slices.Equal(got.Formula, tt.want) {
+				t.Errorf("ForPlatform() formulae = %v, want %v", got.Formula, tt.want)
+			}
+			if len(got.Brew) != len(got.Formula) {
+				t.Errorf("ForPlatform() = %d entries for %d formulae", len(got.Brew), len(got.Formula))
+			}
+		})
+	}
+}
+
+func TestForPlatformDuplicates(t *testing.T) {
+	src := `
+		tap "homebrew/core"
+		if OS.mac?
+		  brew "vim", link: true
+		else
+		  brew "vim", link: false
+		end
+		`
+	bf, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := bf.ForPlatform(platform.All)
+	if !slices.Equal(got.Formula, []string{"vim"}) || !slices.Equal(got.Tap, []string{"homebrew/core"}) {
+		t.Fatalf("ForPlatform() = %v, %v, want one tap and one formula", got.Tap, got.Formula)
+	}
+	if link := got.Brew[0].Link; link == nil || !*link {
+		t.Errorf("ForPlatform() kept options of a later entry")
+	}
+
+	// Entries for a Linux system keep their own options
+	got = bf.ForPlatform(platform.X8664Linux)
+	if link := got.Brew[0].Link; link == nil || *link {
+		t.Errorf("ForPlatform() kept options of another platform")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"UnknownMethod", `system "rm -rf /"`},
+		{"Interpolation", `brew "#{name}"`},
+		{"UnterminatedString", `brew "jq`},
+		{"UnsupportedCondition", `brew "jq" if ENV["CI"]`},
+		{"MissingEnd", "if OS.mac?\n  brew \"jq\"\n"},
+		{"ElsifInUnless", "unless OS.mac?\n  brew \"jq\"\nelsif OS.linux?\nend\n"},
+		{"PositionalAfterOption", `brew link: false, "jq"`},
+		{"Variable", "name = \"jq\"\nbrew name\n"},
+		{"TrailingTokens", `brew "jq" "yq"`},
+		{"MissingName", `brew link: false`},
+		{"InvalidLink", `brew "jq", link: "yes"`},
+		{"InvalidRestartService", `brew "jq", restart_service: :sometimes`},
+		{"InvalidArgs", `brew "jq", args: [1]`},
+		{"HashKey", `brew "jq", { 1 => true }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if bf, err := Parse(strings.NewReader(tt.src)); err == nil {
+				t.Errorf("Parse() = %+v, want error", bf)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package brewfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// LockFileSuffix is appended to a Brewfile's path to name its lock file.
+const LockFileSuffix = ".lock.json"
+
+// Lock represents a Brewfile lock file, recording the exact bottles
+// resolved for every formula in a Brewfile's dependency closure.
+type Lock struct {
+	Entries LockEntries `json:"entries"`
+}
+
+// LockEntries stores the locked entries by kind.
+type LockEntries struct {
+	Brew map[string]*LockedFormula `json:"brew"` // locked formulae by name
+}
+
+// LockedFormula records the resolved bottles of a formula.
+type LockedFormula struct {
+	Version string                              `json:"version"`          // pkg version
+	Tag     string                              `json:"tag,omitempty"`    // bottle tag in the registry, empty if locked from the Homebrew API
+	Digest  string                              `json:"digest,omitempty"` // digest of the bottle index, empty if locked from the Homebrew API
+	Bottles map[platform.Platform]*LockedBottle `json:"bottles"`          // bottles by platform
+}
+
+// LockedBottle records the resolved bottle for a platform.
+type LockedBottle struct {
+	Manifest string `json:"manifest,omitempty"` // digest of the platform's bottle manifest, empty if locked from the Homebrew API
+	Blob     string `json:"blob"`               // digest of the bottle archive
+}
+
+// NewLock creates an empty Lock.
+func NewLock() *Lock {
+	return &Lock{
+		Entries: LockEntries{
+			Brew: map[string]*LockedFormula{},
+		},
+	}
+}
+
+// LockPath produces the path of the lock file for a Brewfile.
+func LockPath(brewfile string) string {
+	return brewfile + LockFileSuffix
+}
+
+// LoadLock loads a lock file.
+func LoadLock(path string) (*Lock, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file %s: %w", path, err)
+	}
+
+	lock := NewLock()
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("loading lock file %s: %w", path, err)
+	}
+	if lock.Entries.Brew == nil {
+		lock.Entries.Brew = map[string]*LockedFormula{}
+	}
+	return lock, nil
+}
+
+// LoadLockIfExists loads a lock file, returning nil if the file does not exist.
+func LoadLockIfExists(path string) (*Lock, error) {
+	lock, err := LoadLock(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return lock, err
+}
+
+// Write writes the lock file.
+func (lock *Lock) Write(path string) error {
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing lock file: %w", err)
+	}
+	return nil
+}
+
+// Formulae lists the names of the locked formulae in sorted order.
+func (lock *Lock) Formulae() []string {
+	return slices.Sorted(maps.Keys(lock.Entries.Brew))
+}
+
+// Tags maps the name of each locked formula to its bottle tag.
+// Formulae locked from the Homebrew API have no tag.
+func (lock *Lock) Tags() map[string]string {
+	tags := make(map[string]string, len(lock.Entries.Brew))
+	for name, f := range lock.Entries.Brew {
+		if f.Tag != "" {
+			tags[name] = f.Tag
+		}
+	}
+	return tags
+}
+
+// Formula produces the locked entry for the named formula.
+func (lock *Lock) Formula(name string) (*LockedFormula, error) {
+	f, ok := lock.Entries.Brew[name]
+	if !ok {
+		return nil, fmt.Errorf("formula %s is not in the lock file", name)
+	}
+	return f, nil
+}
@@ -0,0 +1,692 @@
+package brewfile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// Symbol is a Ruby symbol, such as :changed.
+//
+// Values parsed from a Brewfile are one of:
+//
+//	string, Symbol, bool, int, nil, []any, map[string]any
+//
+// Hash keys written as symbols or strings are both stored as strings.
+type Symbol string
+
+// tokenKind identifies the kind of a token.
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokNewline           // end of a statement
+	tokString            // "text" or 'text'
+	tokSymbol            // :name or :"name"
+	tokLabel             // name: or "name": as a hash key
+	tokIdent             // name, OS.mac?, Hardware::CPU.arm?
+	tokInt               // 42
+	tokPunct             // , [ ] { } ( ) => ! && ||
+)
+
+// token is a lexed token.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexer splits a Brewfile into tokens.
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+	toks []token
+}
+
+// lex splits a Brewfile into tokens.
+func lex(src string) ([]token, error) {
+	l := &lexer{src: []rune(src), line: 1}
+	for {
+		r, ok := l.peek(0)
+		if !ok {
+			l.emit(tokNewline, "")
+			l.emit(tokEOF, "")
+			return l.toks, nil
+		}
+
+		switch {
+		// Line continuation
+		case r == '\\' && l.is(1, '\n'):
+			l.pos += 2
+			l.line++
+		case r == ' ' || r == '\t' || r == '\r':
+			l.pos++
+		// Comments run to the end of the line
+		case r == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case r == '\n':
+			l.emit(tokNewline, "")
+			l.pos++
+			l.line++
+		case r == ';':
+			l.emit(tokNewline, "")
+			l.pos++
+		case r == '"' || r == '\'':
+			s, err := l.string(r)
+			if err != nil {
+				return nil, err
+			}
+			if l.is(0, ':') && !l.is(1, ':') {
+				l.pos++
+				l.emit(tokLabel, s)
+			} else {
+				l.emit(tokString, s)
+			}
+		case r == ':' && (l.is(1, '"') || l.is(1, '\'')):
+			l.pos++
+			q, _ := l.peek(0)
+			s, err := l.string(q)
+			if err != nil {
+				return nil, err
+			}
+			l.emit(tokSymbol, s)
+		case r == ':' && l.isIdentStart(1):
+			l.pos++
+			l.emit(tokSymbol, l.ident())
+		case unicode.IsDigit(r) || (r == '-' && l.isDigit(1)):
+			start := l.pos
+			l.pos++
+			for l.isDigit(0) || l.is(0, '_') {
+				l.pos++
+			}
+			l.emit(tokInt, strings.ReplaceAll(string(l.src[start:l.pos]), "_", ""))
+		case l.isIdentStart(0):
+			name := l.ident()
+			if l.is(0, ':') && !l.is(1, ':') {
+				l.pos++
+				l.emit(tokLabel, name)
+			} else {
+				l.emit(tokIdent, name)
+			}
+		case r == '=' && l.is(1, '>'),
+			r == '&' && l.is(1, '&'),
+			r == '|' && l.is(1, '|'):
+			l.emit(tokPunct, string(l.src[l.pos:l.pos+2]))
+			l.pos += 2
+		case strings.ContainsRune(",[]{}()!", r):
+			l.emit(tokPunct, string(r))
+			l.pos++
+		default:
+			return nil, fmt.Errorf("could not parse Brewfile:%d: unexpected character %q", l.line, r)
+		}
+	}
+}
+
+// emit adds a token.
+func (l *lexer) emit(kind tokenKind, text string) {
+	l.toks = append(l.toks, token{kind: kind, text: text, line: l.line})
+}
+
+// peek produces the rune at an offset from the current position.
+func (l *lexer) peek(offset int) (rune, bool) {
+	if l.pos+offset >= len(l.src) {
+		return 0, false
+	}
+	return l.src[l.pos+offset], true
+}
+
+// is reports whether the rune at an offset is r.
+func (l *lexer) is(offset int, r rune) bool {
+	c, ok := l.peek(offset)
+	return ok && c == r
+}
+
+// isDigit reports whether the rune at an offset is a digit.
+func (l *lexer) isDigit(offset int) bool {
+	c, ok := l.peek(offset)
+	return ok && unicode.IsDigit(c)
+}
+
+// isIdentStart reports whether an identifier starts at an offset.
+func (l *lexer) isIdentStart(offset int) bool {
+	c, ok := l.peek(offset)
+	return ok && (c == '_' || unicode.IsLetter(c))
+}
+
+// ident lexes an identifier, including constant paths and method calls
+// such as Hardware::CPU.arm?.
+func (l *lexer) ident() string {
+	start := l.pos
+	for {
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(l.src[l.pos]) || unicode.IsDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		switch {
+		case l.is(0, ':') && l.is(1, ':') && l.isIdentStart(2):
+			l.pos += 2
+		case l.is(0, '.') && l.isIdentStart(1):
+			l.pos++
+		case l.is(0, '?') || l.is(0, '!') && !l.is(1, '='):
+			l.pos++
+			return string(l.src[start:l.pos])
+		default:
+			return string(l.src[start:l.pos])
+		}
+	}
+}
+
+// string lexes a quoted string.
+func (l *lexer) string(quote rune) (string, error) {
+	line := l.line
+	l.pos++ // opening quote
+	b := &strings.Builder{}
+	for {
+		r, ok := l.peek(0)
+		switch {
+		case !ok:
+			return "", fmt.Errorf("could not parse Brewfile:%d: unterminated string", line)
+		case r == quote:
+			l.pos++
+			return b.String(), nil
+		case r == '\n':
+			l.line++
+		case quote == '"' && r == '#' && l.is(1, '{'):
+			return "", fmt.Errorf("could not parse Brewfile:%d: string interpolation is not supported", line)
+		case r == '\\':
+			l.pos++
+			e, ok := l.peek(0)
+			if !ok {
+				continue
+			}
+			switch {
+			// Single-quoted strings only escape quotes and backslashes
+			case quote == '\'' && e != '\'' && e != '\\':
+				b.WriteRune('\\')
+				r = e
+			case e == 'n':
+				r = '\n'
+			case e == 't':
+				r = '\t'
+			case e == 'e':
+				r = '\x1b'
+			case e == '0':
+				r = 0
+			default:
+				r = e
+			}
+		}
+		b.WriteRune(r)
+		l.pos++
+	}
+}
+
+// parser evaluates the Ruby subset used by Brewfiles.
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// call is a method call statement, such as brew "jq".
+type call struct {
+	method  string
+	args    []any
+	options map[string]any
+	line    int
+	when    condition // platforms the call is made on
+}
+
+// condition reports whether a conditional holds on a platform.
+type condition func(plat platform.Platform) bool
+
+// always holds on every platform.
+func always(platform.Platform) bool { return true }
+
+// never holds on no platform.
+func never(platform.Platform) bool { return false }
+
+// and holds when both conditions hold.
+func and(a, b condition) condition {
+	return func(plat platform.Platform) bool { return a(plat) && b(plat) }
+}
+
+// or holds when either condition holds.
+func or(a, b condition) condition {
+	return func(plat platform.Platform) bool { return a(plat) || b(plat) }
+}
+
+// not holds when the condition does not hold.
+func not(a condition) condition {
+	return func(plat platform.Platform) bool { return !a(plat) }
+}
+
+// isLinux reports whether a platform is Linux, for OS.linux?.
+func isLinux(plat platform.Platform) bool {
+	return strings.HasSuffix(string(plat), "_linux")
+}
+
+// isARM reports whether a platform is ARM, for Hardware::CPU.arm?.
+func isARM(plat platform.Platform) bool {
+	return strings.HasPrefix(string(plat), "arm64_")
+}
+
+// parseCalls parses the method calls of a Brewfile, recording the
+// conditionals each call is made under.
+func parseCalls(src string) ([]*call, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	calls, end, err := p.statements(always)
+	if err != nil {
+		return nil, err
+	}
+	if end.kind != tokEOF {
+		return nil, p.errorf(end, "unexpected %q", end.text)
+	}
+	return calls, nil
+}
+
+// errorf produces a parsing error for a token.
+func (p *parser) errorf(t token, format string, a ...any) error {
+	return fmt.Errorf("could not parse Brewfile:%d: %s", t.line, fmt.Sprintf(format, a...))
+}
+
+// peek produces the current token.
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+// next consumes the current token.
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the current token if it matches.
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the current token, failing if it does not match.
+func (p *parser) expect(kind tokenKind, text string) error {
+	if !p.accept(kind, text) {
+		t := p.peek()
+		return p.errorf(t, "expected %q but found %q", text, t.text)
+	}
+	return nil
+}
+
+// skipNewlines skips newlines, which are allowed inside brackets and after commas.
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokNewline {
+		p.pos++
+	}
+}
+
+// statements parses statements until the end of the file or a keyword
+// ending the block, which is returned unconsumed. The statements are made
+// on the platforms where guard holds.
+func (p *parser) statements(guard condition) ([]*call, token, error) {
+	calls := []*call{}
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return calls, t, nil
+		case t.kind == tokIdent && (t.text == "end" || t.text == "else" || t.text == "elsif"):
+			return calls, t, nil
+		case t.kind == tokIdent && (t.text == "if" || t.text == "unless"):
+			block, err := p.conditional(guard)
+			if err != nil {
+				return nil, t, err
+			}
+			calls = append(calls, block...)
+		case t.kind == tokIdent:
+			c, err := p.call()
+			if err != nil {
+				return nil, t, err
+			}
+			// Trailing conditional modifiers
+			c.when = guard
+			if kw := p.peek(); kw.kind == tokIdent && (kw.text == "if" || kw.text == "unless") {
+				p.next()
+				holds, err := p.condition()
+				if err != nil {
+					return nil, t, err
+				}
+				if kw.text == "unless" {
+					holds = not(holds)
+				}
+				c.when = and(guard, holds)
+			}
+			if end := p.peek(); end.kind != tokNewline {
+				return nil, t, p.errorf(end, "unexpected %q after %s", end.text, c.method)
+			}
+			calls = append(calls, c)
+		default:
+			return nil, t, p.errorf(t, "unexpected %q", t.text)
+		}
+	}
+}
+
+// conditional parses an if or unless block.
+//
+// Each branch is made where its condition holds and no earlier branch was taken.
+func (p *parser) conditional(guard condition) ([]*call, error) {
+	kw := p.next()
+	holds, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	if kw.text == "unless" {
+		holds = not(holds)
+	}
+	p.accept(tokIdent, "then")
+
+	calls := []*call{}
+	var taken condition = never
+	for {
+		block, end, err := p.statements(and(guard, and(not(taken), holds)))
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, block...)
+		taken = or(taken, holds)
+
+		switch {
+		case end.kind == tokIdent && end.text == "elsif" && kw.text == "if":
+			p.next()
+			holds, err = p.condition()
+			if err != nil {
+				return nil, err
+			}
+			p.accept(tokIdent, "then")
+		case end.kind == tokIdent && end.text == "else":
+			p.next()
+			holds = always
+		case end.kind == tokIdent && end.text == "end":
+			p.next()
+			return calls, nil
+		default:
+			return nil, p.errorf(kw, "%s without matching end", kw.text)
+		}
+	}
+}
+
+// condition parses a conditional expression.
+func (p *parser) condition() (condition, error) {
+	holds, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokPunct, "||") || p.accept(tokIdent, "or") {
+		rhs, err := p.conjunction()
+		if err != nil {
+			return nil, err
+		}
+		holds = or(holds, rhs)
+	}
+	return holds, nil
+}
+
+// conjunction parses expressions joined by &&.
+func (p *parser) conjunction() (condition, error) {
+	holds, err := p.predicate()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokPunct, "&&") || p.accept(tokIdent, "and") {
+		rhs, err := p.predicate()
+		if err != nil {
+			return nil, err
+		}
+		holds = and(holds, rhs)
+	}
+	return holds, nil
+}
+
+// predicate parses a negation, parenthesized expression, or platform predicate.
+func (p *parser) predicate() (condition, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokPunct && t.text == "!",
+		t.kind == tokIdent && t.text == "not":
+		holds, err := p.predicate()
+		if err != nil {
+			return nil, err
+		}
+		return not(holds), nil
+	case t.kind == tokPunct && t.text == "(":
+		holds, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		return holds, p.expect(tokPunct, ")")
+	case t.kind == tokIdent:
+		switch t.text {
+		case "true":
+			return always, nil
+		case "false", "nil":
+			return never, nil
+		case "OS.mac?":
+			return platform.Platform.IsMacOS, nil
+		case "OS.linux?":
+			return isLinux, nil
+		case "Hardware::CPU.arm?":
+			return isARM, nil
+		case "Hardware::CPU.intel?":
+			return not(isARM), nil
+		}
+	}
+	return nil, p.errorf(t, "unsupported condition %q", t.text)
+}
+
+// call parses a method call and its arguments.
+func (p *parser) call() (*call, error) {
+	t := p.next()
+	c := &call{method: t.text, line: t.line}
+
+	// Arguments may be parenthesized
+	closing := ""
+	if p.accept(tokPunct, "(") {
+		closing = ")"
+	}
+
+	for {
+		if closing != "" {
+			p.skipNewlines()
+			if p.accept(tokPunct, closing) {
+				return c, nil
+			}
+		} else if next := p.peek(); next.kind == tokNewline || next.kind == tokEOF ||
+			next.kind == tokIdent && (next.text == "if" || next.text == "unless") {
+			return c, nil
+		}
+
+		err := p.argument(c)
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.accept(tokPunct, ",") {
+			if closing != "" {
+				p.skipNewlines()
+				return c, p.expect(tokPunct, closing)
+			}
+			return c, nil
+		}
+		p.skipNewlines()
+	}
+}
+
+// argument parses a positional argument or a trailing hash pair.
+func (p *parser) argument(c *call) error {
+	if p.peek().kind == tokLabel {
+		key := p.next().text
+		p.skipNewlines()
+		value, err := p.value()
+		if err != nil {
+			return err
+		}
+		c.addOption(key, value)
+		return nil
+	}
+
+	t := p.peek()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	if p.accept(tokPunct, "=>") {
+		p.skipNewlines()
+		key, err := hashKey(value)
+		if err != nil {
+			return p.errorf(t, "%s", err.Error())
+		}
+		value, err := p.value()
+		if err != nil {
+			return err
+		}
+		c.addOption(key, value)
+		return nil
+	}
+
+	if c.options != nil {
+		return p.errorf(t, "positional argument after options")
+	}
+	c.args = append(c.args, value)
+	return nil
+}
+
+// addOption records an option of the call.
+func (c *call) addOption(key string, value any) {
+	if c.options == nil {
+		c.options = map[string]any{}
+	}
+	c.options[key] = value
+}
+
+// value parses a literal value.
+func (p *parser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokSymbol:
+		return Symbol(t.text), nil
+	case tokInt:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, p.errorf(t, "invalid integer %q", t.text)
+		}
+		return n, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "nil":
+			return nil, nil
+		}
+	case tokPunct:
+		switch t.text {
+		case "[":
+			return p.array()
+		case "{":
+			return p.hash()
+		}
+	}
+	return nil, p.errorf(t, "unsupported value %q", t.text)
+}
+
+// array parses the elements of an array literal.
+func (p *parser) array() ([]any, error) {
+	values := []any{}
+	for {
+		p.skipNewlines()
+		if p.accept(tokPunct, "]") {
+			return values, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipNewlines()
+		if !p.accept(tokPunct, ",") {
+			return values, p.expect(tokPunct, "]")
+		}
+	}
+}
+
+// hash parses the pairs of a hash literal.
+func (p *parser) hash() (map[string]any, error) {
+	values := map[string]any{}
+	for {
+		p.skipNewlines()
+		if p.accept(tokPunct, "}") {
+			return values, nil
+		}
+
+		t := p.peek()
+		var key string
+		switch t.kind {
+		case tokLabel:
+			key = p.next().text
+		default:
+			k, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			key, err = hashKey(k)
+			if err != nil {
+				return nil, p.errorf(t, "%s", err.Error())
+			}
+			if err := p.expect(tokPunct, "=>"); err != nil {
+				return nil, err
+			}
+		}
+
+		p.skipNewlines()
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+
+		p.skipNewlines()
+		if !p.accept(tokPunct, ",") {
+			return values, p.expect(tokPunct, "}")
+		}
+	}
+}
+
+// hashKey converts a value to a hash key.
+func hashKey(v any) (string, error) {
+	switch k := v.(type) {
+	case string:
+		return k, nil
+	case Symbol:
+		return string(k), nil
+	default:
+		return "", errors.New("hash keys must be strings or symbols")
+	}
+}
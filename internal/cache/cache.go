@@ -0,0 +1,261 @@
+// Package cache inspects and prunes the caches of downloaded bottles.
+//
+// Bottles downloaded from Homebrew's registry are kept in the downloads
+// directory of the Homebrew cache, and bottles pulled from a hops registry are
+// kept in an OCI layout for each formula in the oci directory of the hops cache.
+// Each download and each OCI layout is an Entry that can be pruned on its own.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/act3-ai/hops/internal/utils"
+)
+
+// Area is a part of the cache.
+type Area string
+
+// Cache areas.
+const (
+	AreaDownloads Area = "downloads" // bottles downloaded from Homebrew's registry
+	AreaOCI       Area = "oci"       // OCI layouts of bottles pulled from a hops registry
+	AreaAPI       Area = "api"       // Homebrew API index, never pruned
+)
+
+// Entry is a file or directory in the cache.
+type Entry struct {
+	Area     Area      `json:"area"`
+	Formula  string    `json:"formula,omitempty"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Cache locates the cache directories.
+type Cache struct {
+	Dir       string // hops cache directory
+	Downloads string // Homebrew cache directory
+}
+
+// OCIDir returns the directory of OCI layouts.
+func (c *Cache) OCIDir() string {
+	return filepath.Join(c.Dir, "oci")
+}
+
+// LockFile returns the lock file that keeps the cache from being pruned while
+// bottles are written to it.
+func (c *Cache) LockFile() string {
+	return filepath.Join(c.Dir, "cache.lock")
+}
+
+// APIDir returns the directory of the Homebrew API index.
+func (c *Cache) APIDir() string {
+	return filepath.Join(c.Dir, "api")
+}
+
+// DownloadsDir returns the directory of downloaded bottles.
+func (c *Cache) DownloadsDir() string {
+	return filepath.Join(c.Downloads, "downloads")
+}
+
+// Entries lists the entries in each area of the cache.
+func (c *Cache) Entries(_ context.Context) ([]Entry, error) {
+	downloads, err := c.downloads()
+	if err != nil {
+		return nil, err
+	}
+
+	layouts, err := c.layouts()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := slices.Concat(downloads, layouts)
+
+	// The API index is reported as a whole
+	api, err := dirEntry(AreaAPI, "", c.APIDir())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		entries = append(entries, api)
+	}
+
+	return entries, nil
+}
+
+// downloads lists the downloaded bottles, including partial downloads.
+//
+// Pattern:
+//
+//	URLSHA--NAME--VERSION.PLATFORM.bottle.tar.gz[.incomplete]
+func (c *Cache) downloads() ([]Entry, error) {
+	files, err := os.ReadDir(c.DownloadsDir())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("listing downloads: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, fmt.Errorf("listing downloads: %w", err)
+		}
+
+		name := ""
+		if _, rest, ok := strings.Cut(file.Name(), "--"); ok {
+			name, _, _ = strings.Cut(rest, "--")
+		}
+
+		entries = append(entries, Entry{
+			Area:     AreaDownloads,
+			Formula:  name,
+			Path:     filepath.Join(c.DownloadsDir(), file.Name()),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+// layoutFiles are the files of an OCI layout, which may be nested in the
+// directory of another layout.
+var layoutFiles = []string{ocispec.ImageBlobsDir, ocispec.ImageIndexFile, ocispec.ImageLayoutFile, "ingest"}
+
+// layouts lists the OCI layouts.
+//
+// Formulae are stored in repositories named by brewfmt.Repo, so
+// "openssl@3" is stored in the layout "openssl/3" inside the layout "openssl".
+func (c *Cache) layouts() ([]Entry, error) {
+	root := c.OCIDir()
+	entries := []Entry{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case !d.IsDir():
+			return nil
+		case isLayout(filepath.Dir(path)) && slices.Contains(layoutFiles, d.Name()):
+			return fs.SkipDir // contents of the parent layout
+		case !isLayout(path):
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entry, err := layoutEntry(strings.ReplaceAll(filepath.ToSlash(rel), "/", "@"), path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing OCI layouts: %w", err)
+	}
+	return entries, nil
+}
+
+// isLayout reports whether a directory is an OCI layout.
+func isLayout(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ocispec.ImageLayoutFile))
+	return err == nil
+}
+
+// layoutEntry creates an entry for an OCI layout, which is last used when its
+// directory was last modified.
+func layoutEntry(name, dir string) (Entry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		Area:     AreaOCI,
+		Formula:  name,
+		Path:     dir,
+		LastUsed: info.ModTime(),
+	}
+	for _, file := range layoutFiles {
+		path := filepath.Join(dir, file)
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return Entry{}, err
+		case !info.IsDir():
+			entry.Size += info.Size()
+			continue
+		}
+		_, size, err := utils.CountDir(path)
+		if err != nil {
+			return Entry{}, err
+		}
+		entry.Size += size
+	}
+	return entry, nil
+}
+
+// removeLayout removes an OCI layout, keeping the layouts nested in its directory.
+func removeLayout(dir string) error {
+	for _, file := range layoutFiles {
+		if err := os.RemoveAll(filepath.Join(dir, file)); err != nil {
+			return err
+		}
+	}
+	// Only removed if no layouts are nested in it
+	if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrExist) && !errors.Is(err, syscall.ENOTEMPTY) {
+		return err
+	}
+	return nil
+}
+
+// dirEntry creates an entry for a directory, which is last used when it was last modified.
+func dirEntry(area Area, name, dir string) (Entry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	_, size, err := utils.CountDir(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Area:     area,
+		Formula:  name,
+		Path:     dir,
+		Size:     size,
+		LastUsed: info.ModTime(),
+	}, nil
+}
+
+// Touch marks a cache entry as used now, so it is pruned last.
+func Touch(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return fmt.Errorf("marking cache entry as used: %w", err)
+	}
+	return nil
+}
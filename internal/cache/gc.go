@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// findGarbage finds the blobs in an OCI layout that are not referenced by
+// any manifest in its index, returning their paths and total size.
+//
+// Bottles are cached without tags, so the garbage collection of [oci.Store]
+// would remove every blob. Instead, every manifest in the index is a root.
+func findGarbage(ctx context.Context, dir string) ([]string, int64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ocispec.ImageIndexFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, 0, nil // nothing has been cached
+	case err != nil:
+		return nil, 0, fmt.Errorf("reading OCI layout index: %w", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, 0, fmt.Errorf("reading OCI layout index %s: %w", dir, err)
+	}
+
+	storage, err := oci.NewStorage(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening OCI layout: %w", err)
+	}
+
+	// Mark the content reachable from the index
+	reachable := map[string]bool{}
+	queue := index.Manifests
+	for len(queue) > 0 {
+		desc := queue[0]
+		queue = queue[1:]
+		if reachable[desc.Digest.String()] {
+			continue
+		}
+		reachable[desc.Digest.String()] = true
+
+		successors, err := content.Successors(ctx, storage, desc)
+		switch {
+		case errors.Is(err, errdef.ErrNotFound):
+			continue // referenced content that was never cached
+		case err != nil:
+			return nil, 0, fmt.Errorf("finding referenced blobs in %s: %w", dir, err)
+		}
+		queue = append(queue, successors...)
+	}
+
+	// Sweep the blobs that are not reachable
+	garbage := []string{}
+	var size int64
+	blobs := filepath.Join(dir, ocispec.ImageBlobsDir)
+	err = filepath.WalkDir(blobs, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case d.IsDir():
+			return nil
+		}
+
+		// Blobs are stored as blobs/ALGORITHM/ENCODED
+		alg := filepath.Base(filepath.Dir(path))
+		if reachable[alg+":"+d.Name()] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		garbage = append(garbage, path)
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("finding unreferenced blobs in %s: %w", dir, err)
+	}
+	return garbage, size, nil
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// PruneOptions configures which entries are pruned.
+type PruneOptions struct {
+	MaxAge  time.Duration // prune entries unused for longer than this, if non-zero
+	MaxSize int64         // prune the least recently used entries until the cache is no larger than this, if non-zero
+	All     bool          // prune every entry
+	DryRun  bool          // report what would be pruned without removing anything
+
+	Formulae []string // only prune the entries of these formulae, if set
+}
+
+// PruneResult reports what was pruned.
+type PruneResult struct {
+	Removed []Entry  // pruned entries
+	Garbage []string // unreferenced blobs collected from the remaining OCI layouts
+	Freed   int64    // total size of the pruned entries and blobs
+}
+
+// Prune removes cache entries according to the options, then collects the
+// unreferenced blobs of the OCI layouts that remain.
+//
+// The API index is never pruned.
+func (c *Cache) Prune(ctx context.Context, opts *PruneOptions) (*PruneResult, error) {
+	entries, err := c.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries = slices.DeleteFunc(entries, func(e Entry) bool { return e.Area == AreaAPI })
+
+	// The size budget still applies to the whole cache when only some formulae are pruned
+	prunable := func(e Entry) bool {
+		return len(opts.Formulae) == 0 || slices.Contains(opts.Formulae, e.Formula)
+	}
+
+	result := &PruneResult{}
+
+	// Collect garbage first, so the size budget applies to the content in use
+	for i, e := range entries {
+		if e.Area != AreaOCI || !prunable(e) {
+			continue
+		}
+		garbage, size, err := findGarbage(ctx, e.Path)
+		if err != nil {
+			return nil, err
+		}
+		result.Garbage = append(result.Garbage, garbage...)
+		result.Freed += size
+		entries[i].Size -= size
+	}
+
+	// Least recently used first
+	slices.SortStableFunc(entries, func(a, b Entry) int { return a.LastUsed.Compare(b.LastUsed) })
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		switch {
+		case !prunable(e):
+			continue
+		case opts.All:
+		case opts.MaxAge > 0 && now.Sub(e.LastUsed) > opts.MaxAge:
+		case opts.MaxSize > 0 && total > opts.MaxSize:
+		default:
+			continue
+		}
+		result.Removed = append(result.Removed, e)
+		result.Freed += e.Size
+		total -= e.Size
+	}
+
+	// Report entries in the order they are listed
+	slices.SortFunc(result.Removed, func(a, b Entry) int { return cmp.Compare(a.Path, b.Path) })
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	for _, blob := range result.Garbage {
+		if err := os.Remove(blob); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("removing unreferenced blob: %w", err)
+		}
+	}
+	// Nested layouts are removed before the layouts containing them
+	for _, e := range slices.Backward(result.Removed) {
+		remove := os.RemoveAll
+		if e.Area == AreaOCI {
+			remove = removeLayout
+		}
+		if err := remove(e.Path); err != nil {
+			return nil, fmt.Errorf("pruning cache: %w", err)
+		}
+	}
+
+	if len(result.Removed) > 0 {
+		if err := c.removeBrokenLinks(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// removeBrokenLinks removes the NAME--VERSION symlinks to pruned downloads.
+func (c *Cache) removeBrokenLinks() error {
+	files, err := os.ReadDir(c.Downloads)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("listing cache: %w", err)
+	}
+
+	for _, file := range files {
+		if file.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		link := filepath.Join(c.Downloads, file.Name())
+		if _, err := os.Stat(link); errors.Is(err, fs.ErrNotExist) {
+			if err := os.Remove(link); err != nil {
+				return fmt.Errorf("removing cache symlink: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// writeDownload writes a downloaded bottle last used the given number of days ago.
+func writeDownload(t *testing.T, c *Cache, name string, size, days int) string {
+	t.Helper()
+	file := filepath.Join(c.DownloadsDir(), "0123--"+name+"--1.0.x86_64_linux.bottle.tar.gz")
+	if err := os.MkdirAll(c.DownloadsDir(), 0o775); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	used := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	if err := os.Chtimes(file, used, used); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(file, filepath.Join(c.Downloads, name+"--1.0")); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// pushBlob pushes a blob to an OCI layout.
+func pushBlob(t *testing.T, store *oci.Store, mediaType string, data []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := content.NewDescriptorFromBytes(mediaType, data)
+	if err := store.Push(context.Background(), desc, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+func TestCache_Prune(t *testing.T) {
+	ctx := context.Background()
+	c := &Cache{Dir: t.TempDir(), Downloads: t.TempDir()}
+
+	oldest := writeDownload(t, c, "old", 10000, 200)
+	lru := writeDownload(t, c, "lru", 10000, 10)
+	recent := writeDownload(t, c, "recent", 10000, 1)
+
+	// An OCI layout with a bottle referenced by a manifest and an unreferenced blob
+	store, err := oci.NewWithContext(ctx, filepath.Join(c.OCIDir(), "cowsay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := pushBlob(t, store, "application/vnd.oci.image.layer.v1.tar+gzip", []byte("bottle"))
+	config := pushBlob(t, store, ocispec.MediaTypeEmptyJSON, ocispec.DescriptorEmptyJSON.Data)
+	manifest, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushBlob(t, store, ocispec.MediaTypeImageManifest, manifest)
+	garbage := pushBlob(t, store, "application/octet-stream", []byte("garbage"))
+
+	// The old download exceeds the maximum age, and the least recently
+	// used download does not fit in the size budget
+	result, err := c.Prune(ctx, &PruneOptions{
+		MaxAge:  100 * 24 * time.Hour,
+		MaxSize: 15000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed := []string{}
+	for _, e := range result.Removed {
+		removed = append(removed, e.Path)
+	}
+	if want := []string{lru, oldest}; !slices.Equal(removed, want) {
+		t.Errorf("Cache.Prune() removed %q, want %q", removed, want)
+	}
+	for _, file := range []string{oldest, lru, filepath.Join(c.Downloads, "old--1.0")} {
+		if _, err := os.Lstat(file); !os.IsNotExist(err) {
+			t.Errorf("Cache.Prune() kept %s", file)
+		}
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Cache.Prune() removed %s: %v", recent, err)
+	}
+
+	// Only the unreferenced blob is collected
+	if len(result.Garbage) != 1 || filepath.Base(result.Garbage[0]) != garbage.Digest.Encoded() {
+		t.Errorf("Cache.Prune() collected %q, want blob %s", result.Garbage, garbage.Digest)
+	}
+	exists, err := store.Exists(ctx, layer)
+	if err != nil || !exists {
+		t.Errorf("Cache.Prune() removed the referenced bottle blob")
+	}
+	if want := int64(20000 + len("garbage")); result.Freed != want {
+		t.Errorf("Cache.Prune() freed %d bytes, want %d", result.Freed, want)
+	}
+}
@@ -108,12 +108,14 @@ func cleanupCmd(hops *actions.Hops) *cobra.Command {
 			Remove stale lock files and outdated downloads for all formulae and casks, and
 			remove old versions of installed formulae. If arguments are specified, only do
 			this for the given formulae and casks. Removes all downloads more than 120 days
-			old. This can be adjusted with HOMEBREW_CLEANUP_MAX_AGE_DAYS.`),
-		// 		--prune                      Remove all cache files older than specified
-		// 											  days. If you want to remove everything, use
-		// 											  --prune=all.
-		//   -n, --dry-run                    Show what would be removed, but do not
-		// 											  actually remove anything.
+			old. This can be adjusted with HOMEBREW_CLEANUP_MAX_AGE_DAYS.
+
+			The least recently used downloads are also removed until the cache is no
+			larger than the cleanup.maxSize setting, if it is set.
+
+			Old kegs are removed unless they are linked, opt-linked, or pinned. The newest
+			keg of each formula is always kept. Opt, linked, and pinned records of kegs
+			that are gone and empty racks are also removed.`),
 		//   -s                               Scrub the cache, including downloads for even
 		// 											  the latest versions. Note that downloads for
 		// 											  any installed formulae or casks will still
@@ -126,10 +128,126 @@ func cleanupCmd(hops *actions.Hops) *cobra.Command {
 		//   -v, --verbose                    Make some output more verbose.
 		//   -h, --help                       Show this message.
 		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&action.DryRun, "dry-run", "n", false, "Show what would be removed, but do not actually remove anything")
+	cmd.Flags().StringVar(&action.Prune, "prune", "", "Remove all cache files older than specified days. If you want to remove everything, use --prune=all")
+
+	return cmd
+}
+
+// cacheCmd creates the command.
+func cacheCmd(hops *actions.Hops) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Show or manage the cache",
+		Long: heredoc.Doc(`
+			Show the cache directory, or manage the cached downloads and OCI layouts of
+			bottles with a subcommand.`),
+		Args: cobra.NoArgs,
+		Run:  func(cmd *cobra.Command, _ []string) { cmd.Println(hops.Config().Cache) },
+	}
+
+	cmd.AddCommand(
+		cacheInfoCmd(hops),
+		cachePruneCmd(hops),
+	)
+
+	return cmd
+}
+
+// cacheInfoCmd creates the command.
+func cacheInfoCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.CacheInfo{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show the size of the cache",
+		Long: heredoc.Doc(`
+			Show the size of each area of the cache, and the size of the cached bottles
+			of each formula, largest first.`),
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			return action.Run(cmd.Context())
 		},
 	}
+
+	cmd.Flags().BoolVar(&action.JSON, "json", false, "Print output in JSON format")
+
+	return cmd
+}
+
+// cachePruneCmd creates the command.
+func cachePruneCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.CachePrune{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove cached bottles",
+		Long: heredoc.Doc(`
+			Remove cached bottles that have not been used in --max-age-days days, then
+			remove the least recently used bottles until the cache is no larger than
+			--max-size. Unreferenced blobs are also removed from the OCI layouts that
+			remain. The Homebrew API index is never removed.
+
+			The thresholds default to the cleanup.maxAgeDays and cleanup.maxSize
+			settings, which are also used by hops cleanup.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().IntVar(&action.MaxAgeDays, "max-age-days", -1, "Remove bottles unused for more than this many days, or 0 to keep bottles regardless of age")
+	cmd.Flags().Lookup("max-age-days").DefValue = "cleanup.maxAgeDays"
+	cmd.Flags().StringVar(&action.MaxSize, "max-size", "", "Remove the least recently used bottles until the cache is no larger than this size, such as 10GB")
+	cmd.Flags().Lookup("max-size").DefValue = "cleanup.maxSize"
+	cmd.Flags().BoolVar(&action.All, "all", false, "Remove all cached bottles")
+	cmd.Flags().BoolVarP(&action.DryRun, "dry-run", "n", false, "Show what would be removed, but do not actually remove anything")
+	cmd.MarkFlagsMutuallyExclusive("all", "max-age-days")
+	cmd.MarkFlagsMutuallyExclusive("all", "max-size")
+
+	return cmd
+}
+
+// doctorCmd creates the command.
+func doctorCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Doctor{Hops: hops}
+
+	var listChecks bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor [check]...",
+		Short: "Check your system for potential problems",
+		Long: heredoc.Doc(`
+			Check your system for potential problems. Each problem is reported with its
+			severity and a suggested fix. If checks are named, only those checks are run.
+			Exits with a non-zero status if any problems are found or any check could not run.`),
+		Args: cobra.ArbitraryArgs,
+		ValidArgsFunction: func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+			names := []string{}
+			for _, check := range action.Checks() {
+				names = append(names, check.Name()+"\t"+check.Description())
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listChecks {
+				action.ListChecks()
+				return nil
+			}
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+	cmd.Flags().BoolVar(&listChecks, "list-checks", false, "List all checks")
+	cmd.Flags().BoolVar(&action.JSON, "json", false, "Print output in JSON format")
+	cmd.MarkFlagsMutuallyExclusive("list-checks", "json")
+
+	withRegistryConfig(cmd, action.Hops)
+
 	return cmd
 }
 
@@ -45,6 +45,8 @@ func copyCmd(hops *actions.Hops) *cobra.Command {
 	// Formula flags
 	cmd.Flags().StringSliceVar(&action.Brewfile, "brewfile", nil, "Copy formulae listed in a Brewfile")
 	logutil.FlagErr("brewfile", cmd.MarkFlagFilename("brewfile"))
+	cmd.Flags().StringVar(&action.Lock, "lock", "", "Copy exactly the bottles recorded in a Brewfile lock file, failing if the source no longer matches")
+	logutil.FlagErr("lock", cmd.MarkFlagFilename("lock", "json"))
 
 	// Dependency resolution flags
 	withDependencyFlags(cmd, &action.DependencyOptions)
@@ -72,6 +74,8 @@ func imagesCmd(hops *actions.Hops) *cobra.Command {
 
 	cmd.Flags().StringVar(&action.File, "file", "", "Find images for the formulae listed in a Brewfile")
 	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+	cmd.Flags().StringVar(&action.Lock, "lock", "", "List exactly the bottles recorded in a Brewfile lock file, failing if the registry no longer matches")
+	logutil.FlagErr("lock", cmd.MarkFlagFilename("lock", "json"))
 
 	cmd.Flags().BoolVar(&action.NoResolve, "no-resolve", false, "Do not resolve image tags")
 	cmd.Flags().BoolVar(&action.NoVerify, "no-verify", false, "Do not verify tag existence (implies --no-resolve)")
@@ -82,3 +86,41 @@ func imagesCmd(hops *actions.Hops) *cobra.Command {
 
 	return cmd
 }
+
+// lockCmd creates the command.
+func lockCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Lock{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "lock [--file Brewfile]",
+		Short: "Lock the bottles of a Brewfile",
+		Long: heredoc.Doc(`
+			Generate or refresh the lock file for a Brewfile, named Brewfile.lock.json.
+
+			The lock file records every formula in the Brewfile's dependency closure with
+			its version, bottle tag, bottle index digest, and the manifest and bottle
+			digests for each platform, as resolved in the configured registry. Without a
+			registry, the lock file records the version and the bottle sha256 for each
+			platform from the Homebrew API.
+
+			Formulae already in the lock file keep their locked versions unless --update
+			is set. Use the lock file with the --lock flag of install, copy, and images to
+			resolve exactly the locked bottles.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	// Enable registry override flags
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().StringVar(&action.File, "file", "Brewfile", "Lock the formulae listed in a Brewfile")
+	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+	cmd.Flags().BoolVar(&action.Update, "update", false, "Resolve the newest bottles instead of keeping locked versions")
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
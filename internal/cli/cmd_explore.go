@@ -92,6 +92,59 @@ func depsCmd(hops *actions.Hops) *cobra.Command {
 	return cmd
 }
 
+// usesCmd creates the command.
+func usesCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Uses{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "uses [formula]...",
+		Short: "Show formulae that specify formula as a dependency",
+		Long: heredoc.Doc(`
+			Show formulae that specify formula as a dependency; that is, show dependents of formula.
+			When given multiple formula arguments, show the intersection of formulae that use formula.
+			By default, uses shows all formulae that specify formula as a required or recommended dependency.`),
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: formulaNames(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	cmd.Flags().BoolVar(&action.Recursive, "recursive", false, "Resolve more than one level of dependencies")
+	cmd.Flags().BoolVar(&action.Installed, "installed", false, "Only list formulae that are currently installed")
+
+	withRegistryConfig(cmd, action.Hops)
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
+// missingCmd creates the command.
+func missingCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Missing{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "missing [formula]...",
+		Short: "Check the given formula kegs for missing dependencies",
+		Long: heredoc.Doc(`
+			Check the given formula kegs for missing dependencies. If no formula is provided,
+			check all kegs. Exits with a non-zero status if any dependencies are missing.`),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
 // leavesCmd creates the command.
 func leavesCmd(hops *actions.Hops) *cobra.Command {
 	action := &actions.Leaves{Hops: hops}
@@ -112,6 +165,36 @@ func leavesCmd(hops *actions.Hops) *cobra.Command {
 	return cmd
 }
 
+// outdatedCmd creates the command.
+func outdatedCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Outdated{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "outdated [installed_formula]...",
+		Short: "List installed formulae that have an updated version available",
+		Long: heredoc.Doc(`
+			List installed formulae that have an updated version available, with their
+			installed and available versions.
+
+			STANDALONE MODE:
+
+			The updated version of a formula is the version tagged "latest" in the registry.`),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVarP(&action.Quiet, "quiet", "q", false, "List only the names of outdated kegs")
+	cmd.Flags().StringVar(&action.JSON, "json", "", "Print output in JSON format. There are two versions: v1 and v2. v2 is the default if no version is specified")
+	cmd.Flags().Lookup("json").NoOptDefVal = "v2"
+	cmd.MarkFlagsMutuallyExclusive("quiet", "json")
+
+	return cmd
+}
+
 // listCmd creates the command.
 func listCmd(hops *actions.Hops) *cobra.Command {
 	action := &actions.List{Hops: hops}
@@ -130,7 +213,7 @@ func listCmd(hops *actions.Hops) *cobra.Command {
 	// cmd.Flags().BoolVar(&action.FullName, "full-name", false, "Print formulae with fully-qualified names. Unless --full-name, --versions or --pinned are passed, other options (i.e. -1, -l, -r and -t) are passed to ls(1) which produces the actual output")
 	cmd.Flags().BoolVar(&action.Versions, "versions", false, "Show the version number for installed formulae, or only the specified formulae if formula are provided")
 	cmd.Flags().BoolVar(&action.Multiple, "multiple", false, "Only show formulae with multiple versions installed")
-	// cmd.Flags().BoolVar(&action.Pinned, "pinned", false, "List only pinned formulae, or only the specified (pinned) formulae if formula are provided. See also pin, unpin")
+	cmd.Flags().BoolVar(&action.Pinned, "pinned", false, "List only pinned formulae, or only the specified (pinned) formulae if formula are provided. See also pin, unpin")
 	// cmd.Flags().BoolVar(&action.OnePerLine, "1", false, "Force output to be one entry per line. This is the default when output is not to a terminal")
 	// cmd.MarkFlagsMutuallyExclusive("versions", "1")
 	// cmd.Flags().BoolVarP(&action.Long, "long", "l", false, "List formulae and/or casks in long format. Has no effect when a formula or cask name is passed as an argument")
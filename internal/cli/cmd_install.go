@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/MakeNowJust/heredoc/v2"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/act3-ai/hops/internal/actions"
 	"github.com/act3-ai/hops/internal/o"
+	"github.com/act3-ai/hops/internal/utils/logutil"
 )
 
 // installCmd creates the command.
@@ -15,7 +17,7 @@ func installCmd(hops *actions.Hops) *cobra.Command {
 	action := &actions.Install{Hops: hops}
 
 	cmd := &cobra.Command{
-		Use:   "install formula [...]",
+		Use:   "install (formula [...] | --lock file)",
 		Short: "Install a formula",
 		Long: heredoc.Doc(`
 			Install a formula. Additional options specific to a formula may be appended to the command.
@@ -29,16 +31,24 @@ func installCmd(hops *actions.Hops) *cobra.Command {
 
 			Unless HOMEBREW_NO_INSTALL_UPGRADE is set, brew install formula will
 			upgrade formula if it is already installed but outdated.
-			
+
+			Formulae that conflict with linked formulae are not installed unless
+			--unlinked is set, in which case they are installed without being linked.
+
+			If --lock is set without any formulae, every formula in the lock file is
+			installed.
+
 			STANDALONE MODE:
 
 			Hops has an alternate mode to fetch all packages and metadata from a single OCI registry.
 			The default behavior for standalone mode is to install the version tagged "latest".
 			The tag for a formula can be set by using the argument format "<formula>:<tag>".
 			`),
-		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: formulaNames(hops),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && action.Lock == "" {
+				return errors.New("requires at least 1 formula or --lock")
+			}
 			return action.Run(cmd.Context(), args...)
 		},
 	}
@@ -48,6 +58,9 @@ func installCmd(hops *actions.Hops) *cobra.Command {
 	cmd.Flags().BoolVar(&action.Force, "force", false, "Install formulae without checking for previously installed keg-only or non-migrated versions. When installing casks, overwrite existing files (binaries and symlinks are excluded, unless originally from the same cask)")
 	cmd.Flags().BoolVar(&action.DryRun, "dry-run", false, "Show what would be installed, but do not actually install anything")
 	cmd.Flags().BoolVar(&action.Overwrite, "overwrite", false, "Delete files that already exist in the prefix while linking")
+	cmd.Flags().BoolVar(&action.Unlinked, "unlinked", false, "Install formulae that conflict with linked formulae without linking them")
+	cmd.Flags().StringVar(&action.Lock, "lock", "", "Install exactly the bottles recorded in a Brewfile lock file, failing if they no longer match")
+	logutil.FlagErr("lock", cmd.MarkFlagFilename("lock", "json"))
 
 	// Dependency resolution flags
 	withDependencyFlags(cmd, &action.DependencyOptions)
@@ -58,6 +71,50 @@ func installCmd(hops *actions.Hops) *cobra.Command {
 	return cmd
 }
 
+// fetchCmd creates the command.
+func fetchCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Fetch{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "fetch (formula [...] | --brewfile [Brewfile])",
+		Short: "Download bottles into the cache",
+		Long: heredoc.Doc(`
+			Download the bottles for the given formulae into the cache, so they can be
+			installed later without network access. Bottles that are already cached are
+			verified against their digests and downloaded again if they are corrupt.
+
+			Bottles for other platforms can be downloaded with --platform, and bottles for
+			every supported platform with --platform all.
+
+			If --brewfile is set, the formulae listed in the Brewfile are fetched. If the
+			Brewfile has been locked with hops lock, the locked bottles are fetched.
+			`),
+		ValidArgsFunction: formulaNames(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && action.Brewfile == "" {
+				return errors.New("requires at least 1 formula or --brewfile")
+			}
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.Deps, "deps", false, "Also download bottles for dependencies")
+	cmd.Flags().StringVar(&action.Brewfile, "brewfile", "", "Download bottles for the formulae listed in a Brewfile")
+	cmd.Flags().Lookup("brewfile").NoOptDefVal = "Brewfile"
+	logutil.FlagErr("brewfile", cmd.MarkFlagFilename("brewfile"))
+
+	// Platform selector (not reflected in Homebrew)
+	platflag := cmd.Flags().VarPF(&action.Platform, "platform", "p", "Download bottles for platform, or \"all\" for every supported platform")
+	platflag.DefValue = "system"
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
 // uninstallCmd creates the command.
 func uninstallCmd(hops *actions.Hops) *cobra.Command {
 	action := &actions.Uninstall{Hops: hops}
@@ -98,10 +155,21 @@ func updateCmd(hops *actions.Hops) *cobra.Command {
 
 // upgradeCmd creates the command.
 func upgradeCmd(hops *actions.Hops) *cobra.Command {
-	action := &actions.Upgrade{Hops: hops}
+	action := &actions.Upgrade{Install: actions.Install{Hops: hops}}
 	cmd := &cobra.Command{
-		Use:               "upgrade [formula]...",
-		Short:             "Upgrade installed formulae",
+		Use:   "upgrade [formula]...",
+		Short: "Upgrade installed formulae",
+		Long: heredoc.Doc(`
+			Upgrade outdated formulae. If formulae are specified, upgrade only the given
+			formula kegs. Outdated dependencies of the upgraded formulae are upgraded and
+			missing dependencies are installed.
+
+			The new keg is linked in place of the outdated keg. Formulae that are not
+			linked remain unlinked, and formulae installed on request remain installed
+			on request.
+
+			Unless HOMEBREW_NO_INSTALL_CLEANUP is set, the outdated kegs will then be
+			removed.`),
 		ValidArgsFunction: installedFormulae(hops),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return action.Run(cmd.Context(), args...)
@@ -110,6 +178,14 @@ func upgradeCmd(hops *actions.Hops) *cobra.Command {
 
 	withRegistryConfig(cmd, action.Hops)
 
+	cmd.Flags().BoolVarP(&action.Force, "force", "f", false, "Install formulae without checking for previously installed keg-only or non-migrated versions")
+	cmd.Flags().BoolVarP(&action.DryRun, "dry-run", "n", false, "Show what would be upgraded, but do not actually upgrade anything")
+	cmd.Flags().BoolVar(&action.Overwrite, "overwrite", false, "Delete files that already exist in the prefix while linking")
+	cmd.Flags().BoolVar(&action.Unlinked, "unlinked", false, "Install formulae that conflict with linked formulae without linking them")
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
 	return cmd
 }
 
@@ -164,3 +240,401 @@ func unlinkCmd(hops *actions.Hops) *cobra.Command {
 
 	return cmd
 }
+
+// switchCmd creates the command.
+func switchCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Switch{Hops: hops}
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("switch %s %s", o.StyleUnderline("installed_formula"), o.StyleUnderline("version")),
+		Short: "Switch the linked version of a formula",
+		Long: heredoc.Doc(`
+			Switch which installed version of formula is linked into Homebrew's prefix and
+			used by its dependents through opt. Kegs of other versions are unlinked. If the
+			formula is not linked, such as a keg-only formula, only opt is switched.
+
+			The version may omit the revision to switch to the newest installed revision of
+			that version.`),
+		Args: cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			switch len(args) {
+			case 0:
+				return installedFormulae(hops)(cmd, args, toComplete)
+			case 1:
+				return installedVersions(hops, args[0]), cobra.ShellCompDirectiveNoFileComp
+			default:
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args[0], args[1])
+		},
+	}
+
+	cmd.Flags().BoolVar(&action.Overwrite, "overwrite", false, "Delete files that already exist in the prefix while linking")
+
+	return cmd
+}
+
+// migrateCmd creates the command.
+func migrateCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Migrate{Hops: hops}
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("migrate %s...", o.StyleUnderline("installed_formula")),
+		Short: "Migrate renamed formulae to their new names",
+		Long: heredoc.Doc(`
+			Migrate renamed packages to new names, where formula are old names of packages
+			or their new names. The kegs installed under an old name are moved to the rack
+			of the new name and relinked, and their install receipts are rewritten.
+
+			The old name keeps working: the old rack links to the new rack and opt keeps a
+			record for the old name, so dependents built against the old name still find
+			the formula.`),
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&action.DryRun, "dry-run", "n", false, "Show what would be migrated, but do not actually migrate anything")
+	withRegistryConfig(cmd, action.Hops)
+
+	return cmd
+}
+
+// pinCmd creates the command.
+func pinCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Pin{Hops: hops}
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("pin %s...", o.StyleUnderline("installed_formula")),
+		Short: "Pin an installed formula",
+		Long: heredoc.Doc(`
+			Pin the specified formula, preventing them from being upgraded when issuing
+			the hops upgrade command, or when they are outdated dependencies of an
+			upgraded formula. See also unpin.
+
+			Note: Other packages which depend on newer versions of a pinned formula might
+			not install or run correctly.`),
+		Args:              cobra.MatchAll(cobra.MinimumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	return cmd
+}
+
+// unpinCmd creates the command.
+func unpinCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.Unpin{Hops: hops}
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("unpin %s...", o.StyleUnderline("installed_formula")),
+		Short: "Unpin an installed formula",
+		Long: heredoc.Doc(`
+			Unpin formula, allowing them to be upgraded by hops upgrade. See also pin.`),
+		Args:              cobra.MatchAll(cobra.MinimumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	return cmd
+}
+
+// bundleCmd creates the command.
+func bundleCmd(hops *actions.Hops) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Install formulae from a Brewfile",
+		Long: heredoc.Doc(`
+			Bundler for non-Ruby dependencies from Homebrew. Installs, checks, dumps, and
+			cleans up the formulae listed in a Brewfile.`),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		bundleInstallCmd(hops),
+		bundleCheckCmd(hops),
+		bundleDumpCmd(hops),
+		bundleCleanupCmd(hops),
+	)
+
+	return cmd
+}
+
+// bundleInstallCmd creates the command.
+func bundleInstallCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.BundleInstall{Install: actions.Install{Hops: hops}}
+
+	cmd := &cobra.Command{
+		Use:   "install [--file Brewfile]",
+		Short: "Install all formulae in a Brewfile",
+		Long: heredoc.Doc(`
+			Install the formulae listed in a Brewfile that are not already installed.
+
+			Entry options are honored: link: false installs a formula without linking it,
+			link: true links it even if it is keg-only, link: :overwrite also deletes
+			files in the way, and conflicts_with unlinks the listed formulae first.
+			Installed formulae are linked or unlinked to match their link option.
+			start_service and restart_service start or restart the formula's service.
+
+			If the Brewfile has been locked with hops lock, the locked bottles are
+			installed unless another lock file is given with --lock.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().StringVar(&action.File, "file", "Brewfile", "Install the formulae listed in a Brewfile")
+	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+	cmd.Flags().BoolVar(&action.DryRun, "dry-run", false, "Show what would be installed, but do not actually install anything")
+	cmd.Flags().BoolVar(&action.Overwrite, "overwrite", false, "Delete files that already exist in the prefix while linking")
+	cmd.Flags().BoolVar(&action.Unlinked, "unlinked", false, "Install formulae that conflict with linked formulae without linking them")
+	cmd.Flags().StringVar(&action.Lock, "lock", "", "Install exactly the bottles recorded in a Brewfile lock file, failing if they no longer match")
+	logutil.FlagErr("lock", cmd.MarkFlagFilename("lock", "json"))
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
+// bundleCheckCmd creates the command.
+func bundleCheckCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.BundleCheck{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "check [--file Brewfile]",
+		Short: "Check that all formulae in a Brewfile are installed",
+		Long: heredoc.Doc(`
+			Check if all formulae listed in a Brewfile and their dependencies are
+			installed. Exits with a non-zero status if anything is missing.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().StringVar(&action.File, "file", "Brewfile", "Check the formulae listed in a Brewfile")
+	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
+// bundleDumpCmd creates the command.
+func bundleDumpCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.BundleDump{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "dump [--file Brewfile]",
+		Short: "Write installed formulae to a Brewfile",
+		Long: heredoc.Doc(`
+			Write all installed leaves that were installed on request to a Brewfile.
+			Formulae from taps other than homebrew/core are written with their tap.
+
+			An existing Brewfile is only overwritten if --force is set. Use --file=-
+			to write the Brewfile to standard output.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().StringVar(&action.File, "file", "Brewfile", "Write the formulae to a Brewfile")
+	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+	cmd.Flags().BoolVarP(&action.Force, "force", "f", false, "Overwrite an existing Brewfile")
+
+	return cmd
+}
+
+// bundleCleanupCmd creates the command.
+func bundleCleanupCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.BundleCleanup{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "cleanup [--file Brewfile]",
+		Short: "Uninstall formulae not in a Brewfile",
+		Long: heredoc.Doc(`
+			List installed formulae that are not listed in a Brewfile or required by a
+			formula listed in it. The formulae are only uninstalled if --force is set.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().StringVar(&action.File, "file", "Brewfile", "Keep the formulae listed in a Brewfile")
+	logutil.FlagErr("file", cmd.MarkFlagFilename("file"))
+	cmd.Flags().BoolVarP(&action.Force, "force", "f", false, "Uninstall the formulae instead of listing them")
+
+	// Dependency resolution flags
+	withDependencyFlags(cmd, &action.DependencyOptions)
+
+	return cmd
+}
+
+// servicesCmd creates the command.
+func servicesCmd(hops *actions.Hops) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "services",
+		Short: "Manage background services",
+		Long: heredoc.Doc(`
+			Manage background services of formulae with systemd user units on Linux or
+			launchd agents on macOS.
+
+			Services are defined by the service block of a formula. Paths in the service
+			block are resolved against the prefix. Scheduled services run with a systemd
+			timer on Linux.`),
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		servicesListCmd(hops),
+		servicesInfoCmd(hops),
+		servicesStartCmd(hops),
+		servicesStopCmd(hops),
+		servicesRestartCmd(hops),
+	)
+
+	return cmd
+}
+
+// servicesListCmd creates the command.
+func servicesListCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.ServicesList{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the services of installed formulae",
+		Long: heredoc.Doc(`
+			List the services of all installed formulae with their status, user, and
+			service file.`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return action.Run(cmd.Context())
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.JSON, "json", false, "Print output in JSON format")
+
+	return cmd
+}
+
+// servicesInfoCmd creates the command.
+func servicesInfoCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.ServicesInfo{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("info %s...", o.StyleUnderline("installed_formula")),
+		Short: "Show the service of a formula",
+		Long: heredoc.Doc(`
+			Show the status, command, and log paths of a formula's service.
+
+			With --format, print the systemd units or launchd property list that define
+			the service instead. Definitions can be printed for either service manager
+			on any system.`),
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.JSON, "json", false, "Print output in JSON format")
+	cmd.Flags().StringVar(&action.Format, "format", "", "Print the service definition for a service manager, one of systemd, launchd")
+	logutil.FlagErr("format", cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"systemd", "launchd"}, cobra.ShellCompDirectiveNoFileComp)))
+	cmd.MarkFlagsMutuallyExclusive("json", "format")
+
+	return cmd
+}
+
+// servicesStartCmd creates the command.
+func servicesStartCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.ServicesStart{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("start (%s... | --all)", o.StyleUnderline("installed_formula")),
+		Short: "Start a service and register it to start at login",
+		Long: heredoc.Doc(`
+			Start the service of a formula immediately and register it to start at login.
+			Scheduled services are started by their schedule.`),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.All, "all", false, "Start the services of all installed formulae")
+
+	return cmd
+}
+
+// servicesStopCmd creates the command.
+func servicesStopCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.ServicesStop{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("stop (%s... | --all)", o.StyleUnderline("installed_formula")),
+		Short: "Stop a service and unregister it",
+		Long: heredoc.Doc(`
+			Stop the service of a formula immediately and unregister it from starting at
+			login.`),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.All, "all", false, "Stop the services of all installed formulae")
+
+	return cmd
+}
+
+// servicesRestartCmd creates the command.
+func servicesRestartCmd(hops *actions.Hops) *cobra.Command {
+	action := &actions.ServicesRestart{Hops: hops}
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("restart (%s... | --all)", o.StyleUnderline("installed_formula")),
+		Short: "Restart a service",
+		Long: heredoc.Doc(`
+			Stop, if necessary, and start the service of a formula immediately and
+			register it to start at login.`),
+		ValidArgsFunction: installedFormulae(hops),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return action.Run(cmd.Context(), args...)
+		},
+	}
+
+	withRegistryConfig(cmd, action.Hops)
+
+	cmd.Flags().BoolVar(&action.All, "all", false, "Restart the services of all installed formulae")
+
+	return cmd
+}
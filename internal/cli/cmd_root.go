@@ -40,6 +40,10 @@ func NewCLI(version string) *cobra.Command {
 	// Concurrency flag
 	cmd.PersistentFlags().IntVar(&hops.Concurrency, "concurrency", GOMAXPROCS, "Concurrency level")
 
+	// Lock flags
+	cmd.PersistentFlags().BoolVar(&hops.Wait, "wait", false, "Wait for formula locks held by other hops or brew processes instead of failing (the prefix link lock only coordinates hops processes and is always waited for)")
+	cmd.PersistentFlags().DurationVar(&hops.WaitTimeout, "wait-timeout", 0, "Stop waiting for locks after this long, or never if 0")
+
 	// Style the error prefix red
 	cmd.SetErrPrefix(o.StyleRed(cmd.ErrPrefix()))
 
@@ -52,9 +56,11 @@ func NewCLI(version string) *cobra.Command {
 			Title: "Install and update formulae",
 		},
 		installCmd(hops),
+		fetchCmd(hops),
 		uninstallCmd(hops),
 		updateCmd(hops),
 		upgradeCmd(hops),
+		bundleCmd(hops),
 	)
 
 	commands.AddGroupedCommands(cmd,
@@ -64,8 +70,15 @@ func NewCLI(version string) *cobra.Command {
 		},
 		linkCmd(hops),
 		unlinkCmd(hops),
+		switchCmd(hops),
+		migrateCmd(hops),
+		pinCmd(hops),
+		unpinCmd(hops),
 		listCmd(hops),
 		leavesCmd(hops),
+		missingCmd(hops),
+		outdatedCmd(hops),
+		servicesCmd(hops),
 	)
 
 	commands.AddGroupedCommands(cmd,
@@ -75,6 +88,7 @@ func NewCLI(version string) *cobra.Command {
 		},
 		infoCmd(hops),
 		depsCmd(hops),
+		usesCmd(hops),
 		searchCmd(hops),
 	)
 
@@ -86,6 +100,8 @@ func NewCLI(version string) *cobra.Command {
 		shellenvCmd(hops),
 		envCmd(hops),
 		cleanupCmd(hops),
+		cacheCmd(hops),
+		doctorCmd(hops),
 		prefixCmd(hops),
 		cellarCmd(hops),
 		configCmd(hops),
@@ -98,6 +114,7 @@ func NewCLI(version string) *cobra.Command {
 		},
 		imagesCmd(hops),
 		copyCmd(hops),
+		lockCmd(hops),
 	)
 
 	return cmd
@@ -72,6 +72,21 @@ func installedFormulae(hops *actions.Hops) func(cmd *cobra.Command, _ []string,
 	}
 }
 
+// installedVersions lists the installed versions of the named formula for autocompletion.
+func installedVersions(hops *actions.Hops, name string) []string {
+	kegs, err := hops.Prefix().InstalledKegsByName(name)
+	if err != nil {
+		cobra.CompErrorln("loading completions: checking cellar: " + err.Error())
+		return []string{}
+	}
+
+	versions := make([]string, 0, len(kegs))
+	for _, k := range kegs {
+		versions = append(versions, k.Version())
+	}
+	return versions
+}
+
 // withDependencyFlags adds flags for dependency resolution.
 func withDependencyFlags(cmd *cobra.Command, opts *formula.DependencyTags) {
 	cmd.Flags().BoolVar(&opts.IncludeBuild, "include-build", false, "Include :build dependencies for formula")
@@ -0,0 +1,106 @@
+package dependencies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/formula"
+)
+
+// Schedule calls fn for each formula in the graph as soon as fn has returned
+// for all of the formula's dependencies, with up to maxGoroutines calls at once.
+//
+// When fn fails for a formula, the formulae that depend on it are skipped while
+// the others continue. The failures are returned once every call has returned.
+// Cancelling the context stops new calls from being made.
+func (deps *DependencyGraph) Schedule(ctx context.Context, maxGoroutines int, fn func(context.Context, formula.PlatformFormula) error) error {
+	keys := deps.keys()
+
+	// Number of unfinished dependencies of each formula
+	waiting := make(map[string]int, len(keys))
+	dependents := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		waiting[key] = len(deps.edges[key])
+		for _, dep := range deps.edges[key] {
+			dependents[dep] = append(dependents[dep], key)
+		}
+	}
+
+	ready := slices.DeleteFunc(slices.Clone(keys), func(key string) bool { return waiting[key] > 0 })
+
+	type result struct {
+		key string
+		err error
+	}
+	results := make(chan result)
+	running, done := 0, 0
+	failed := []result{}
+	for len(ready) > 0 || running > 0 {
+		// Start the ready formulae while there are free routines
+		for len(ready) > 0 && running < max(maxGoroutines, 1) && ctx.Err() == nil {
+			key := ready[0]
+			ready = ready[1:]
+			running++
+			go func() {
+				results <- result{key: key, err: fn(ctx, deps.formulae[key])}
+			}()
+		}
+		if running == 0 {
+			break // cancelled
+		}
+
+		r := <-results
+		running--
+		if r.err != nil {
+			failed = append(failed, r) // dependents are never ready, so they are skipped
+			continue
+		}
+		done++
+		for _, dependent := range dependents[r.key] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	// Report the failures instead of the cancellations they caused
+	errs := []error{}
+	for _, r := range failed {
+		if ctx.Err() != nil && errors.Is(r.err, context.Canceled) {
+			continue
+		}
+		errs = append(errs, r.err)
+		if skipped := dependentsOf(r.key, dependents); len(skipped) > 0 {
+			errs = append(errs, fmt.Errorf("skipped %s because %s failed", strings.Join(skipped, ", "), r.key))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if done < len(keys) {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// dependentsOf lists the formulae that depend on the named formula, recursively.
+func dependentsOf(name string, dependents map[string][]string) []string {
+	found := []string{}
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		for _, dependent := range dependents[queue[0]] {
+			if !seen[dependent] {
+				seen[dependent] = true
+				found = append(found, dependent)
+				queue = append(queue, dependent)
+			}
+		}
+		queue = queue[1:]
+	}
+	return found
+}
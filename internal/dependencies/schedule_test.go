@@ -0,0 +1,127 @@
+package dependencies
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+func TestSchedule(t *testing.T) {
+	// Two chains sharing a base, and a formula without dependencies
+	formulae := []formula.PlatformFormula{
+		testFormula(platform.X8664Linux, "base"),
+		testFormula(platform.X8664Linux, "a1", "base"),
+		testFormula(platform.X8664Linux, "a2", "a1"),
+		testFormula(platform.X8664Linux, "b1", "base"),
+		testFormula(platform.X8664Linux, "b2", "b1", "a1"),
+		testFormula(platform.X8664Linux, "solo"),
+	}
+	graph, err := New(formulae, &formula.DependencyTags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const maxGoroutines = 2
+	mu := sync.Mutex{}
+	finished := map[string]bool{}
+	running, peak := 0, 0
+	err = graph.Schedule(context.Background(), maxGoroutines, func(_ context.Context, f formula.PlatformFormula) error {
+		mu.Lock()
+		for _, dep := range f.Dependencies().Required {
+			if !finished[dep] {
+				t.Errorf("%s started before its dependency %s finished", f.Name(), dep)
+			}
+		}
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		finished[f.Name()] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(finished) != len(formulae) {
+		t.Errorf("finished %d formulae, want %d", len(finished), len(formulae))
+	}
+	if peak > maxGoroutines {
+		t.Errorf("ran %d formulae at once, want at most %d", peak, maxGoroutines)
+	}
+}
+
+func TestScheduleFailure(t *testing.T) {
+	formulae := []formula.PlatformFormula{
+		testFormula(platform.X8664Linux, "base"),
+		testFormula(platform.X8664Linux, "lib", "base"),
+		testFormula(platform.X8664Linux, "app", "lib"),
+		testFormula(platform.X8664Linux, "other"),
+	}
+	graph, err := New(formulae, &formula.DependencyTags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errFailed := errors.New("failed")
+	mu := sync.Mutex{}
+	called := []string{}
+	err = graph.Schedule(context.Background(), 4, func(_ context.Context, f formula.PlatformFormula) error {
+		mu.Lock()
+		called = append(called, f.Name())
+		mu.Unlock()
+		if f.Name() == "lib" {
+			return errFailed
+		}
+		return nil
+	})
+
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("Schedule() error = %v, want %v", err, errFailed)
+	}
+	if !strings.Contains(err.Error(), "skipped app because lib failed") {
+		t.Errorf("Schedule() error = %q, want skipped dependents", err)
+	}
+
+	// Formulae that do not depend on the failure still run
+	slices.Sort(called)
+	if want := []string{"base", "lib", "other"}; !slices.Equal(called, want) {
+		t.Errorf("called %v, want %v", called, want)
+	}
+}
+
+func TestScheduleCancel(t *testing.T) {
+	formulae := []formula.PlatformFormula{
+		testFormula(platform.X8664Linux, "base"),
+		testFormula(platform.X8664Linux, "app", "base"),
+	}
+	graph, err := New(formulae, &formula.DependencyTags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = graph.Schedule(ctx, 1, func(ctx context.Context, f formula.PlatformFormula) error {
+		if f.Name() == "app" {
+			t.Error("app started after the context was cancelled")
+		}
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Schedule() error = %v, want %v", err, context.Canceled)
+	}
+}
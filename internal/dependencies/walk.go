@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"sync"
 
 	"github.com/xlab/treeprint"
 
@@ -17,7 +18,48 @@ type DependencyGraph struct {
 	rootKeys      []string                           // list of root formulae
 	dependentKeys []string                           // list of dependency names, ordered
 	formulae      map[string]formula.PlatformFormula // stores dependency information
-	trees         map[string]*treeprint.Node         // stores dependency trees
+	edges         map[string][]string                // names of the direct dependencies of each formula
+}
+
+// New evaluates the dependency graph between the given formulae without
+// fetching any other formulae. Dependencies that are not in the list are left out.
+//
+// Formulae that no other formula in the list depends on are the roots.
+func New(formulae []formula.PlatformFormula, tags *formula.DependencyTags) (*DependencyGraph, error) {
+	deps := &DependencyGraph{
+		rootKeys:      []string{},
+		dependentKeys: []string{},
+		formulae:      make(map[string]formula.PlatformFormula, len(formulae)),
+		edges:         make(map[string][]string, len(formulae)),
+	}
+
+	for _, f := range formulae {
+		deps.formulae[f.Name()] = f
+	}
+
+	depended := map[string]bool{}
+	for _, f := range formulae {
+		tagged := f.Dependencies()
+		if tagged == nil {
+			continue
+		}
+		for _, dep := range tagged.ForTags(tags) {
+			if _, ok := deps.formulae[dep]; ok {
+				deps.addEdge(f.Name(), dep)
+				depended[dep] = true
+			}
+		}
+	}
+
+	for _, f := range formulae {
+		if depended[f.Name()] {
+			deps.dependentKeys = append(deps.dependentKeys, f.Name())
+		} else {
+			deps.rootKeys = append(deps.rootKeys, f.Name())
+		}
+	}
+
+	return deps, deps.checkAcyclic()
 }
 
 // Dependencies returns the list of computed dependencies.
@@ -40,86 +82,281 @@ func (deps *DependencyGraph) Roots() []formula.PlatformFormula {
 
 // Tree returns a printable tree of dependencies.
 func (deps *DependencyGraph) Tree(root string) (treeprint.Tree, error) {
-	tree, ok := deps.trees[root]
-	if !ok {
+	if _, ok := deps.formulae[root]; !ok {
+		return nil, errdef.NewFormulaNotFoundError(root)
+	}
+	return deps.tree(root), nil
+}
+
+// tree builds the printable tree of the named formula and its dependencies.
+func (deps *DependencyGraph) tree(name string) *treeprint.Node {
+	node := &treeprint.Node{Value: name}
+	for _, dep := range deps.edges[name] {
+		node.Nodes = append(node.Nodes, deps.tree(dep))
+	}
+	return node
+}
+
+// DependenciesOf returns the names of the recursive dependencies of the named root.
+func (deps *DependencyGraph) DependenciesOf(root string) ([]string, error) {
+	if _, ok := deps.formulae[root]; !ok {
 		return nil, errdef.NewFormulaNotFoundError(root)
 	}
-	return tree, nil
+
+	found := []string{}
+	deps.visit(root, map[string]bool{root: true}, func(name string) {
+		found = append(found, name)
+	})
+	return found, nil
+}
+
+// Dependents returns the roots that depend on the named formula. If recursive is
+// set, roots that depend on it through other dependencies are included.
+func (deps *DependencyGraph) Dependents(name string, recursive bool) []formula.PlatformFormula {
+	dependents := []formula.PlatformFormula{}
+	for _, root := range deps.rootKeys {
+		if root == name {
+			continue
+		}
+
+		found := false
+		if recursive {
+			deps.visit(root, map[string]bool{root: true}, func(dep string) {
+				found = found || dep == name
+			})
+		} else {
+			found = slices.Contains(deps.edges[root], name)
+		}
+
+		if found {
+			dependents = append(dependents, deps.formulae[root])
+		}
+	}
+	return dependents
+}
+
+// visit calls fn once for each dependency of the named formula, depth first.
+func (deps *DependencyGraph) visit(name string, seen map[string]bool, fn func(name string)) {
+	for _, dep := range deps.edges[name] {
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+		fn(dep)
+		deps.visit(dep, seen, fn)
+	}
+}
+
+// keys lists the names of every formula in the graph once, dependencies first.
+func (deps *DependencyGraph) keys() []string {
+	keys := slices.Concat(deps.dependentKeys, deps.rootKeys)
+	seen := make(map[string]bool, len(keys))
+	return slices.DeleteFunc(keys, func(key string) bool {
+		found := seen[key]
+		seen[key] = true
+		return found
+	})
+}
+
+// addEdge records that the formula depends on the dependency.
+func (deps *DependencyGraph) addEdge(name, dep string) {
+	if !slices.Contains(deps.edges[name], dep) {
+		deps.edges[name] = append(deps.edges[name], dep)
+	}
+}
+
+// checkAcyclic verifies that no formula depends on itself, reporting the first cycle found.
+func (deps *DependencyGraph) checkAcyclic() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(deps.formulae))
+	path := []string{} // formulae being visited, each depending on the next
+
+	var check func(name string) error
+	check = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			i := slices.Index(path, name)
+			return errdef.NewDependencyCycleError(append(slices.Clone(path[i:]), name))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps.edges[name] {
+			if err := check(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	// Cycles are reported from the roots that lead to them
+	for _, name := range slices.Concat(deps.rootKeys, deps.dependentKeys) {
+		if err := check(name); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// WalkPlatform evaluates the dependency graph of all root nodes for a specific platform.
-func Walk(ctx context.Context, store formula.Formulary, roots []formula.PlatformFormula, plat platform.Platform, tags *formula.DependencyTags) (*DependencyGraph, error) {
+// Walk evaluates the dependency graph of all root nodes for a specific platform.
+//
+// Dependencies are fetched breadth first with up to maxGoroutines fetches at
+// once. Each name is fetched once, even when several formulae depend on it. The
+// graph is ordered as if it were walked depth first, regardless of the order the
+// fetches finish in. An error is returned if the dependencies form a cycle.
+func Walk(ctx context.Context, store formula.Formulary, roots []formula.PlatformFormula, plat platform.Platform, tags *formula.DependencyTags, maxGoroutines int) (*DependencyGraph, error) {
+	w := &walker{
+		store:   store,
+		plat:    plat,
+		tags:    tags,
+		roots:   make(map[string]bool, len(roots)),
+		sem:     make(chan struct{}, max(maxGoroutines, 1)),
+		fetches: make(map[string]*fetch, len(roots)),
+	}
+
+	// Roots are already fetched
+	for _, f := range roots {
+		w.roots[f.Name()] = true
+		w.fetches[f.Name()] = &fetch{f: f}
+	}
+	for _, f := range roots {
+		w.expand(ctx, f)
+	}
+	w.wg.Wait()
+
 	deps := &DependencyGraph{
 		rootKeys:      []string{},
 		dependentKeys: []string{},
 		formulae:      map[string]formula.PlatformFormula{},
-		trees:         map[string]*treeprint.Node{},
+		edges:         map[string][]string{},
 	}
 
 	for _, f := range roots {
 		deps.rootKeys = append(deps.rootKeys, f.Name())
 
-		_, err := deps.add(ctx, store, f, plat, tags)
+		err := deps.add(w, f)
 		if err != nil {
 			return deps, err
 		}
 	}
 
-	return deps, nil
+	return deps, deps.checkAcyclic()
 }
 
 // WalkAll evaluates the dependency graph of all root nodes.
 //
 // If dependencies vary by platform, all possible dependencies will be included.
-func WalkAll(ctx context.Context, store formula.Formulary, roots []formula.PlatformFormula, tags *formula.DependencyTags) (*DependencyGraph, error) {
-	return Walk(ctx, store, roots, platform.All, tags)
+func WalkAll(ctx context.Context, store formula.Formulary, roots []formula.PlatformFormula, tags *formula.DependencyTags, maxGoroutines int) (*DependencyGraph, error) {
+	return Walk(ctx, store, roots, platform.All, tags, maxGoroutines)
 }
 
-// add adds the given Formula to the found dependencies.
-func (deps *DependencyGraph) add(ctx context.Context, store formula.Formulary, f formula.PlatformFormula, plat platform.Platform, tags *formula.DependencyTags) (*treeprint.Node, error) {
+// add adds the given Formula and its fetched dependencies to the found dependencies, depth first.
+func (deps *DependencyGraph) add(w *walker, f formula.PlatformFormula) error {
 	key := f.Name()
 
-	// Use trees to check for existence because the tree is added last
-	n, ok := deps.trees[key]
-	if ok {
+	if _, ok := deps.formulae[key]; ok {
 		// Already found
-		return n, nil
+		return nil
 	}
-
-	node := &treeprint.Node{Value: key}
-
-	children := f.Dependencies().ForTags(tags)
+	deps.formulae[key] = f
 
 	if !slices.Contains(deps.rootKeys, key) {
 		deps.dependentKeys = append(deps.dependentKeys, key)
 	}
 
-	childformulae, err := formula.FetchAllPlatform(ctx, store, children, plat)
-	if err != nil {
-		return nil, err
+	for _, name := range w.children(f) {
+		child := w.fetches[name]
+		if child.err != nil {
+			return child.err
+		}
+		deps.addEdge(key, child.f.Name())
+
+		err := deps.add(w, child.f)
+		if err != nil {
+			return err
+		}
 	}
 
-	for _, d := range childformulae {
-		switch d := d.(type) {
-		case formula.PlatformFormula:
-			// Don't include indirect test dependencies
-			child, err := deps.add(ctx, store, d, plat, withoutTest(tags))
-			if err != nil {
-				return nil, err
-			}
+	return nil
+}
+
+// walker fetches the dependencies of the roots concurrently.
+type walker struct {
+	store formula.Formulary
+	plat  platform.Platform
+	tags  *formula.DependencyTags
+	roots map[string]bool // names of the root formulae
+
+	sem chan struct{} // limits the number of concurrent fetches
+	wg  sync.WaitGroup
+
+	mu      sync.Mutex
+	fetches map[string]*fetch // fetches by the requested name, including those in flight
+}
+
+// fetch is the result of fetching a formula by name.
+type fetch struct {
+	f   formula.PlatformFormula
+	err error
+}
+
+// children lists the names of the dependencies of the formula.
+func (w *walker) children(f formula.PlatformFormula) []string {
+	if w.roots[f.Name()] {
+		return f.Dependencies().ForTags(w.tags)
+	}
+	// Don't include indirect test dependencies
+	return f.Dependencies().ForTags(withoutTest(w.tags))
+}
 
-			// Append to list of child nodes
-			node.Nodes = append(node.Nodes, child)
-		default:
-			return nil, fmt.Errorf("no dependency information for formula %s", d.Name())
+// expand starts fetching the dependencies of the formula that are not fetched yet.
+func (w *walker) expand(ctx context.Context, f formula.PlatformFormula) {
+	for _, name := range w.children(f) {
+		w.mu.Lock()
+		if _, ok := w.fetches[name]; ok {
+			w.mu.Unlock()
+			continue
 		}
+		result := &fetch{}
+		w.fetches[name] = result
+		w.mu.Unlock()
+
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			result.f, result.err = w.fetch(ctx, name)
+			if result.err == nil {
+				w.expand(ctx, result.f)
+			}
+		}()
 	}
+}
 
-	deps.formulae[key] = f
-	deps.trees[key] = node
+// fetch fetches the named formula once a fetch is free.
+func (w *walker) fetch(ctx context.Context, name string) (formula.PlatformFormula, error) {
+	select {
+	case w.sem <- struct{}{}:
+		defer func() { <-w.sem }()
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
 
-	// Return my tree once all my children have been accounted for
-	return node, nil
+	fetched, err := formula.FetchAllPlatform(ctx, w.store, []string{name}, w.plat)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(fetched) == 0 || fetched[0] == nil:
+		return nil, fmt.Errorf("no dependency information for formula %s", name)
+	}
+	return fetched[0], nil
 }
 
 func withoutTest(tags *formula.DependencyTags) *formula.DependencyTags {
@@ -0,0 +1,207 @@
+package dependencies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/errdef"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// testFormulary serves formulae from a map of names to their dependencies.
+type testFormulary map[string][]string
+
+// FetchFormula implements formula.Formulary.
+func (store testFormulary) FetchFormula(_ context.Context, _ string) (formula.MultiPlatformFormula, error) {
+	return nil, errors.New("not implemented")
+}
+
+// FetchPlatformFormula implements formula.PlatformFormulary.
+func (store testFormulary) FetchPlatformFormula(_ context.Context, name string, plat platform.Platform) (formula.PlatformFormula, error) {
+	deps, ok := store[name]
+	if !ok {
+		return nil, errdef.NewFormulaNotFoundError(name)
+	}
+	return testFormula(plat, name, deps...), nil
+}
+
+func testFormula(plat platform.Platform, name string, deps ...string) formula.PlatformFormula {
+	return formula.PlatformFromV1(plat, &brewv1.PlatformInfo{
+		Name:         name,
+		Versions:     brewv1.Versions{Stable: "1.0"},
+		Dependencies: deps,
+	})
+}
+
+func TestWalk(t *testing.T) {
+	store := testFormulary{
+		"app":  {"lib", "util"},
+		"tool": {"util"},
+		"lib":  {"util"},
+		"util": {},
+	}
+	roots := []formula.PlatformFormula{
+		testFormula(platform.X8664Linux, "app", store["app"]...),
+		testFormula(platform.X8664Linux, "tool", store["tool"]...),
+	}
+
+	graph, err := Walk(context.Background(), store, roots, platform.X8664Linux, &formula.DependencyTags{}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := formula.Names(graph.Dependencies()); !slices.Equal(got, []string{"lib", "util"}) {
+		t.Errorf("Dependencies() = %v", got)
+	}
+	if got, _ := graph.DependenciesOf("app"); !slices.Equal(got, []string{"lib", "util"}) {
+		t.Errorf("DependenciesOf(app) = %v", got)
+	}
+	if got := formula.Names(graph.Dependents("util", false)); !slices.Equal(got, []string{"app", "tool"}) {
+		t.Errorf("Dependents(util, false) = %v", got)
+	}
+	if got := formula.Names(graph.Dependents("lib", false)); !slices.Equal(got, []string{"app"}) {
+		t.Errorf("Dependents(lib, false) = %v", got)
+	}
+
+	tree, err := graph.Tree("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app\n├── lib\n│   └── util\n└── util\n"
+	if got := tree.String(); got != want {
+		t.Errorf("Tree(app) =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// countingFormulary counts the fetches of each name, answering after a random delay.
+type countingFormulary struct {
+	testFormulary
+
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+// FetchPlatformFormula implements formula.PlatformFormulary.
+func (store *countingFormulary) FetchPlatformFormula(ctx context.Context, name string, plat platform.Platform) (formula.PlatformFormula, error) {
+	store.mu.Lock()
+	store.fetches[name]++
+	store.mu.Unlock()
+	time.Sleep(time.Duration(rand.IntN(1000)) * time.Microsecond)
+	return store.testFormulary.FetchPlatformFormula(ctx, name, plat)
+}
+
+func TestWalkConcurrent(t *testing.T) {
+	// Layers of formulae that each depend on every formula in the next layer
+	store := testFormulary{}
+	const layers, width = 4, 5
+	for layer := range layers {
+		for i := range width {
+			deps := []string{}
+			if layer < layers-1 {
+				for j := range width {
+					deps = append(deps, fmt.Sprintf("layer%d-%d", layer+1, j))
+				}
+			}
+			store[fmt.Sprintf("layer%d-%d", layer, i)] = deps
+		}
+	}
+	roots := []formula.PlatformFormula{}
+	for i := range width {
+		name := fmt.Sprintf("layer0-%d", i)
+		roots = append(roots, testFormula(platform.X8664Linux, name, store[name]...))
+	}
+
+	var want []string
+	for range 5 {
+		counter := &countingFormulary{testFormulary: store, fetches: map[string]int{}}
+		graph, err := Walk(context.Background(), counter, roots, platform.X8664Linux, &formula.DependencyTags{}, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Each name is fetched once and roots are not fetched again
+		for name, n := range counter.fetches {
+			if n != 1 {
+				t.Errorf("fetched %s %d times", name, n)
+			}
+		}
+		if got := len(counter.fetches); got != (layers-1)*width {
+			t.Errorf("fetched %d formulae, want %d", got, (layers-1)*width)
+		}
+
+		// Ordering does not depend on the order fetches finish in
+		got := formula.Names(graph.Dependencies())
+		if want == nil {
+			want = got
+		} else if !slices.Equal(got, want) {
+			t.Errorf("Dependencies() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWalkNotFound(t *testing.T) {
+	store := testFormulary{"app": {"lib", "missing"}, "lib": {}}
+	roots := []formula.PlatformFormula{testFormula(platform.X8664Linux, "app", store["app"]...)}
+
+	_, err := Walk(context.Background(), store, roots, platform.X8664Linux, &formula.DependencyTags{}, 4)
+	if !errors.As(err, &errdef.FormulaNotFoundError{}) {
+		t.Errorf("Walk() error = %v, want a FormulaNotFoundError", err)
+	}
+}
+
+func TestWalkCycle(t *testing.T) {
+	store := testFormulary{
+		"app": {"a"},
+		"a":   {"b"},
+		"b":   {"c"},
+		"c":   {"a"},
+	}
+	roots := []formula.PlatformFormula{testFormula(platform.X8664Linux, "app", "a")}
+
+	_, err := Walk(context.Background(), store, roots, platform.X8664Linux, &formula.DependencyTags{}, 4)
+	var cycleErr errdef.DependencyCycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Walk() error = %v, want a DependencyCycleError", err)
+	}
+	if got := cycleErr.Cycle(); !slices.Equal(got, []string{"a", "b", "c", "a"}) {
+		t.Errorf("Cycle() = %v", got)
+	}
+	if want := "dependency cycle between formulae: a -> b -> c -> a"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	formulae := []formula.PlatformFormula{
+		testFormula(platform.X8664Linux, "lib", "util", "installed"),
+		testFormula(platform.X8664Linux, "app", "lib"),
+		testFormula(platform.X8664Linux, "util"),
+	}
+
+	graph, err := New(formulae, &formula.DependencyTags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dependencies outside the list are left out
+	if got, _ := graph.DependenciesOf("app"); !slices.Equal(got, []string{"lib", "util"}) {
+		t.Errorf("DependenciesOf(app) = %v", got)
+	}
+	if got := formula.Names(graph.Roots()); !slices.Equal(got, []string{"app"}) {
+		t.Errorf("Roots() = %v", got)
+	}
+
+	formulae = append(formulae, testFormula(platform.X8664Linux, "self", "self"))
+	_, err = New(formulae, &formula.DependencyTags{})
+	if want := "dependency cycle between formulae: self -> self"; err == nil || err.Error() != want {
+		t.Errorf("New() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,246 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	brewenv "github.com/act3-ai/hops/internal/apis/config.brew.sh"
+	hopsv1 "github.com/act3-ai/hops/internal/apis/config.hops.io/v1beta1"
+	brewformulary "github.com/act3-ai/hops/internal/brew/formulary"
+	"github.com/act3-ai/hops/internal/prefix"
+)
+
+// shellenvFix suggests setting up the shell environment.
+const shellenvFix = "Add `eval \"$(hops shellenv)\"` to the end of your shell profile."
+
+// Path finds problems with the prefix in the PATH.
+type Path struct {
+	Prefix prefix.Prefix
+	Path   string // value of the PATH environment variable
+}
+
+// Name implements Check.
+func (c *Path) Name() string {
+	return "path"
+}
+
+// Description implements Check.
+func (c *Path) Description() string {
+	return "Check that the prefix is in the PATH before system directories"
+}
+
+// Run implements Check.
+func (c *Path) Run(_ context.Context) ([]Problem, error) {
+	entries := filepath.SplitList(c.Path)
+	for i, e := range entries {
+		entries[i] = filepath.Clean(e)
+	}
+
+	bin := filepath.Join(c.Prefix.String(), "bin")
+	i := slices.Index(entries, bin)
+	if i < 0 {
+		return []Problem{{
+			Severity: SeverityWarning,
+			Message:  bin + " is not in your PATH.",
+			Fix:      shellenvFix,
+		}}, nil
+	}
+
+	// System programs should not shadow installed formulae
+	before := []string{}
+	for _, dir := range []string{"/usr/bin", "/bin", "/usr/sbin", "/sbin"} {
+		if j := slices.Index(entries, dir); j >= 0 && j < i {
+			before = append(before, dir)
+		}
+	}
+	if len(before) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  "System directories occur before " + bin + " in your PATH, so system programs will be run instead of installed formulae.",
+		Details:  before,
+		Fix:      shellenvFix,
+	}}, nil
+}
+
+// DefaultCacheMaxAge is the default age of a stale API cache.
+const DefaultCacheMaxAge = 7 * 24 * time.Hour
+
+// APICache finds a missing or stale Homebrew API cache.
+type APICache struct {
+	Dir        string                    // cache directory
+	AutoUpdate *brewenv.AutoUpdateConfig // auto-update configuration
+	MaxAge     time.Duration             // age of a stale cache
+}
+
+// Name implements Check.
+func (c *APICache) Name() string {
+	return "api-cache"
+}
+
+// Description implements Check.
+func (c *APICache) Description() string {
+	return "Check that the Homebrew API cache exists and is up to date"
+}
+
+// Run implements Check.
+func (c *APICache) Run(_ context.Context) ([]Problem, error) {
+	file := brewformulary.FormulaeFile(c.Dir)
+	info, err := os.Stat(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return []Problem{{
+			Severity: SeverityWarning,
+			Message:  "The Homebrew API has not been cached.",
+			Details:  []string{file},
+			Fix:      "Run `hops update` to download it.",
+		}}, nil
+	case err != nil:
+		return nil, fmt.Errorf("checking API cache: %w", err)
+	}
+
+	age := time.Since(info.ModTime())
+	// Caches that are refreshed on the next use are not stale
+	if age < c.MaxAge || (c.AutoUpdate != nil && c.AutoUpdate.ShouldAutoUpdate(file)) {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("The Homebrew API cache was last updated %d days ago and is not updated automatically.", int(age.Hours()/24)),
+		Details:  []string{file},
+		Fix:      "Run `hops update` to update it, or enable auto-updates by unsetting HOMEBREW_NO_AUTO_UPDATE.",
+	}}, nil
+}
+
+// Pinger is implemented by registries that can be pinged.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Registry finds an unreachable bottle registry.
+type Registry struct {
+	Prefix   string // configured registry prefix
+	Registry Pinger
+}
+
+// Name implements Check.
+func (c *Registry) Name() string {
+	return "registry"
+}
+
+// Description implements Check.
+func (c *Registry) Description() string {
+	return "Check that the configured bottle registry is reachable"
+}
+
+// Run implements Check.
+func (c *Registry) Run(ctx context.Context) ([]Problem, error) {
+	if err := c.Registry.Ping(ctx); err != nil {
+		return []Problem{{
+			Severity: SeverityError,
+			Message:  "The bottle registry " + c.Prefix + " is unreachable.",
+			Details:  []string{err.Error()},
+			Fix:      "Check your network connection and the registry settings in `hops config`, and log in to the registry if it requires authentication.",
+		}}, nil
+	}
+	return nil, nil
+}
+
+// Config finds an invalid configuration.
+type Config struct {
+	Files  []string              // config files in the order they are searched
+	Config *hopsv1.Configuration // loaded configuration
+}
+
+// Name implements Check.
+func (c *Config) Name() string {
+	return "config"
+}
+
+// Description implements Check.
+func (c *Config) Description() string {
+	return "Check that the configuration is valid"
+}
+
+// Run implements Check.
+func (c *Config) Run(_ context.Context) ([]Problem, error) {
+	var problems []Problem
+
+	file, err := c.checkFile()
+	if err != nil {
+		problems = append(problems, Problem{
+			Severity: SeverityError,
+			Message:  "The config file " + file + " is invalid.",
+			Details:  []string{err.Error()},
+			Fix:      "Fix or remove " + file + ".",
+		})
+	}
+
+	invalid := []string{}
+	if !filepath.IsAbs(c.Config.Prefix) {
+		invalid = append(invalid, "prefix: must be an absolute path")
+	}
+	if !filepath.IsAbs(c.Config.Cache) {
+		invalid = append(invalid, "cache: must be an absolute path")
+	}
+	if _, err := c.Config.Registry.ParseHeaders(); err != nil {
+		invalid = append(invalid, "registry.headers: "+err.Error())
+	}
+	if c.Config.Registry.OCILayout {
+		if info, err := os.Stat(c.Config.Registry.Prefix); err != nil || !info.IsDir() {
+			invalid = append(invalid, "registry.prefix: must be a directory when registry.ociLayout is set")
+		}
+	}
+	if c.Config.Registry.Config != "" {
+		if _, err := os.Stat(c.Config.Registry.Config); err != nil {
+			invalid = append(invalid, "registry.config: "+err.Error())
+		}
+	}
+	if _, err := c.Config.Cleanup.ParseMaxSize(); err != nil {
+		invalid = append(invalid, "cleanup.maxSize: "+err.Error())
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, Problem{
+			Severity: SeverityError,
+			Message:  "The configuration has invalid settings.",
+			Details:  invalid,
+			Fix:      "Correct these settings in your config file or environment, see `hops config`.",
+		})
+	}
+
+	return problems, nil
+}
+
+// checkFile strictly parses the first config file found, returning its name
+// and any error.
+func (c *Config) checkFile() (string, error) {
+	for _, file := range c.Files {
+		content, err := os.ReadFile(file)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			continue
+		case err != nil:
+			return file, err
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(&hopsv1.Configuration{})
+		if errors.Is(err, io.EOF) {
+			return file, nil // empty file
+		}
+		return file, err
+	}
+	return "", nil
+}
@@ -0,0 +1,65 @@
+// Package doctor diagnoses problems with a Homebrew prefix and hops' configuration.
+//
+// Each kind of problem is found by a Check. Checks are independent of each
+// other, so callers choose which checks to run and may add their own.
+package doctor
+
+import (
+	"context"
+
+	"github.com/sourcegraph/conc/iter"
+)
+
+// Severity describes how serious a problem is.
+type Severity string
+
+// Known severities.
+const (
+	SeverityWarning Severity = "warning" // something may not work as expected
+	SeverityError   Severity = "error"   // something will not work
+)
+
+// Problem is a problem found by a Check.
+type Problem struct {
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`           // describes the problem
+	Details  []string `json:"details,omitempty"` // lists the affected files, formulae, or settings
+	Fix      string   `json:"fix"`               // suggests how to fix the problem
+}
+
+// Check diagnoses one kind of problem.
+type Check interface {
+	// Name identifies the check.
+	Name() string
+	// Description describes what the check looks for.
+	Description() string
+	// Run runs the check, returning the problems found.
+	Run(ctx context.Context) ([]Problem, error)
+}
+
+// Result is the outcome of running a Check.
+type Result struct {
+	Check    string    `json:"check"`
+	Problems []Problem `json:"problems"`
+	Error    string    `json:"error,omitempty"` // set if the check could not be completed
+}
+
+// Run runs the checks concurrently, returning their results in the order of the checks.
+func Run(ctx context.Context, checks []Check, maxGoroutines int) []Result {
+	mapper := iter.Mapper[Check, Result]{MaxGoroutines: maxGoroutines}
+	return mapper.Map(checks, func(c *Check) Result {
+		check := *c
+		result := Result{
+			Check:    check.Name(),
+			Problems: []Problem{},
+		}
+		problems, err := check.Run(ctx)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if problems != nil {
+			result.Problems = problems
+		}
+		return result
+	})
+}
@@ -0,0 +1,335 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/utils/logutil"
+)
+
+// BrokenLinks finds symlinks in the prefix that point to missing files.
+type BrokenLinks struct {
+	Prefix prefix.Prefix
+}
+
+// Name implements Check.
+func (c *BrokenLinks) Name() string {
+	return "broken-links"
+}
+
+// Description implements Check.
+func (c *BrokenLinks) Description() string {
+	return "Find broken symlinks in the prefix"
+}
+
+// Run implements Check.
+func (c *BrokenLinks) Run(_ context.Context) ([]Problem, error) {
+	broken, err := c.Prefix.BrokenLinks()
+	if err != nil {
+		return nil, err
+	}
+	if len(broken) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  "Broken symlinks were found in the prefix.",
+		Details:  broken,
+		Fix:      "Run `hops cleanup` to remove them.",
+	}}, nil
+}
+
+// UnlinkedKegs finds installed formulae that are not linked into the prefix
+// even though they are not keg-only.
+type UnlinkedKegs struct {
+	Prefix    prefix.Prefix
+	Formulary func(ctx context.Context) (formula.Formulary, error) // loads the formulary when needed
+	Platform  platform.Platform
+}
+
+// Name implements Check.
+func (c *UnlinkedKegs) Name() string {
+	return "unlinked-kegs"
+}
+
+// Description implements Check.
+func (c *UnlinkedKegs) Description() string {
+	return "Find installed formulae that are not keg-only but are not linked"
+}
+
+// Run implements Check.
+func (c *UnlinkedKegs) Run(ctx context.Context) ([]Problem, error) {
+	racks, err := c.Prefix.Racks()
+	if err != nil {
+		return nil, err
+	}
+
+	var store formula.Formulary
+	unlinked := []string{}
+	for _, rack := range racks {
+		keg, err := c.Prefix.LinkedKeg(rack.Name())
+		switch {
+		case err != nil:
+			return nil, err
+		case keg != "":
+			continue
+		}
+
+		if store == nil {
+			store, err = c.Formulary(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		f, err := formula.FetchPlatform(ctx, store, rack.Name(), c.Platform)
+		if err != nil {
+			// Formulae that are no longer available cannot be checked
+			slog.Debug("skipping unlinked keg", slog.String("formula", rack.Name()), logutil.ErrAttr(err))
+			continue
+		}
+		if !f.IsKegOnly() {
+			unlinked = append(unlinked, rack.Name())
+		}
+	}
+
+	if len(unlinked) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  "Formulae that are not keg-only are installed but not linked.",
+		Details:  unlinked,
+		Fix:      "Run `hops link " + strings.Join(unlinked, " ") + "` to link them.",
+	}}, nil
+}
+
+// MissingReceipts finds kegs without a readable install receipt.
+type MissingReceipts struct {
+	Prefix prefix.Prefix
+}
+
+// Name implements Check.
+func (c *MissingReceipts) Name() string {
+	return "missing-receipts"
+}
+
+// Description implements Check.
+func (c *MissingReceipts) Description() string {
+	return "Find kegs without a readable " + receipt.InstallReceiptFile
+}
+
+// Run implements Check.
+func (c *MissingReceipts) Run(_ context.Context) ([]Problem, error) {
+	kegs, err := c.Prefix.Kegs()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	names := []string{}
+	for _, keg := range kegs {
+		r, err := receipt.Load(keg.String())
+		switch {
+		case err != nil:
+			missing = append(missing, fmt.Sprintf("%s (%s)", keg, err))
+		case r == nil:
+			missing = append(missing, keg.String())
+		default:
+			continue
+		}
+		if !slices.Contains(names, keg.Name()) {
+			names = append(names, keg.Name())
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  "Some kegs are missing their install receipts.",
+		Details:  missing,
+		Fix:      "Run `hops reinstall " + strings.Join(names, " ") + "` to restore them.",
+	}}, nil
+}
+
+// MissingDependencies finds installed formulae whose runtime dependencies are not installed.
+type MissingDependencies struct {
+	Prefix prefix.Prefix
+}
+
+// Name implements Check.
+func (c *MissingDependencies) Name() string {
+	return "missing-dependencies"
+}
+
+// Description implements Check.
+func (c *MissingDependencies) Description() string {
+	return "Find installed formulae with runtime dependencies that are not installed"
+}
+
+// Run implements Check.
+func (c *MissingDependencies) Run(_ context.Context) ([]Problem, error) {
+	racks, err := c.Prefix.Racks()
+	if err != nil {
+		return nil, err
+	}
+
+	details := []string{}
+	missing := []string{}
+	for _, rack := range racks {
+		// Only the keg in use is checked, older kegs may have other dependencies
+		keg, err := c.Prefix.OptKeg(rack.Name())
+		if err != nil || keg == "" {
+			continue
+		}
+		r, err := receipt.Load(keg.String())
+		if err != nil || r == nil {
+			continue // reported by MissingReceipts
+		}
+
+		for _, dep := range r.RuntimeDependencies {
+			// Dependencies from taps are named by their full name
+			name := path.Base(dep.FullName)
+			kegs, err := c.Prefix.InstalledKegsByName(name)
+			if err != nil {
+				return nil, err
+			}
+			if len(kegs) > 0 {
+				continue
+			}
+			details = append(details, rack.Name()+" requires "+name)
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityError,
+		Message:  "Some installed formulae are missing dependencies.",
+		Details:  details,
+		Fix:      "Run `hops install " + strings.Join(missing, " ") + "` to install them.",
+	}}, nil
+}
+
+// RenamedFormulae finds formulae installed under a name they were renamed from.
+type RenamedFormulae struct {
+	Prefix  prefix.Prefix
+	Renames func(ctx context.Context) (map[string]string, error) // loads the map of old names to new names when needed
+}
+
+// Name implements Check.
+func (c *RenamedFormulae) Name() string {
+	return "renamed-formulae"
+}
+
+// Description implements Check.
+func (c *RenamedFormulae) Description() string {
+	return "Find formulae installed under an old name that have not been migrated"
+}
+
+// Run implements Check.
+func (c *RenamedFormulae) Run(ctx context.Context) ([]Problem, error) {
+	racks, err := c.Prefix.Racks()
+	if err != nil {
+		return nil, err
+	}
+	if len(racks) == 0 {
+		return nil, nil
+	}
+
+	renames, err := c.Renames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := []string{}
+	names := []string{}
+	for _, rack := range racks {
+		name, ok := renames[rack.Name()]
+		if !ok {
+			continue
+		}
+		details = append(details, rack.Name()+" was renamed to "+name)
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return []Problem{{
+		Severity: SeverityWarning,
+		Message:  "Some installed formulae were renamed.",
+		Details:  details,
+		Fix:      "Run `hops migrate " + strings.Join(names, " ") + "` to move them to their new names.",
+	}}, nil
+}
+
+// Permissions finds directories in the prefix that cannot be written to.
+type Permissions struct {
+	Prefix prefix.Prefix
+}
+
+// Name implements Check.
+func (c *Permissions) Name() string {
+	return "permissions"
+}
+
+// Description implements Check.
+func (c *Permissions) Description() string {
+	return "Find prefix directories that are not writable"
+}
+
+// Run implements Check.
+func (c *Permissions) Run(_ context.Context) ([]Problem, error) {
+	notDirs := []string{}
+	notWritable := []string{}
+	for _, dir := range c.Prefix.MustExistDirectories() {
+		info, err := os.Stat(dir)
+		switch {
+		// Missing directories are created when needed
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			notWritable = append(notWritable, fmt.Sprintf("%s (%s)", dir, err))
+		case !info.IsDir():
+			notDirs = append(notDirs, dir)
+		case !writable(dir):
+			notWritable = append(notWritable, dir)
+		}
+	}
+
+	var problems []Problem
+	if len(notDirs) > 0 {
+		problems = append(problems, Problem{
+			Severity: SeverityError,
+			Message:  "Some paths in the prefix must be directories.",
+			Details:  notDirs,
+			Fix:      "Move these files out of the way.",
+		})
+	}
+	if len(notWritable) > 0 {
+		problems = append(problems, Problem{
+			Severity: SeverityError,
+			Message:  "Some directories in the prefix are not writable by you.",
+			Details:  notWritable,
+			Fix:      "Change their ownership to your user with `sudo chown -R \"$USER\"` and make them writable with `chmod u+w`.",
+		})
+	}
+	return problems, nil
+}
@@ -0,0 +1,8 @@
+//go:build !(darwin || linux)
+
+package doctor
+
+// writable always reports true, Homebrew prefixes are only supported on macOS and Linux.
+func writable(string) bool {
+	return true
+}
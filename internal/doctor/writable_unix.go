@@ -0,0 +1,13 @@
+//go:build darwin || linux
+
+package doctor
+
+import "syscall"
+
+// wOK is the access mode for write permission (W_OK).
+const wOK = 0x2
+
+// writable reports whether the current user can write to the path.
+func writable(path string) bool {
+	return syscall.Access(path, wOK) == nil
+}
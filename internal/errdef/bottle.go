@@ -0,0 +1,57 @@
+package errdef
+
+// DigestMismatchError reports a bottle whose content does not match its expected digest.
+type DigestMismatchError struct {
+	name     string
+	expected string
+	actual   string
+}
+
+// Error implements error.
+func (err DigestMismatchError) Error() string {
+	return "SHA-256 mismatch for " + err.name + "\nExpected: " + err.expected + "\n  Actual: " + err.actual
+}
+
+// Expected produces the expected digest.
+func (err DigestMismatchError) Expected() string {
+	return err.expected
+}
+
+// Actual produces the digest of the content.
+func (err DigestMismatchError) Actual() string {
+	return err.actual
+}
+
+// NewDigestMismatchError produces a DigestMismatchError.
+func NewDigestMismatchError(name, expected, actual string) error {
+	return DigestMismatchError{
+		name:     name,
+		expected: expected,
+		actual:   actual,
+	}
+}
+
+// LockMismatchError reports a bottle that no longer matches its lock file entry.
+type LockMismatchError struct {
+	name   string
+	field  string
+	locked string
+	actual string
+}
+
+// Error implements error.
+func (err LockMismatchError) Error() string {
+	return err.field + " mismatch for " + err.name + " in lock file\nLocked: " + err.locked + "\nActual: " + err.actual
+}
+
+// NewLockMismatchError produces a LockMismatchError.
+//
+// The field names what does not match, such as "Version".
+func NewLockMismatchError(name, field, locked, actual string) error {
+	return LockMismatchError{
+		name:   name,
+		field:  field,
+		locked: locked,
+		actual: actual,
+	}
+}
@@ -2,6 +2,7 @@ package errdef
 
 import (
 	"strconv"
+	"strings"
 )
 
 // FormulaNotFoundError is emitted when a a formula could not be found.
@@ -37,3 +38,63 @@ func NewFormulaUpToDateError(name, version string) error {
 		version: version,
 	}
 }
+
+// FormulaConflictError reports a formula that conflicts with linked formulae.
+type FormulaConflictError struct {
+	name      string
+	conflicts []string // names of the conflicting formulae
+	reasons   []string // reason for each conflict, may be empty
+}
+
+// Error implements error.
+func (err FormulaConflictError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("Cannot install " + err.name + " because conflicting formulae are installed.\n")
+	for i, c := range err.conflicts {
+		b.WriteString("  " + c)
+		if i < len(err.reasons) && err.reasons[i] != "" {
+			b.WriteString(": because " + err.reasons[i])
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nPlease `hops unlink " + strings.Join(err.conflicts, " ") + "` before continuing,")
+	b.WriteString("\nor install " + err.name + " without linking it using --unlinked.")
+	return b.String()
+}
+
+// Conflicts produces the names of the conflicting formulae.
+func (err FormulaConflictError) Conflicts() []string {
+	return err.conflicts
+}
+
+// NewFormulaConflictError produces a FormulaConflictError.
+//
+// Each conflicting formula is given with its reason, which may be empty.
+func NewFormulaConflictError(name string, conflicts, reasons []string) error {
+	return FormulaConflictError{
+		name:      name,
+		conflicts: conflicts,
+		reasons:   reasons,
+	}
+}
+
+// DependencyCycleError reports formulae that depend on each other.
+type DependencyCycleError struct {
+	cycle []string // names of the formulae in the cycle, starting and ending with the same formula
+}
+
+// Error implements error.
+func (err DependencyCycleError) Error() string {
+	return "dependency cycle between formulae: " + strings.Join(err.cycle, " -> ")
+}
+
+// Cycle produces the names of the formulae in the cycle,
+// starting and ending with the same formula.
+func (err DependencyCycleError) Cycle() []string {
+	return err.cycle
+}
+
+// NewDependencyCycleError produces a DependencyCycleError.
+func NewDependencyCycleError(cycle []string) error {
+	return DependencyCycleError{cycle: cycle}
+}
@@ -0,0 +1,42 @@
+package errdef
+
+import (
+	"path/filepath"
+	"strconv"
+)
+
+// LockedError reports a lock file held by another process.
+type LockedError struct {
+	path   string
+	holder string // command line of the holding process, if known
+	pid    int    // PID of the holding process, if known
+}
+
+// Error implements error.
+func (err LockedError) Error() string {
+	return err.Holder() + " has already locked " + filepath.Base(err.path) + ".\nPlease wait for it to finish or terminate it to continue."
+}
+
+// Holder describes the process holding the lock.
+func (err LockedError) Holder() string {
+	if err.holder == "" {
+		return "Another process"
+	}
+	return "A `" + err.holder + "` process (PID " + strconv.Itoa(err.pid) + ")"
+}
+
+// Path produces the path to the lock file.
+func (err LockedError) Path() string {
+	return err.path
+}
+
+// NewLockedError produces a LockedError.
+//
+// The holder and PID are unknown if holder is empty.
+func NewLockedError(path, holder string, pid int) error {
+	return LockedError{
+		path:   path,
+		holder: holder,
+		pid:    pid,
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 
+	tab "github.com/act3-ai/hops/internal/apis/sh.brew.tab"
 	"github.com/act3-ai/hops/internal/formula"
 )
 
@@ -21,6 +22,22 @@ type (
 		Registry
 		FetchBottles(ctx context.Context, flist []formula.PlatformFormula) ([]io.ReadCloser, error)
 	}
+
+	// TabRegistry is a source of Bottles that can provide each Bottle's Tab.
+	TabRegistry interface {
+		Registry
+		// FetchTab fetches the Tab annotated on a Bottle's manifest.
+		// A return value of nil, nil signifies that the Bottle has no Tab.
+		FetchTab(ctx context.Context, f formula.PlatformFormula) (*tab.Tab, error)
+	}
+
+	// Downloader is a source of Bottles that caches them locally.
+	Downloader interface {
+		Registry
+		// DownloadBottle downloads a Bottle to the cache and verifies its digest.
+		// Returns true if the Bottle was already cached.
+		DownloadBottle(ctx context.Context, f formula.PlatformFormula) (cached bool, err error)
+	}
 )
 
 // Fetch fetches a Bottle from the BottleRegistry.
@@ -28,6 +45,32 @@ func Fetch(ctx context.Context, src Registry, f formula.PlatformFormula) (io.Rea
 	return src.FetchBottle(ctx, f)
 }
 
+// FetchTab fetches a Bottle's Tab from the BottleRegistry.
+//
+// A return value of nil, nil signifies that the BottleRegistry does not provide a Tab for the Bottle.
+func FetchTab(ctx context.Context, src Registry, f formula.PlatformFormula) (*tab.Tab, error) {
+	if src, ok := src.(TabRegistry); ok {
+		return src.FetchTab(ctx, f)
+	}
+	return nil, nil
+}
+
+// Download downloads a Bottle from the BottleRegistry to its cache.
+//
+// Bottles from a BottleRegistry without a cache are fetched and discarded,
+// and are never reported as cached.
+func Download(ctx context.Context, src Registry, f formula.PlatformFormula) (cached bool, err error) {
+	if src, ok := src.(Downloader); ok {
+		return src.DownloadBottle(ctx, f)
+	}
+	r, err := src.FetchBottle(ctx, f)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(io.Discard, r)
+	return false, errors.Join(err, r.Close())
+}
+
 // FetchAll fetches Bottles from the BottleRegistry.
 func FetchAll(ctx context.Context, src Registry, formulae []formula.PlatformFormula) ([]io.ReadCloser, error) {
 	// Closes all readers and returns a combined error
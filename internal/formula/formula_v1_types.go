@@ -33,6 +33,13 @@ func (f *V1) Info() *Info {
 	return f.info
 }
 
+// PlatformV1 is implemented by PlatformFormulae produced from v1 API output.
+type PlatformV1 interface {
+	PlatformFormula
+	// SourceV1 returns the underlying v1 API output.
+	SourceV1() *brewv1.PlatformInfo
+}
+
 // platformFormulaV1 implements PlatformFormula for v1 API output.
 type platformFormulaV1 struct {
 	src       brewv1.PlatformInfo
@@ -43,6 +50,11 @@ type platformFormulaV1 struct {
 	bottle    *Bottle
 }
 
+// SourceV1 implements PlatformV1.
+func (p *platformFormulaV1) SourceV1() *brewv1.PlatformInfo {
+	return &p.src
+}
+
 // Bottle implements PlatformFormula.
 func (p *platformFormulaV1) Bottle() *Bottle {
 	return p.bottle
@@ -85,10 +97,12 @@ func (p *platformFormulaV1) Conflicts() []Conflict {
 	if p.conflicts == nil {
 		p.conflicts = make([]Conflict, 0, len(p.src.ConflictsWith))
 		for i, with := range p.src.ConflictsWith {
-			p.conflicts = append(p.conflicts, Conflict{
-				Name:   with,
-				Reason: p.src.ConflictsWithReasons[i],
-			})
+			c := Conflict{Name: with}
+			// Reasons are optional
+			if i < len(p.src.ConflictsWithReasons) {
+				c.Reason = p.src.ConflictsWithReasons[i]
+			}
+			p.conflicts = append(p.conflicts, c)
 		}
 	}
 	return p.conflicts
@@ -203,6 +217,8 @@ func infoFromV1(input *brewv1.PlatformInfo) *Info {
 		Desc:     input.Desc,
 		License:  input.License,
 		Homepage: input.Homepage,
+		Aliases:  input.Aliases,
+		OldNames: input.OldNames,
 	}
 }
 
@@ -240,10 +256,5 @@ func bottleFromV1(plat platform.Platform, input *brewv1.PlatformInfo) *Bottle {
 		PourOnlyIf: pourOnlyIf,
 	}
 
-	// Set Cellar ot empty string if relocatable
-	if file.Relocatable() {
-		bottle.Cellar = ""
-	}
-
 	return bottle
 }
@@ -14,6 +14,8 @@ type (
 		Desc     string
 		License  string
 		Homepage string
+		Aliases  []string // other names the Formula can be found by
+		OldNames []string // names the Formula was renamed from
 	}
 
 	// SourceInfo defines source information for a Formula.
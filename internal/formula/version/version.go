@@ -0,0 +1,251 @@
+// Package version compares formula versions as Homebrew does.
+//
+// Versions are split into numeric, alphabetic, pre-release, and post-release
+// tokens that are compared in order, following Homebrew's Version class:
+//
+//	1.0alpha1 < 1.0beta1 < 1.0pre1 < 1.0rc1 < 1.0 < 1.0a < 1.0p1 < 1.0.post1 < 1.0.1
+//
+// Package versions add a revision to the version, as in "1.0_1".
+package version
+
+import (
+	"cmp"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a formula version, as in "1.2.3".
+type Version string
+
+// Compare compares two versions,
+// returning -1 if v < w, 0 if v == w, and 1 if v > w.
+func (v Version) Compare(w Version) int {
+	switch {
+	case v == w:
+		return 0
+	case v == "":
+		return -1
+	case w == "":
+		return 1
+	case v.Head() && !w.Head():
+		return 1
+	case !v.Head() && w.Head():
+		return -1
+	case v.Head() && w.Head():
+		return 0
+	}
+
+	ltokens, rtokens := tokenize(string(v)), tokenize(string(w))
+	end := max(len(ltokens), len(rtokens))
+	l, r := 0, 0
+	for l < end {
+		a, b := tokenAt(ltokens, l), tokenAt(rtokens, r)
+		switch {
+		case a.compare(b) == 0:
+			l++
+			r++
+		case a.kind == numericKind && b.kind != numericKind:
+			// Numeric tokens take precedence over non-numeric tokens
+			if a.compare(nullToken) > 0 {
+				return 1
+			}
+			l++
+		case a.kind != numericKind && b.kind == numericKind:
+			if b.compare(nullToken) > 0 {
+				return -1
+			}
+			r++
+		default:
+			return a.compare(b)
+		}
+	}
+	return 0
+}
+
+// Head reports whether the version is a HEAD version, which is newer than every other version.
+func (v Version) Head() bool {
+	return strings.HasPrefix(string(v), "HEAD")
+}
+
+// PkgVersion is a formula version with its revision.
+type PkgVersion struct {
+	Version  Version
+	Revision int
+}
+
+// ParsePkgVersion parses a package version, as in "1.2.3_1".
+//
+// The revision is zero if the package version has no revision.
+func ParsePkgVersion(s string) PkgVersion {
+	if i := strings.LastIndex(s, "_"); i > 0 {
+		if revision, err := strconv.Atoi(s[i+1:]); err == nil && isDigits(s[i+1:]) {
+			return PkgVersion{Version: Version(s[:i]), Revision: revision}
+		}
+	}
+	return PkgVersion{Version: Version(s)}
+}
+
+// String produces the package version, omitting a zero revision.
+func (v PkgVersion) String() string {
+	if v.Revision == 0 {
+		return string(v.Version)
+	}
+	return string(v.Version) + "_" + strconv.Itoa(v.Revision)
+}
+
+// Compare compares two package versions by version and then by revision,
+// returning -1 if v < w, 0 if v == w, and 1 if v > w.
+func (v PkgVersion) Compare(w PkgVersion) int {
+	return cmp.Or(v.Version.Compare(w.Version), cmp.Compare(v.Revision, w.Revision))
+}
+
+// Compare compares two package versions,
+// returning -1 if a < b, 0 if a == b, and 1 if a > b.
+func Compare(a, b string) int {
+	return ParsePkgVersion(a).Compare(ParsePkgVersion(b))
+}
+
+// tokenKind is the kind of a version token.
+//
+// Pre-release and post-release kinds are ordered by precedence.
+type tokenKind int
+
+const (
+	nullKind tokenKind = iota
+	numericKind
+	stringKind
+	alphaKind
+	betaKind
+	preKind
+	rcKind
+	patchKind
+	postKind
+)
+
+// token is a part of a version.
+type token struct {
+	kind  tokenKind
+	value string
+	rev   string // number of a pre-release or post-release token
+}
+
+// nullToken pads the shorter version when versions are compared.
+var nullToken = token{kind: nullKind}
+
+// tokenPatterns match each kind of token, in the order they are matched.
+var tokenPatterns = []struct {
+	kind    tokenKind
+	pattern string
+}{
+	{alphaKind, `alpha[0-9]*|a[0-9]+`},
+	{betaKind, `beta[0-9]*|b[0-9]+`},
+	{preKind, `pre[0-9]*`},
+	{rcKind, `rc[0-9]*`},
+	{patchKind, `p[0-9]*`},
+	{postKind, `.post[0-9]+`},
+	{numericKind, `[0-9]+`},
+	{stringKind, `[a-z]+`},
+}
+
+// scanPattern matches the next token, capturing it in the group of its kind.
+var scanPattern = func() *regexp.Regexp {
+	groups := make([]string, 0, len(tokenPatterns))
+	for _, p := range tokenPatterns {
+		groups = append(groups, "("+p.pattern+")")
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(groups, "|"))
+}()
+
+// tokenize splits a version into tokens.
+func tokenize(v string) []token {
+	matches := scanPattern.FindAllStringSubmatchIndex(v, -1)
+	tokens := make([]token, 0, len(matches))
+	for _, m := range matches {
+		for i, p := range tokenPatterns {
+			start, end := m[2*i+2], m[2*i+3]
+			if start < 0 {
+				continue
+			}
+			tokens = append(tokens, newToken(p.kind, v[start:end]))
+			break
+		}
+	}
+	return tokens
+}
+
+// newToken creates a token of the kind.
+func newToken(kind tokenKind, s string) token {
+	switch kind {
+	case numericKind:
+		return token{kind: kind, value: trimZeros(s)}
+	case stringKind:
+		return token{kind: kind, value: s}
+	default:
+		// Number at the end of the token
+		i := strings.LastIndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		return token{kind: kind, value: s, rev: trimZeros(s[i+1:])}
+	}
+}
+
+// tokenAt returns the token at the index, or the null token past the end.
+func tokenAt(tokens []token, i int) token {
+	if i < len(tokens) {
+		return tokens[i]
+	}
+	return nullToken
+}
+
+// compare compares two tokens,
+// returning -1 if t < u, 0 if t == u, and 1 if t > u.
+func (t token) compare(u token) int {
+	switch {
+	case t.kind == nullKind && u.kind == nullKind:
+		return 0
+	case t.kind == nullKind:
+		switch u.kind {
+		case numericKind:
+			if u.value == "0" {
+				return 0
+			}
+			return -1
+		case alphaKind, betaKind, preKind, rcKind:
+			return 1 // 1.0 > 1.0rc1
+		default:
+			return -1 // 1.0 < 1.0a < 1.0p1
+		}
+	case u.kind == nullKind:
+		return -u.compare(t)
+	case t.kind == numericKind && u.kind == numericKind:
+		return compareNumbers(t.value, u.value)
+	case t.kind == numericKind:
+		return 1
+	case u.kind == numericKind:
+		return -1
+	case t.kind == stringKind || u.kind == stringKind:
+		return strings.Compare(t.value, u.value)
+	case t.kind != u.kind:
+		return cmp.Compare(t.kind, u.kind)
+	default:
+		return compareNumbers(t.rev, u.rev)
+	}
+}
+
+// compareNumbers compares two numbers of any size without leading zeros.
+func compareNumbers(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+}
+
+// trimZeros removes the leading zeros of a number, keeping zero as "0".
+func trimZeros(s string) string {
+	s = strings.TrimLeft(s, "0")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// isDigits reports whether s is a non-empty string of digits.
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
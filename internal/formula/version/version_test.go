@@ -0,0 +1,119 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	// Cases from the specs of Homebrew's Version and PkgVersion
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// Equal versions
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"2.0", "2", 0},
+		{"1.0.0", "1.00.00", 0},
+		{"1.0.0b7", "1.0.0beta7", 0},
+		{"HEAD-abcdef", "HEAD-fedcba", 0},
+
+		// Numeric tokens
+		{"0.1", "0.2", -1},
+		{"1.2.3", "1.2.2", 1},
+		{"1.2.4", "1.2.4.1", -1},
+		{"1.2.10", "1.2.9", 1},
+		{"0.9.1", "0.10", -1},
+		{"1", "1.0.1", -1},
+		{"20240101", "2024.01.02", 1},
+		{"123456789012345678901234567890", "123456789012345678901234567891", -1},
+
+		// Pre-release tokens are older than the release
+		{"1.2.3alpha4", "1.2.3", -1},
+		{"1.2.3a4", "1.2.3", -1},
+		{"1.2.3beta2", "1.2.3", -1},
+		{"1.2.3b2", "1.2.3", -1},
+		{"1.2.3pre9", "1.2.3", -1},
+		{"1.2.3rc3", "1.2.3", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0rc", "1.0", -1},
+		{"1.1.1beta", "1.1.1", -1},
+		{"1.2.3alpha4", "1.2.3beta2", -1},
+		{"1.2.3beta2", "1.2.3pre9", -1},
+		{"1.2.3pre9", "1.2.3rc3", -1},
+		{"1.2.3alpha4", "1.2.3alpha5", -1},
+		{"1.2.3rc3", "1.2.3rc10", -1},
+		{"1.0rc1", "1.0.1", -1},
+
+		// Patch and post-release tokens are newer than the release
+		{"1.2.3", "1.2.3-p34", -1},
+		{"1.2.3-p34", "1.2.3-p35", -1},
+		{"1.2.3rc3", "1.2.3-p34", -1},
+		{"1.2.3.post1", "1.2.3", 1},
+		{"1.2.3.post1", "1.2.3.post2", -1},
+		{"1.2.3.post1", "1.2.4", -1},
+
+		// Unevenly padded versions
+		{"2.1.0-p194", "2.1-p195", -1},
+		{"2.1-p194", "2.1.0-p195", -1},
+		{"1.9.3-p0", "1.9.3", 1},
+
+		// Alphabetic tokens are newer than the release
+		{"1.0a", "1.0", 1},
+		{"1.0.1a", "1.0.1", 1},
+		{"1.0a", "1.0b", -1},
+		{"2024a", "2024", 1},
+		{"2024a", "2023c", 1},
+		{"2024a", "2024b", -1},
+		{"r29", "r30", -1},
+		{"r29", "r100", -1},
+
+		// HEAD versions are newer than every other version
+		{"HEAD", "1.2.3", 1},
+		{"HEAD-abcdef", "1.2.3", 1},
+		{"", "1.0", -1},
+
+		// Revisions of package versions
+		{"1.0_1", "1.0_1", 0},
+		{"1.0_1", "1.0", 1},
+		{"1.0_1", "1.0_2", -1},
+		{"1.0_10", "1.0_9", 1},
+		{"1.0_1", "1.1", -1},
+		{"1.2.10", "1.2.9_1", 1},
+		{"1.2.9_1", "1.2.9.1", -1},
+		{"3.04_1", "3.04", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" <=> "+tt.b, func(t *testing.T) {
+			if got := Compare(tt.a, tt.b); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := Compare(tt.b, tt.a); got != -tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePkgVersion(t *testing.T) {
+	tests := []struct {
+		s    string
+		want PkgVersion
+	}{
+		{"1.0", PkgVersion{Version: "1.0"}},
+		{"1.0_1", PkgVersion{Version: "1.0", Revision: 1}},
+		{"2024a_12", PkgVersion{Version: "2024a", Revision: 12}},
+		{"1_0_2", PkgVersion{Version: "1_0", Revision: 2}},
+		{"1.0_rc1", PkgVersion{Version: "1.0_rc1"}},
+		{"_1", PkgVersion{Version: "_1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got := ParsePkgVersion(tt.s)
+			if got != tt.want {
+				t.Errorf("ParsePkgVersion(%q) = %+v, want %+v", tt.s, got, tt.want)
+			}
+			if got.String() != tt.s {
+				t.Errorf("ParsePkgVersion(%q).String() = %q", tt.s, got.String())
+			}
+		})
+	}
+}
@@ -9,10 +9,13 @@ import (
 	"strings"
 	"sync"
 
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sourcegraph/conc/iter"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/errdef"
 
+	tab "github.com/act3-ai/hops/internal/apis/sh.brew.tab"
 	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/formula/bottle"
 	"github.com/act3-ai/hops/internal/hops/regbottle"
@@ -26,14 +29,19 @@ import (
 type Client interface {
 	formula.ConcurrentPlatformFormulary
 	bottle.ConcurrentRegistry
+	bottle.TabRegistry
+	bottle.Downloader
 }
 
 // NewClient creates a Hops formulary.
-func NewClient(source hopsreg.Registry, cache *hopsreg.Local, alternateTags map[string]string, maxGoroutines int) Client {
+//
+// Formulae requested by an old name in renames are fetched by their current name.
+func NewClient(source hopsreg.Registry, cache *hopsreg.Local, alternateTags, renames map[string]string, maxGoroutines int) Client {
 	return &formulary{
 		registry:      source,
 		cache:         cache,
 		tags:          alternateTags,
+		renames:       renames,
 		resolved:      sync.Map{},
 		maxGoroutines: maxGoroutines,
 	}
@@ -44,6 +52,7 @@ type formulary struct {
 	registry      hopsreg.Registry
 	cache         *hopsreg.Local
 	tags          map[string]string // map names to special tags to use
+	renames       map[string]string // map old names to current names
 	resolved      sync.Map
 	maxGoroutines int
 }
@@ -82,8 +91,18 @@ func (store *formulary) FetchPlatformFormulae(ctx context.Context, names []strin
 	})
 }
 
+// current produces the current name of a formula requested by an old name.
+func (store *formulary) current(name string) string {
+	if renamed, ok := store.renames[name]; ok && renamed != "" {
+		return renamed
+	}
+	return name
+}
+
 // fetch fetches general metadata.
 func (store *formulary) fetch(ctx context.Context, name string) (formula.MultiPlatformFormula, error) {
+	name = store.current(name)
+
 	source, err := store.registry.Repository(ctx, name)
 	if err != nil {
 		return nil, err
@@ -114,6 +133,8 @@ func (store *formulary) fetch(ctx context.Context, name string) (formula.MultiPl
 
 // fetchPlatform fetches platform metadata.
 func (store *formulary) fetchPlatform(ctx context.Context, name string, plat platform.Platform) (formula.PlatformFormula, error) {
+	name = store.current(name)
+
 	source, err := store.registry.Repository(ctx, name)
 	if err != nil {
 		return nil, err
@@ -182,23 +203,60 @@ func (store *formulary) FetchBottles(ctx context.Context, formulae []formula.Pla
 	})
 }
 
+// DownloadBottle implements bottle.Downloader.
+func (store *formulary) DownloadBottle(ctx context.Context, f formula.PlatformFormula) (bool, error) {
+	cached, btldesc, r, err := store.openBottle(ctx, f)
+	if err != nil {
+		return false, err
+	}
+
+	// Bottles streamed from the source are verified as they are read
+	var vr *content.VerifyReader
+	if cached {
+		vr = content.NewVerifyReader(r, btldesc)
+		r = struct {
+			io.Reader
+			io.Closer
+		}{vr, r}
+	}
+
+	_, err = io.Copy(io.Discard, r)
+	if err = errors.Join(err, r.Close()); err != nil {
+		return false, fmt.Errorf("downloading bottle: %w", err)
+	}
+	if vr != nil {
+		if err := vr.Verify(); err != nil {
+			return false, fmt.Errorf("verifying cached bottle: %w", err)
+		}
+	}
+	return cached, nil
+}
+
 // fetchBottle implements formula.BottleRegistry.
 func (store *formulary) fetchBottle(ctx context.Context, f formula.PlatformFormula) (io.ReadCloser, error) {
+	_, _, r, err := store.openBottle(ctx, f)
+	return r, err
+}
+
+// openBottle opens a bottle from the cache, or streams it from the source while caching it.
+//
+// Returns true if the bottle was already cached.
+func (store *formulary) openBottle(ctx context.Context, f formula.PlatformFormula) (bool, ocispec.Descriptor, io.ReadCloser, error) {
 	name := f.Name()
 
 	source, err := store.registry.Repository(ctx, name)
 	if err != nil {
-		return nil, err
+		return false, ocispec.Descriptor{}, nil, err
 	}
 
 	cache, err := store.cache.Repository(ctx, name)
 	if err != nil {
-		return nil, err
+		return false, ocispec.Descriptor{}, nil, err
 	}
 
 	btl, err := store.resolve(ctx, name)
 	if err != nil {
-		return nil, err
+		return false, ocispec.Descriptor{}, nil, err
 	}
 
 	// TODO: figure out why this was not copying the bottle blob
@@ -209,22 +267,47 @@ func (store *formulary) fetchBottle(ctx context.Context, f formula.PlatformFormu
 
 	btldesc, err := btl.ResolveBottle(ctx, cache, f.Platform())
 	if err != nil {
-		return nil, err
+		return false, ocispec.Descriptor{}, nil, err
+	}
+
+	cached, err := cache.Exists(ctx, btldesc)
+	if err != nil {
+		return false, btldesc, nil, fmt.Errorf("checking cache for bottle: %w", err)
 	}
 
-	// Copy the bottle blob
-	err = orasutil.CopyNode(ctx, source, cache, btldesc)
+	// Fetch the cached bottle blob
+	if cached {
+		r, err := cache.Fetch(ctx, btldesc)
+		if err != nil {
+			return true, btldesc, nil, fmt.Errorf("fetching bottle from cache: %w", err)
+		}
+		return true, btldesc, r, nil
+	}
+
+	// Stream the bottle blob, caching it as it is read
+	r, err := orasutil.TeeFetch(ctx, source, cache, btldesc)
+	if err != nil {
+		return false, btldesc, nil, fmt.Errorf("fetching bottle: %w", err)
+	}
+
+	return false, btldesc, r, nil
+}
+
+// FetchTab implements bottle.TabRegistry.
+func (store *formulary) FetchTab(ctx context.Context, f formula.PlatformFormula) (*tab.Tab, error) {
+	name := f.Name()
+
+	cache, err := store.cache.Repository(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	// Fetch the bottle blob
-	r, err := cache.Fetch(ctx, btldesc)
+	btl, err := store.resolve(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("fetching bottle from cache: %w", err)
+		return nil, err
 	}
 
-	return r, nil
+	return btl.Tab(ctx, cache, f.Platform())
 }
 
 func listAvailableTags(ctx context.Context, repo oras.ReadOnlyGraphTarget, name string) error {
@@ -14,6 +14,7 @@ import (
 
 	hopsspec "github.com/act3-ai/hops/internal/apis/annotations.hops.io"
 	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	tab "github.com/act3-ai/hops/internal/apis/sh.brew.tab"
 	"github.com/act3-ai/hops/internal/platform"
 	"github.com/act3-ai/hops/internal/utils/logutil"
 	"github.com/act3-ai/hops/internal/utils/orasutil"
@@ -78,6 +79,26 @@ func resolvePlatform(ctx context.Context, repo oras.ReadOnlyGraphTarget, bottle
 		return p, nil
 	}
 
+	index, err := fetchIndex(ctx, repo, bottle)
+	if err != nil {
+		return nil, err
+	}
+
+	sel, err := platform.SelectManifest(index, plat)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return selected manifest
+	bottle.platforms[plat] = &bottleManifest{
+		Descriptor: sel,
+		// index:      bottle,
+	}
+	return bottle.platforms[plat], nil
+}
+
+// fetchIndex fetches the content of the bottle index.
+func fetchIndex(ctx context.Context, repo oras.ReadOnlyGraphTarget, bottle *BottleIndex) (*ocispec.Index, error) {
 	if bottle.index == nil {
 		index, err := orasutil.FetchDecode[ocispec.Index](ctx, repo, bottle.Descriptor)
 		if err != nil {
@@ -85,18 +106,32 @@ func resolvePlatform(ctx context.Context, repo oras.ReadOnlyGraphTarget, bottle
 		}
 		bottle.index = index
 	}
+	return bottle.index, nil
+}
 
-	sel, err := platform.SelectManifest(bottle.index, plat)
+// Platforms lists the platforms of the bottle manifests in the index.
+func (btl *BottleIndex) Platforms(ctx context.Context, repo oras.ReadOnlyGraphTarget) ([]platform.Platform, error) {
+	index, err := fetchIndex(ctx, repo, btl)
 	if err != nil {
 		return nil, err
 	}
 
-	// Return selected manifest
-	bottle.platforms[plat] = &bottleManifest{
-		Descriptor: sel,
-		// index:      bottle,
+	plats := make([]platform.Platform, 0, len(index.Manifests))
+	for _, m := range index.Manifests {
+		if m.Platform != nil {
+			plats = append(plats, platform.FromOCI(m.Platform))
+		}
 	}
-	return bottle.platforms[plat], nil
+	return plats, nil
+}
+
+// ResolveManifest resolves the bottle manifest for a platform.
+func (btl *BottleIndex) ResolveManifest(ctx context.Context, repo oras.ReadOnlyGraphTarget, plat platform.Platform) (ocispec.Descriptor, error) {
+	bottleManifest, err := resolvePlatform(ctx, repo, btl, plat)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return bottleManifest.Descriptor, nil
 }
 
 // ResolveBottle resolves the bottle artifact from a platform manifest.
@@ -138,6 +173,32 @@ func resolveBottle(ctx context.Context, repo oras.ReadOnlyGraphTarget, desc *bot
 	return ocispec.Descriptor{}, fmt.Errorf("%s: manifest has no layers with mediaType %s", desc.Descriptor.Digest.Encoded(), hopsspec.MediaTypeBottleArchiveLayer)
 }
 
+// Tab returns the Tab annotated on the bottle manifest for a platform.
+//
+// A return value of nil, nil signifies that the bottle manifest has no Tab.
+func (btl *BottleIndex) Tab(ctx context.Context, repo oras.ReadOnlyGraphTarget, plat platform.Platform) (*tab.Tab, error) {
+	pman, err := resolvePlatform(ctx, repo, btl, plat)
+	if err != nil {
+		return nil, err
+	}
+
+	// Homebrew annotates the index entry for the manifest
+	if _, ok := pman.Annotations[tab.AnnotationTab]; ok {
+		return tab.FromAnnotations(pman.Annotations)
+	}
+
+	// Fall back to the manifest's own annotations
+	if pman.manifest == nil {
+		manifest, err := orasutil.FetchDecode[ocispec.Manifest](ctx, repo, pman.Descriptor)
+		if err != nil {
+			return nil, fmt.Errorf("fetching manifest: %w", err)
+		}
+		pman.manifest = manifest
+	}
+
+	return tab.FromAnnotations(pman.manifest.Annotations)
+}
+
 // GeneralMetadata returns the full metadata for the bottle.
 func (btl *BottleIndex) GeneralMetadata(ctx context.Context, repo oras.ReadOnlyGraphTarget) (*brewv1.Info, error) {
 	mdman, err := resolveFullMetadata(ctx, repo, btl)
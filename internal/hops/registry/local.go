@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 
@@ -11,6 +12,8 @@ import (
 	"oras.land/oras-go/v2/content/oci"
 
 	brewfmt "github.com/act3-ai/hops/internal/brew/fmt"
+	"github.com/act3-ai/hops/internal/cache"
+	"github.com/act3-ai/hops/internal/utils/logutil"
 )
 
 // Local defines a local bottle store.
@@ -39,6 +42,11 @@ func (r *Local) repository(ctx context.Context, name string) (*oci.Store, error)
 		return nil, fmt.Errorf("initializing local storage for %s: %w", name, err)
 	}
 
+	// Record the use for pruning the least recently used repositories
+	if err := cache.Touch(dir); err != nil {
+		slog.Debug("could not mark repository as used", slog.String("repository", dir), logutil.ErrAttr(err))
+	}
+
 	return s, nil
 }
 
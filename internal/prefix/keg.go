@@ -46,3 +46,15 @@ func (k Keg) Paths() ([]string, error) {
 	}
 	return paths, nil
 }
+
+// readKegRecord reads a keg record, a symlink to a keg.
+func readKegRecord(record string) (Keg, error) {
+	target, err := os.Readlink(record)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(record), target)
+	}
+	return Keg(filepath.Clean(target)), nil
+}
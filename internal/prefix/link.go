@@ -20,8 +20,12 @@ func (p Prefix) LinkedFiles(kegs ...string) ([]string, error) {
 	linked := []string{}
 	for _, pdir := range p.MustExistSubdirectories() {
 		err := fs.WalkDir(os.DirFS(pdir), ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil && !errors.Is(err, os.ErrNotExist) {
-				return err // means pdir does not exist
+			switch {
+			// pdir does not exist
+			case errors.Is(err, os.ErrNotExist):
+				return nil
+			case err != nil:
+				return err
 			}
 
 			if d.Type() != fs.ModeSymlink {
@@ -35,9 +39,11 @@ func (p Prefix) LinkedFiles(kegs ...string) ([]string, error) {
 			}
 
 			// Check if link points to one of the given kegs
+			target := filepath.Clean(filepath.Join(pdir, dst))
 			for _, keg := range kegs {
-				if strings.HasPrefix(filepath.Clean(filepath.Join(pdir, dst)), keg) {
+				if target == keg || strings.HasPrefix(target, keg+string(filepath.Separator)) {
 					linked = append(linked, filepath.Join(pdir, path))
+					break
 				}
 			}
 
@@ -50,13 +56,47 @@ func (p Prefix) LinkedFiles(kegs ...string) ([]string, error) {
 	return linked, nil
 }
 
+// Unlink removes all files in the prefix that link into the given kegs.
+//
+// Each symlink is recorded with the Recorder before it is removed so it can be restored.
+func (p Prefix) Unlink(rec symlink.Recorder, kegs ...string) ([]string, error) {
+	links, err := p.LinkedFiles(kegs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range links {
+		if rec != nil {
+			target, err := os.Readlink(l)
+			if err != nil {
+				return nil, fmt.Errorf("reading link %s: %w", l, err)
+			}
+			err = rec.RecordLink(l, "", target)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = os.Remove(l)
+		if err != nil {
+			return nil, fmt.Errorf("removing link %s: %w", l, err)
+		}
+	}
+
+	return links, nil
+}
+
 // BrokenLinks finds all broken links in the prefix.
 func (p Prefix) BrokenLinks() ([]string, error) {
 	broken := []string{}
 	for _, pdir := range p.MustExistSubdirectories() {
 		err := fs.WalkDir(os.DirFS(pdir), ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil && !errors.Is(err, os.ErrNotExist) {
-				return err // means pdir does not exist
+			switch {
+			// pdir does not exist
+			case errors.Is(err, os.ErrNotExist):
+				return nil
+			case err != nil:
+				return err
 			}
 
 			if d.Type() != fs.ModeSymlink {
@@ -107,7 +147,7 @@ func (p Prefix) Link(name, version string, opts *LinkOptions) (links, files int,
 		var err error
 		// Create all "must exist" dirs
 		for _, dir := range p.MustExistDirectories() {
-			err = errors.Join(err, os.MkdirAll(dir, 0o775))
+			err = errors.Join(err, mkdirAll(dir, opts.Recorder))
 		}
 		if err != nil {
 			return links, files, err
@@ -141,7 +181,7 @@ func (p Prefix) Link(name, version string, opts *LinkOptions) (links, files int,
 		links += l
 	}
 
-	err = symlink.Relative(kegPath, filepath.Join(p.LinkedKegRecords(), name), (*symlink.Options)(opts))
+	err = symlink.Relative(kegPath, p.LinkedKegRecord(name), (*symlink.Options)(opts))
 	if err != nil {
 		return links, files, err
 	}
@@ -387,7 +427,7 @@ func linkDir(m mode, src, dst string, opts *symlink.Options) (bool, error) {
 		return false, fs.SkipDir
 	case modeMkpath:
 		// check what "resolve_any_conflicts" does
-		err := os.MkdirAll(dst, 0o775)
+		err := mkdirAll(dst, opts.Recorder)
 		if err != nil {
 			return false, err
 		}
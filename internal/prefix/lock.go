@@ -0,0 +1,225 @@
+package prefix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/act3-ai/hops/internal/errdef"
+)
+
+// LockOptions configures how locks held by other processes are handled.
+type LockOptions struct {
+	// Wait for locks held by other processes instead of failing
+	Wait bool
+
+	// Stop waiting after this long, waits indefinitely if zero
+	Timeout time.Duration
+}
+
+// Lock is a set of held advisory locks.
+type Lock struct {
+	files []*os.File
+	sems  []chan struct{}
+}
+
+// lockPollInterval is the time between attempts to take a held lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// processLocks serializes goroutines of this process on each lock file,
+// since flock does not.
+var processLocks sync.Map // lock file path -> chan struct{}
+
+// FormulaLockFile produces the lock file for the named formula.
+//
+// Lock files are named as Homebrew names them so both respect each other:
+//
+//	var/homebrew/locks/cowsay.formula.lock
+func (p Prefix) FormulaLockFile(name string) string {
+	return filepath.Join(p.Locks(), name+".formula.lock")
+}
+
+// LinkLockFile produces the prefix-wide lock file for linking.
+//
+// Homebrew has no such lock, so it only coordinates hops processes. Homebrew
+// is kept out by the formula locks, which are taken before the link lock.
+func (p Prefix) LinkLockFile() string {
+	return filepath.Join(p.Locks(), "prefix.link.lock")
+}
+
+// LockFormulae locks the named formulae while they are installed, linked,
+// or uninstalled, as Homebrew does.
+func (p Prefix) LockFormulae(ctx context.Context, opts *LockOptions, names ...string) (*Lock, error) {
+	// Lock in a consistent order so processes cannot deadlock
+	names = slices.Sorted(slices.Values(names))
+	names = slices.Compact(names)
+
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		files = append(files, p.FormulaLockFile(name))
+	}
+	return p.lock(ctx, opts, files...)
+}
+
+// LockFile locks a lock file outside of the prefix, such as the cache lock.
+//
+// Shared locks are held by any number of processes at once, while an exclusive
+// lock is held by one process alone. The lock is always waited for.
+func LockFile(ctx context.Context, opts *LockOptions, path string, shared bool) (*Lock, error) {
+	wait := &LockOptions{Wait: true}
+	if opts != nil {
+		wait.Timeout = opts.Timeout
+	}
+	return lockFiles(ctx, wait, filepath.Dir(path), shared, path)
+}
+
+// LockLinks takes the prefix-wide link lock while symlinks are changed.
+//
+// The link lock is only held briefly, so it is always waited for.
+func (p Prefix) LockLinks(ctx context.Context, opts *LockOptions) (*Lock, error) {
+	wait := &LockOptions{Wait: true}
+	if opts != nil {
+		wait.Timeout = opts.Timeout
+	}
+	return p.lock(ctx, wait, p.LinkLockFile())
+}
+
+// lock takes exclusive locks on the lock files in order.
+func (p Prefix) lock(ctx context.Context, opts *LockOptions, paths ...string) (*Lock, error) {
+	return lockFiles(ctx, opts, p.Locks(), false, paths...)
+}
+
+// lockFiles takes locks on the lock files in dir in order.
+func lockFiles(ctx context.Context, opts *LockOptions, dir string, shared bool, paths ...string) (*Lock, error) {
+	if opts == nil {
+		opts = &LockOptions{}
+	}
+	if opts.Wait && opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating locks directory: %w", err)
+	}
+
+	l := &Lock{}
+	for _, path := range paths {
+		err := l.add(ctx, opts.Wait, shared, path)
+		if err != nil {
+			return nil, errors.Join(err, l.Unlock())
+		}
+	}
+	return l, nil
+}
+
+// add takes an exclusive or shared lock on the lock file.
+func (l *Lock) add(ctx context.Context, wait, shared bool, path string) error {
+	// Goroutines of this process share shared locks
+	if !shared {
+		sem, _ := processLocks.LoadOrStore(path, make(chan struct{}, 1))
+		select {
+		case sem.(chan struct{}) <- struct{}{}:
+			l.sems = append(l.sems, sem.(chan struct{}))
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", filepath.Base(path), ctx.Err())
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening lock file: %w", err)
+	}
+
+	waiting := false
+	for {
+		ok, err := tryLock(f, shared)
+		switch {
+		case err != nil:
+			return errors.Join(fmt.Errorf("locking %s: %w", path, err), f.Close())
+		case ok:
+			l.files = append(l.files, f)
+			return writeHolder(f)
+		case !wait:
+			return errors.Join(lockedError(f), f.Close())
+		case !waiting:
+			waiting = true
+			holder := errdef.LockedError{}
+			if errors.As(lockedError(f), &holder) {
+				slog.Warn("Waiting for lock", slog.String("lock", filepath.Base(path)), slog.String("holder", holder.Holder()))
+			}
+		}
+
+		select {
+		case <-time.After(lockPollInterval):
+		case <-ctx.Done():
+			return errors.Join(fmt.Errorf("waiting for lock: %w: %w", ctx.Err(), lockedError(f)), f.Close())
+		}
+	}
+}
+
+// Unlock releases all held locks.
+func (l *Lock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+
+	var errs error
+	for _, f := range slices.Backward(l.files) {
+		// Clear the holder so it is not reported after release
+		errs = errors.Join(errs,
+			f.Truncate(0),
+			unlock(f),
+			f.Close(),
+		)
+	}
+	for _, sem := range slices.Backward(l.sems) {
+		<-sem
+	}
+	l.files, l.sems = nil, nil
+	return errs
+}
+
+// writeHolder records this process as the holder of a lock file.
+func writeHolder(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("writing lock holder: %w", err)
+	}
+	_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+" "+strings.Join(os.Args, " ")+"\n"), 0)
+	if err != nil {
+		return fmt.Errorf("writing lock holder: %w", err)
+	}
+	return nil
+}
+
+// lockedError produces an error naming the holder of a lock file.
+//
+// Homebrew does not record itself as the holder, so the holder may be unknown.
+func lockedError(f *os.File) error {
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		return errdef.NewLockedError(f.Name(), "", 0)
+	}
+
+	pid, cmd, _ := strings.Cut(strings.TrimSpace(string(content)), " ")
+	n, err := strconv.Atoi(pid)
+	if err != nil || cmd == "" {
+		return errdef.NewLockedError(f.Name(), "", 0)
+	}
+	// Show the command as it was typed
+	if args := strings.Fields(cmd); len(args) > 0 {
+		args[0] = filepath.Base(args[0])
+		cmd = strings.Join(args, " ")
+	}
+	return errdef.NewLockedError(f.Name(), cmd, n)
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || linux)
+
+package prefix
+
+import "os"
+
+// tryLock always succeeds, Homebrew prefixes are only supported on macOS and Linux.
+func tryLock(*os.File, bool) (bool, error) {
+	return true, nil
+}
+
+// unlock does nothing.
+func unlock(*os.File) error {
+	return nil
+}
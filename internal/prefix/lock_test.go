@@ -0,0 +1,57 @@
+package prefix
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	opts := &LockOptions{Timeout: 3 * lockPollInterval}
+
+	// Shared locks are held together
+	first, err := LockFile(ctx, opts, path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LockFile(ctx, opts, path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An exclusive lock waits for the shared locks
+	_, err = LockFile(ctx, opts, path, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockFile() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := errors.Join(first.Unlock(), second.Unlock()); err != nil {
+		t.Fatal(err)
+	}
+	exclusive, err := LockFile(ctx, opts, path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A shared lock waits for the exclusive lock
+	done := make(chan error, 1)
+	go func() {
+		l, err := LockFile(ctx, &LockOptions{}, path, true)
+		done <- errors.Join(err, l.Unlock())
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("LockFile() returned while the exclusive lock was held, error = %v", err)
+	case <-time.After(3 * lockPollInterval):
+	}
+	if err := exclusive.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package prefix
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+// PinRecord produces the pin record for the named formula.
+//
+// Pin records are symlinks to the pinned keg, as created by Homebrew:
+//
+//	var/homebrew/pinned/cowsay -> ../../../Cellar/cowsay/3.04_1
+func (p Prefix) PinRecord(name string) string {
+	return filepath.Join(p.PinnedKegRecords(), name)
+}
+
+// Pinned reports whether the named formula is pinned.
+func (p Prefix) Pinned(name string) bool {
+	info, err := os.Lstat(p.PinRecord(name))
+	return err == nil && info.Mode().Type() == fs.ModeSymlink
+}
+
+// PinnedKeg returns the keg the named formula is pinned to.
+// An empty Keg is returned if the formula is not pinned.
+func (p Prefix) PinnedKeg(name string) (Keg, error) {
+	if !p.Pinned(name) {
+		return "", nil
+	}
+	keg, err := readKegRecord(p.PinRecord(name))
+	if err != nil {
+		return "", fmt.Errorf("reading pin record: %w", err)
+	}
+	return keg, nil
+}
+
+// PinnedFormulae lists the names of all pinned formulae.
+func (p Prefix) PinnedFormulae() ([]string, error) {
+	entries, err := os.ReadDir(p.PinnedKegRecords())
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("listing pin records: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Type() == fs.ModeSymlink {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Pin pins the named formula to its newest installed keg.
+func (p Prefix) Pin(name string) error {
+	kegs, err := p.InstalledKegsByName(name)
+	if err != nil {
+		return err
+	}
+	if len(kegs) == 0 {
+		return p.NewErrNoSuchKeg(name)
+	}
+
+	err = symlink.Relative(kegs[len(kegs)-1].String(), p.PinRecord(name), &symlink.Options{
+		MkdirParent: true,
+		Overwrite:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("pinning %s: %w", name, err)
+	}
+	return nil
+}
+
+// Unpin unpins the named formula.
+func (p Prefix) Unpin(name string) error {
+	err := os.Remove(p.PinRecord(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("unpinning %s: %w", name, err)
+	}
+
+	// Remove the pinned directory once it is empty, as Homebrew does
+	entries, err := os.ReadDir(p.PinnedKegRecords())
+	if err == nil && len(entries) == 0 {
+		_ = os.Remove(p.PinnedKegRecords())
+	}
+	return nil
+}
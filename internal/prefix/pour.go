@@ -22,6 +22,9 @@ func (p Prefix) CanPourBottle(ctx context.Context, f formula.PlatformFormula) er
 		slog.Debug("No bottle")
 		return nil
 	}
+	if err := p.CompatibleWithCellar(f); err != nil {
+		return err
+	}
 	switch f.Bottle().PourOnlyIf {
 	// Bottle can always be poured
 	case "":
@@ -75,16 +78,107 @@ func (p Prefix) CanPourBottles(ctx context.Context, formulae []formula.PlatformF
 
 // Pour pours a Bottle into the Cellar.
 func (p Prefix) Pour(btl io.Reader) error {
+	return p.pour(context.Background(), btl, nil, nil)
+}
+
+// pour extracts a Bottle into a staging directory in the Cellar
+// and renames its kegs into place, recording changes to tx.
+// Each staged keg is passed to prepare, if given, before it is moved into place.
+func (p Prefix) pour(ctx context.Context, btl io.Reader, tx *Transaction, prepare func(keg string) error) error {
+	if err := mkdirAll(p.Cellar(), tx); err != nil {
+		return fmt.Errorf("creating Cellar: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(p.Cellar(), stagingDirPrefix)
+	if err != nil {
+		return fmt.Errorf("creating staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := tx.record(journalEntry{Op: journalStage, Path: staging}); err != nil {
+		return err
+	}
+
 	// Untar the bottle
-	if err := untar(btl, p.Cellar()); err != nil {
+	if err := untar(ctx, btl, staging); err != nil {
 		return fmt.Errorf("pouring bottle: %w", err)
 	}
+
+	// Read past the end of the archive, so a bottle that is verified once it
+	// has been read fails before any keg is placed
+	if _, err := io.Copy(io.Discard, btl); err != nil {
+		return fmt.Errorf("reading bottle: %w", err)
+	}
+
+	// Bottles contain kegs at "name/version"
+	kegs, err := filepath.Glob(filepath.Join(staging, "*", "*"))
+	if err != nil {
+		return fmt.Errorf("finding poured kegs: %w", err)
+	}
+
+	for _, staged := range kegs {
+		rel, err := filepath.Rel(staging, staged)
+		if err != nil {
+			return err
+		}
+		if prepare != nil {
+			if err := prepare(staged); err != nil {
+				return err
+			}
+		}
+		if err := p.placeKeg(staged, filepath.Join(p.Cellar(), rel), tx); err != nil {
+			return fmt.Errorf("pouring keg %s: %w", rel, err)
+		}
+	}
+
+	return nil
+}
+
+// placeKeg renames a staged keg into place, moving aside any keg it replaces.
+func (p Prefix) placeKeg(staged, keg string, tx *Transaction) error {
+	if err := mkdirAll(filepath.Dir(keg), tx); err != nil {
+		return fmt.Errorf("creating rack: %w", err)
+	}
+
+	_, err := os.Lstat(keg)
+	switch {
+	// No existing keg
+	case errors.Is(err, os.ErrNotExist):
+		if err := tx.record(journalEntry{Op: journalKeg, Path: keg}); err != nil {
+			return err
+		}
+	// Unknown path error
+	case err != nil:
+		return fmt.Errorf("checking destination: %w", err)
+	// Existing keg cannot be restored, replace it
+	case tx == nil:
+		if err := os.RemoveAll(keg); err != nil {
+			return fmt.Errorf("removing existing keg: %w", err)
+		}
+	// Move the existing keg aside so it can be restored
+	default:
+		backup := tx.backupPath(keg)
+		if err := tx.record(journalEntry{Op: journalKeg, Path: keg, Previous: backup}); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(backup), 0o775); err != nil {
+			return fmt.Errorf("creating backup directory: %w", err)
+		}
+		if err := os.Rename(keg, backup); err != nil {
+			return fmt.Errorf("moving existing keg aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(staged, keg); err != nil {
+		return fmt.Errorf("moving keg into place: %w", err)
+	}
 	return nil
 }
 
 // untar takes a destination path and a reader; a tar reader loops over the tarfile
 // creating the file structure at 'dst' along the way, and writing any files.
-func untar(r io.Reader, dst string) error {
+// Extraction stops when ctx is canceled.
+func untar(ctx context.Context, r io.Reader, dst string) error {
 	gzr, err := gzip.NewReader(r)
 	if err != nil {
 		return err
@@ -94,6 +188,10 @@ func untar(r io.Reader, dst string) error {
 	tr := tar.NewReader(gzr)
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		header, err := tr.Next()
 		switch {
 		// if no more files are found return
@@ -137,7 +235,7 @@ func untar(r io.Reader, dst string) error {
 				return fmt.Errorf("creating directory %s: destination is a file", target)
 			// directory exists
 			default:
-				return nil
+				continue
 			}
 		case tar.TypeReg:
 			// if it's a file create it
@@ -162,7 +260,7 @@ func untar(r io.Reader, dst string) error {
 			switch {
 			// path already exists
 			case err == nil:
-				return nil
+				continue
 			// unknown path error
 			case !errors.Is(err, os.ErrNotExist):
 				return fmt.Errorf("checking destination: %w", err)
@@ -14,9 +14,11 @@ import (
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/sourcegraph/conc/iter"
-	"golang.org/x/mod/semver"
 
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
 	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/formula/version"
 	"github.com/act3-ai/hops/internal/utils"
 	"github.com/act3-ai/hops/internal/utils/logutil"
 	"github.com/act3-ai/hops/internal/utils/symlink"
@@ -82,12 +84,21 @@ func (p Prefix) CompatibleWithCellar(f formula.PlatformFormula) error {
 	}
 	got := p.Cellar()
 
-	switch want {
-	// Empty string means Bottle is relocatable (or cannot be checked for compatibility).
-	case "":
+	switch {
+	// Empty string means Bottle cannot be checked for compatibility.
+	case want == "":
+		return nil
+	// Bottle can be poured into any Cellar as is.
+	case want == common.CellarAnySkipRelocation:
+		return nil
+	// Mach-O files cannot be relocated, only ELF files.
+	case want == common.CellarAny && f.Platform().IsMacOS():
+		return fmt.Errorf("bottle for %s must be relocated, which is not supported for %s", f.Name(), f.Platform())
+	// Bottle can be relocated to any Cellar.
+	case want == common.CellarAny:
 		return nil
 	// Compatible with configured Cellar.
-	case got:
+	case want == got:
 		return nil
 	// Cannot be relocated and is not compatible with configured Cellar.
 	default:
@@ -122,11 +133,21 @@ func (p Prefix) OptRecord(name string) string {
 	return filepath.Join(string(p), "opt", name)
 }
 
+// Repository.
+func (p Prefix) Repository() string {
+	return string(p)
+}
+
 // Library.
 func (p Prefix) Library() string {
 	return filepath.Join(string(p), "Library")
 }
 
+// Taps.
+func (p Prefix) Taps() string {
+	return filepath.Join(p.Library(), "Taps")
+}
+
 // ShimsPath.
 func (p Prefix) ShimsPath() string {
 	return filepath.Join(p.Library(), "Homebrew", "shims")
@@ -142,6 +163,39 @@ func (p Prefix) LinkedKegRecords() string {
 	return filepath.Join(string(p), "var", "homebrew", "linked")
 }
 
+// LinkedKegRecord produces the linked keg record for the named formula.
+func (p Prefix) LinkedKegRecord(name string) string {
+	return filepath.Join(p.LinkedKegRecords(), name)
+}
+
+// LinkedKeg returns the keg the named formula is linked to.
+// An empty Keg is returned if the formula is not linked.
+func (p Prefix) LinkedKeg(name string) (Keg, error) {
+	keg, err := readKegRecord(p.LinkedKegRecord(name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("reading linked keg record: %w", err)
+	default:
+		return keg, nil
+	}
+}
+
+// OptKeg returns the keg the named formula's opt record points to.
+// An empty Keg is returned if the formula has no opt record.
+func (p Prefix) OptKeg(name string) (Keg, error) {
+	keg, err := readKegRecord(p.OptRecord(name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", nil
+	case err != nil:
+		return "", fmt.Errorf("reading opt record: %w", err)
+	default:
+		return keg, nil
+	}
+}
+
 // PinnedKegRecords.
 func (p Prefix) PinnedKegRecords() string {
 	return filepath.Join(string(p), "var", "homebrew", "pinned")
@@ -152,7 +206,11 @@ func (p Prefix) Locks() string {
 	return filepath.Join(string(p), "var", "homebrew", "locks")
 }
 
-// OptLink.
+// OptLink links the opt record of the named formula to the keg of the version.
+//
+// As Homebrew does, the opt records of the aliases listed in the keg's install
+// receipt are also linked, and the opt records of old names that point into
+// the formula's rack are refreshed.
 func (p Prefix) OptLink(name, version string, opts *symlink.Options) error {
 	optRecord := p.OptRecord(name)
 
@@ -163,29 +221,67 @@ func (p Prefix) OptLink(name, version string, opts *symlink.Options) error {
 		return err
 	}
 
-	// TODO
-	// // Refresh all alias links
-	// for _, alias := range info.Aliases {
-	// 	aliasOptRecord := p.OptRecord(alias)
-	// 	// Create new link from alias to the keg
-	// 	err := symlink.Relative(kegPath, aliasOptRecord, opts)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
-	// // Refresh all old name links
-	// for _, oldName := range info.OldNames {
-	// 	oldNameOptRecord := p.OptRecord(oldName)
-	// 	// Create new link from old name to the keg
-	// 	err := symlink.Relative(kegPath, oldNameOptRecord, opts)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
+	records, err := p.optAliasRecords(name, kegPath)
+	if err != nil {
+		return err
+	}
+
+	// Alias and old name records always follow the formula
+	replace := *opts
+	replace.Overwrite = true
+	for _, record := range records {
+		err := symlink.Relative(kegPath, record, &replace)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// optAliasRecords lists the opt records of the aliases and old names of the named formula.
+func (p Prefix) optAliasRecords(name, keg string) ([]string, error) {
+	records := []string{}
+
+	// Aliases are recorded in the install receipt
+	r, err := receipt.Load(keg)
+	if err != nil {
+		slog.Warn("skipping alias opt records", slog.String("keg", keg), logutil.ErrAttr(err))
+	} else if r != nil {
+		for _, alias := range r.Aliases {
+			if alias != name {
+				records = append(records, p.OptRecord(alias))
+			}
+		}
+	}
+
+	// Old names are opt records that point into the rack, as created by migrate
+	entries, err := os.ReadDir(p.Opt())
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return records, nil
+	case err != nil:
+		return nil, fmt.Errorf("listing opt records: %w", err)
+	}
+
+	rack := filepath.Join(p.Cellar(), name)
+	for _, e := range entries {
+		record := p.OptRecord(e.Name())
+		if e.Type() != fs.ModeSymlink || e.Name() == name || slices.Contains(records, record) {
+			continue
+		}
+		target, err := readKegRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("reading opt record: %w", err)
+		}
+		if filepath.Dir(target.String()) == rack {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
 // KegKegLinkDirectories.
 func KegKegLinkDirectories() []string {
 	return []string{
@@ -270,6 +366,8 @@ func (p Prefix) InstalledKegs(f formula.Formula) ([]Keg, error) {
 }
 
 // InstalledPrefixes returns all currently installed prefix directories.
+//
+// The kegs of each formula are sorted from oldest to newest version.
 func (p Prefix) InstalledKegsByName(names ...string) ([]Keg, error) {
 	prefixes := []struct {
 		dir   string
@@ -277,9 +375,12 @@ func (p Prefix) InstalledKegsByName(names ...string) ([]Keg, error) {
 	}{}
 
 	sortedPrefixes := func() []Keg {
-		// Sort by basenames
-		sort.Slice(prefixes, func(i, j int) bool {
-			return prefixes[i].entry.Name() < prefixes[j].entry.Name()
+		// Sort by versions
+		sort.SliceStable(prefixes, func(i, j int) bool {
+			if prefixes[i].dir != prefixes[j].dir {
+				return prefixes[i].dir < prefixes[j].dir
+			}
+			return version.Compare(prefixes[i].entry.Name(), prefixes[j].entry.Name()) < 0
 		})
 
 		// Flatten to list of keg dirs
@@ -315,44 +416,21 @@ func (p Prefix) InstalledKegsByName(names ...string) ([]Keg, error) {
 }
 
 // FormulaOutdated reports whether the formula is outdated.
+// Formulae that are not installed are outdated.
 func (p Prefix) FormulaOutdated(f formula.Formula) (bool, error) {
-	installedPrefixes, err := p.InstalledKegs(f)
-	if err != nil {
-		return true, err
-	}
-
-	latest := formula.PkgVersion(f)
-
-	outdated := true
-
-	// Try to find an up-to-date keg
-	for _, installedPrefix := range installedPrefixes {
-		installedVersion := filepath.Base(string(installedPrefix))
-
-		l := slog.Default().With(slog.String("latest", latest), slog.String("installed", installedVersion))
-
-		// Check if the installed version is newer or up-to-date
-		switch semver.Compare(latest, installedVersion) {
-		case -1:
-			l.Debug("Found keg with greater version than found version")
-			outdated = false
-		case 0:
-			outdated = false
-		default:
-		}
-	}
-
-	return outdated, nil
+	return p.FormulaOutdatedFromName(f.Name(), formula.PkgVersion(f))
 }
 
 // FilterInstalledGeneric categorizes Formulae by install status.
+//
+// Formulae with any installed keg are installed, even if they are outdated.
 func FilterInstalled[T formula.Formula](p Prefix, list []T) (uninstalled []T, installed []T, err error) {
 	for _, entry := range list {
-		missing, err := p.FormulaOutdated(entry)
+		kegs, err := p.InstalledKegs(entry)
 		switch {
 		case err != nil:
 			return nil, nil, err
-		case missing:
+		case len(kegs) == 0:
 			uninstalled = append(uninstalled, entry)
 		default:
 			installed = append(installed, entry)
@@ -361,51 +439,56 @@ func FilterInstalled[T formula.Formula](p Prefix, list []T) (uninstalled []T, in
 	return uninstalled, installed, nil
 }
 
-// FormulaOutdated reports whether the formula is outdated.
+// FormulaOutdatedFromName reports whether the named formula is outdated.
+// Formulae that are not installed are outdated.
 func (p Prefix) FormulaOutdatedFromName(name, latest string) (bool, error) {
-	installedPrefixes, err := p.InstalledKegsByName(name)
+	kegs, err := p.InstalledKegsByName(name)
 	if err != nil {
 		return true, err
 	}
+	return len(kegs) == 0 || len(OutdatedKegs(kegs, latest)) > 0, nil
+}
 
-	outdated := true
-
-	// Try to find an up-to-date keg
-	for _, installedPrefix := range installedPrefixes {
-		installedVersion := filepath.Base(string(installedPrefix))
+// FormulaOutdatedKegs returns the installed kegs of the formula that are outdated.
+func (p Prefix) FormulaOutdatedKegs(f formula.Formula) ([]Keg, error) {
+	kegs, err := p.InstalledKegs(f)
+	if err != nil {
+		return nil, err
+	}
+	return OutdatedKegs(kegs, formula.PkgVersion(f)), nil
+}
 
-		l := slog.Default().With(slog.String("keg", installedVersion), slog.String("latest", latest))
+// OutdatedKegs returns the kegs that are older than the latest version.
+//
+// No kegs are outdated if any keg is at least as new as the latest version.
+func OutdatedKegs(kegs []Keg, latest string) []Keg {
+	outdated := []Keg{}
+	for _, k := range kegs {
+		l := slog.Default().With(slog.String("keg", k.String()), slog.String("latest", latest))
 
 		// Check if the installed version is newer or up-to-date
-		switch semver.Compare(latest, installedVersion) {
+		switch version.Compare(latest, k.Version()) {
 		case -1:
-			l.Debug("found keg with newer version, reverting")
+			l.Debug("found keg with newer version")
+			return []Keg{}
 		case 0:
 			l.Debug("found up to date keg")
-			outdated = false
+			return []Keg{}
 		default:
 			l.Debug("found out of date keg")
+			outdated = append(outdated, k)
 		}
 	}
-
-	return outdated, nil
+	return outdated
 }
 
 // Uninstall removes the keg and any symlinks into the keg.
 func (p Prefix) Uninstall(kegs ...string) error {
-	links, err := p.LinkedFiles(kegs...)
+	_, err := p.Unlink(nil, kegs...)
 	if err != nil {
 		return err
 	}
 
-	for _, l := range links {
-		// Remove the link
-		err = os.Remove(l)
-		if err != nil {
-			return err
-		}
-	}
-
 	for _, keg := range kegs {
 		files, size, err := utils.CountDir(keg)
 		if err != nil {
@@ -0,0 +1,64 @@
+package prefix
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/act3-ai/hops/internal/apis/receipt.brew.sh"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+func TestOptLinkAliasRecords(t *testing.T) {
+	p := testPrefix(t)
+	if err := p.Pour(bytes.NewReader(testBottle(t, "foo", "2.0", "new"))); err != nil {
+		t.Fatal(err)
+	}
+	keg := p.KegPath("foo", "2.0")
+
+	// Aliases are listed in the install receipt
+	err := os.WriteFile(filepath.Join(keg, receipt.InstallReceiptFile), []byte(`{"aliases": ["foo", "foo-alias"]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Old names link into the rack, other records are left alone
+	for record, target := range map[string]string{
+		"foo-old": p.KegPath("foo", "1.0"),
+		"other":   p.KegPath("other", "1.0"),
+	} {
+		if err := symlink.Relative(target, p.OptRecord(record), &symlink.Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := p.optAliasRecords("foo", keg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{p.OptRecord("foo-alias"), p.OptRecord("foo-old")}
+	if !slices.Equal(records, want) {
+		t.Errorf("optAliasRecords() = %v, want %v", records, want)
+	}
+
+	// Alias and old name records follow the formula even without overwriting
+	if err := p.OptLink("foo", "2.0", &symlink.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"foo":       p.KegPath("foo", "1.0"),
+		"foo-alias": keg,
+		"foo-old":   keg,
+		"other":     p.KegPath("other", "1.0"),
+	}
+	for name, want := range tests {
+		got, err := p.OptKeg(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want {
+			t.Errorf("OptKeg(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package prefix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/utils/elfutil"
+)
+
+// Placeholders used by Homebrew to represent install locations in bottles.
+const (
+	placeholderMarker     = "@@HOMEBREW_"
+	prefixPlaceholder     = "@@HOMEBREW_PREFIX@@"
+	cellarPlaceholder     = "@@HOMEBREW_CELLAR@@"
+	repositoryPlaceholder = "@@HOMEBREW_REPOSITORY@@"
+	libraryPlaceholder    = "@@HOMEBREW_LIBRARY@@"
+	perlPlaceholder       = "@@HOMEBREW_PERL@@"
+	javaPlaceholder       = "@@HOMEBREW_JAVA@@"
+)
+
+// binarySniffLen is the number of bytes checked when detecting binary files.
+const binarySniffLen = 8000
+
+// Relocate relocates the formula's keg at the given path to the Prefix.
+//
+// Install location placeholders are replaced in text files. ELF files have
+// their interpreter and library search paths rewritten unless the bottle's
+// Cellar is ":any_skip_relocation". Mach-O files are not relocated, so
+// CompatibleWithCellar rejects macOS bottles that need relocation.
+func (p Prefix) Relocate(f formula.PlatformFormula, keg string) error {
+	btl := f.Bottle()
+	if btl == nil {
+		return nil
+	}
+
+	replacer := p.relocationReplacer(f)
+	skipLinkage := btl.Cellar == common.CellarAnySkipRelocation
+
+	var relocated int
+	err := filepath.WalkDir(keg, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		changed, err := relocateFile(path, replacer, skipLinkage)
+		if err != nil {
+			return fmt.Errorf("relocating %s: %w", path, err)
+		}
+		if changed {
+			relocated++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("relocating keg: %w", err)
+	}
+
+	slog.Debug("Relocated keg", slog.String("keg", keg), slog.Int("files", relocated))
+	return nil
+}
+
+// relocationReplacer creates the replacer for a formula's install location placeholders.
+func (p Prefix) relocationReplacer(f formula.PlatformFormula) *strings.Replacer {
+	perl := "/usr/bin/perl"
+	java := ""
+	if deps := f.Dependencies(); deps != nil {
+		for _, dep := range deps.Required {
+			switch {
+			// Formula uses Homebrew's Perl
+			case dep == "perl":
+				perl = filepath.Join(p.OptRecord(dep), "bin", "perl")
+			// Formula uses Homebrew's OpenJDK
+			case dep == "openjdk" || strings.HasPrefix(dep, "openjdk@"):
+				java = filepath.Join(p.OptRecord(dep), "libexec")
+				if f.Platform().IsMacOS() {
+					java = filepath.Join(java, "openjdk.jdk", "Contents", "Home")
+				}
+			}
+		}
+	}
+
+	pairs := []string{
+		prefixPlaceholder, p.String(),
+		cellarPlaceholder, p.Cellar(),
+		repositoryPlaceholder, p.Repository(),
+		libraryPlaceholder, p.Library(),
+		perlPlaceholder, perl,
+	}
+	if java != "" {
+		pairs = append(pairs, javaPlaceholder, java)
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+// relocateFile relocates a single file, reporting whether it was changed.
+//
+// Only the header is read to detect the kind of file, so binary files
+// without dynamic linkage are never read into memory.
+func relocateFile(path string, replacer *strings.Replacer, skipLinkage bool) (bool, error) {
+	header, binary, err := sniffFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	var relocated []byte
+	switch {
+	// Patch dynamic linkage of ELF files
+	case elfutil.IsELF(header):
+		if skipLinkage {
+			return false, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		patched, changed, err := elfutil.Relocate(data, replacer.Replace)
+		if err != nil || !changed {
+			return false, err
+		}
+		relocated = patched
+	// Binary files cannot have their length changed
+	case binary:
+		found, err := containsPlaceholder(path)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			slog.Warn("Skipping relocation of binary file", slog.String("file", path))
+		}
+		return false, nil
+	// Replace placeholders in text files
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Contains(data, []byte(placeholderMarker)) {
+			return false, nil
+		}
+		relocated = []byte(replacer.Replace(string(data)))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if err := overwriteFile(path, relocated, info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sniffFile reads the header of a file, reporting whether it is a binary file.
+func sniffFile(path string) (header []byte, binary bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	header = make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, err
+	}
+	header = header[:n]
+	return header, bytes.IndexByte(header, 0) >= 0, nil
+}
+
+// containsPlaceholder reports whether a file contains an install location
+// placeholder, reading it in chunks.
+func containsPlaceholder(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	marker := []byte(placeholderMarker)
+	buf := make([]byte, 64<<10)
+	kept := 0 // bytes kept from the previous chunk
+	for {
+		n, err := f.Read(buf[kept:])
+		end := kept + n
+		if bytes.Contains(buf[:end], marker) {
+			return true, nil
+		}
+		switch {
+		case errors.Is(err, io.EOF):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+
+		// Keep the end of the chunk in case the marker spans chunks
+		kept = min(end, len(marker)-1)
+		copy(buf, buf[end-kept:end])
+	}
+}
+
+// overwriteFile overwrites a file, temporarily making it writable if needed.
+func overwriteFile(path string, data []byte, perm fs.FileMode) error {
+	if perm&0o200 == 0 {
+		if err := os.Chmod(path, perm|0o200); err != nil {
+			return err
+		}
+		defer os.Chmod(path, perm) //nolint:errcheck
+	}
+	return os.WriteFile(path, data, perm)
+}
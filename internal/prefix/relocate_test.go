@@ -0,0 +1,150 @@
+package prefix
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+)
+
+// bottledFormula creates a formula with a bottle for the platform poured into the Cellar.
+func bottledFormula(plat platform.Platform, cellar string, deps ...string) formula.PlatformFormula {
+	return formula.PlatformFromV1(plat, &brewv1.PlatformInfo{
+		Name:         "foo",
+		Versions:     brewv1.Versions{Stable: "1.0"},
+		Dependencies: deps,
+		Bottle: map[string]*brewv1.Bottle{
+			brewv1.Stable: {Files: map[platform.Platform]*brewv1.BottleFile{
+				plat: {Cellar: cellar},
+			}},
+		},
+	})
+}
+
+func TestCompatibleWithCellar(t *testing.T) {
+	p := Prefix("/home/linuxbrew/.linuxbrew")
+	tests := []struct {
+		plat    platform.Platform
+		cellar  string
+		wantErr bool
+	}{
+		{platform.X8664Linux, ":any_skip_relocation", false},
+		{platform.X8664Linux, ":any", false},
+		{platform.X8664Linux, "/home/linuxbrew/.linuxbrew/Cellar", false},
+		{platform.X8664Linux, "/opt/other/Cellar", true},
+		{platform.Arm64Sonoma, ":any_skip_relocation", false},
+		{platform.Arm64Sonoma, ":any", true}, // Mach-O files cannot be relocated
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.plat)+" "+tt.cellar, func(t *testing.T) {
+			err := p.CompatibleWithCellar(bottledFormula(tt.plat, tt.cellar))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompatibleWithCellar() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	p := Prefix(t.TempDir())
+	f := bottledFormula(platform.X8664Linux, ":any", "perl")
+	keg := p.FormulaKegPath(f)
+
+	files := map[string]string{
+		"bin/script":   "#!@@HOMEBREW_PERL@@\nexec @@HOMEBREW_PREFIX@@/bin/foo\n",
+		"lib/foo.pc":   "prefix=@@HOMEBREW_CELLAR@@/foo/1.0\n",
+		"share/README": "no placeholders\n",
+		"lib/data.bin": "\x00\x01@@HOMEBREW_PREFIX@@\x00",
+	}
+	for name, content := range files {
+		path := filepath.Join(keg, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o444); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := p.Relocate(f, keg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"bin/script":   "#!" + filepath.Join(p.OptRecord("perl"), "bin", "perl") + "\nexec " + p.String() + "/bin/foo\n",
+		"lib/foo.pc":   "prefix=" + p.Cellar() + "/foo/1.0\n",
+		"share/README": "no placeholders\n",
+		"lib/data.bin": files["lib/data.bin"], // binary files cannot be relocated
+	}
+	for name, content := range want {
+		path := filepath.Join(keg, name)
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+
+		// Read-only files stay read-only
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o444 {
+			t.Errorf("%s has mode %o, want %o", name, perm, 0o444)
+		}
+	}
+}
+
+func TestContainsPlaceholder(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{"none", bytes.Repeat([]byte{0}, 200<<10), false},
+		{"start", []byte(placeholderMarker + "PREFIX@@"), true},
+		// Marker spans the first and second chunk
+		{"boundary", append(bytes.Repeat([]byte{0}, 64<<10-4), placeholderMarker...), true},
+		{"end", append(bytes.Repeat([]byte{0}, 300<<10), placeholderMarker...), true},
+		{"partial", append(bytes.Repeat([]byte{0}, 64<<10-4), placeholderMarker[:len(placeholderMarker)-1]...), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := containsPlaceholder(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("containsPlaceholder() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "text")
+	if err := os.WriteFile(text, []byte(strings.Repeat("a", binarySniffLen*2)+"\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// NUL bytes past the header do not make a file binary
+	header, binary, err := sniffFile(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary || len(header) != binarySniffLen {
+		t.Errorf("sniffFile() = %d bytes, binary %t, want %d bytes of text", len(header), binary, binarySniffLen)
+	}
+}
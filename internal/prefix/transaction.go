@@ -0,0 +1,515 @@
+package prefix
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/act3-ai/hops/internal/utils/logutil"
+	"github.com/act3-ai/hops/internal/utils/symlink"
+)
+
+// Transaction records changes made to the Prefix so they can be undone.
+//
+// Kegs, symlinks, and directories are written to a journal before they are
+// created, symlinks before they are removed, and kegs and files before they
+// are moved or rewritten. Rollback undoes the recorded changes in reverse order. Commit records that the changes are final before
+// removing the replaced kegs and the journal. Journals left behind by a run
+// that did not finish are rolled back by Recover, unless they were committed.
+type Transaction struct {
+	prefix  Prefix
+	id      string
+	path    string // path to the journal file
+	mu      sync.Mutex
+	journal *os.File
+	entries []journalEntry
+}
+
+// journalOp identifies a change recorded in a journal.
+type journalOp string
+
+const (
+	journalBegin  journalOp = "begin"  // transaction started by process PID
+	journalDir    journalOp = "dir"    // directory created at Path
+	journalStage  journalOp = "stage"  // staging directory created at Path
+	journalKeg    journalOp = "keg"    // keg moved to Path, the replaced or removed keg moved to Previous
+	journalMove   journalOp = "move"   // keg moved to Path from Previous
+	journalFile   journalOp = "file"   // file at Path moved to Previous before it is rewritten or replaced
+	journalLink   journalOp = "link"   // symlink to Target created at Path, replacing a symlink to Previous; no Target means removed
+	journalCommit journalOp = "commit" // changes are final, replaced kegs can be removed
+)
+
+// journalEntry is a line in a journal.
+type journalEntry struct {
+	Op       journalOp `json:"op"`
+	Path     string    `json:"path,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Previous string    `json:"previous,omitempty"`
+	PID      int       `json:"pid,omitempty"`
+}
+
+// Prefixes of hidden directories created in the Cellar.
+const (
+	stagingDirPrefix = ".hops-staging-"
+	backupDirPrefix  = ".hops-backup-"
+)
+
+// Transactions.
+func (p Prefix) Transactions() string {
+	return filepath.Join(string(p), "var", "hops", "transactions")
+}
+
+// Begin starts a Transaction.
+func (p Prefix) Begin() (*Transaction, error) {
+	err := os.MkdirAll(p.Transactions(), 0o775)
+	if err != nil {
+		return nil, fmt.Errorf("creating transactions directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(p.Transactions(), "*.journal")
+	if err != nil {
+		return nil, fmt.Errorf("creating journal: %w", err)
+	}
+
+	tx := &Transaction{
+		prefix:  p,
+		id:      strings.TrimSuffix(filepath.Base(f.Name()), ".journal"),
+		path:    f.Name(),
+		journal: f,
+	}
+
+	err = tx.record(journalEntry{Op: journalBegin, PID: os.Getpid()})
+	if err != nil {
+		return nil, errors.Join(err, f.Close(), os.Remove(f.Name()))
+	}
+
+	return tx, nil
+}
+
+// Recover rolls back Transactions left behind by runs that did not finish.
+//
+// Transactions that were committed are completed instead.
+func (p Prefix) Recover() error {
+	journals, err := filepath.Glob(filepath.Join(p.Transactions(), "*.journal"))
+	if err != nil {
+		return fmt.Errorf("finding journals: %w", err)
+	}
+
+	var errs error
+	for _, path := range journals {
+		tx, err := p.loadTransaction(path)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+
+		// Leave transactions of running processes alone
+		if tx.running() {
+			slog.Debug("Skipping journal of a running process", slog.String("journal", path))
+			continue
+		}
+
+		if tx.committed() {
+			slog.Debug("Completing committed transaction", slog.String("journal", path))
+			errs = errors.Join(errs, tx.finish())
+			continue
+		}
+
+		slog.Warn("Rolling back interrupted transaction", slog.String("journal", path))
+		errs = errors.Join(errs, tx.Rollback())
+	}
+
+	return errs
+}
+
+// loadTransaction loads a Transaction from its journal.
+func (p Prefix) loadTransaction(path string) (*Transaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+
+	tx := &Transaction{
+		prefix: p,
+		id:     strings.TrimSuffix(filepath.Base(path), ".journal"),
+		path:   path,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A crash can leave the last line partially written
+			slog.Debug("Skipping unreadable journal entry", slog.String("journal", path), logutil.ErrAttr(err))
+			continue
+		}
+		tx.entries = append(tx.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading journal %s: %w", path, err)
+	}
+
+	return tx, nil
+}
+
+// running reports whether the process that started the Transaction is still running.
+func (tx *Transaction) running() bool {
+	for _, e := range tx.entries {
+		if e.Op != journalBegin {
+			continue
+		}
+		if e.PID == os.Getpid() {
+			return true
+		}
+		proc, err := os.FindProcess(e.PID)
+		if err != nil {
+			return false
+		}
+		err = proc.Signal(syscall.Signal(0))
+		return err == nil || errors.Is(err, syscall.EPERM)
+	}
+	return false
+}
+
+// committed reports whether the Transaction was committed.
+func (tx *Transaction) committed() bool {
+	return slices.ContainsFunc(tx.entries, func(e journalEntry) bool { return e.Op == journalCommit })
+}
+
+// Pour pours a Bottle into the Cellar as part of the Transaction.
+// The Bottle is read to the end before any keg is moved into place.
+//
+// If prepare is not nil, it is called with the path of each keg while the keg
+// is staged, before the keg is moved into place.
+func (tx *Transaction) Pour(ctx context.Context, btl io.Reader, prepare func(keg string) error) error {
+	return tx.prefix.pour(ctx, btl, tx, prepare)
+}
+
+// Move moves a keg to another path as part of the Transaction,
+// creating the rack it is moved to.
+func (tx *Transaction) Move(keg, path string) error {
+	if err := mkdirAll(filepath.Dir(path), tx); err != nil {
+		return fmt.Errorf("creating rack: %w", err)
+	}
+	if err := tx.record(journalEntry{Op: journalMove, Path: path, Previous: keg}); err != nil {
+		return err
+	}
+	if err := os.Rename(keg, path); err != nil {
+		return fmt.Errorf("moving keg: %w", err)
+	}
+	return nil
+}
+
+// RemoveKeg moves a keg into the backup directory of the Transaction, so it is
+// removed when the Transaction is committed and restored if it is rolled back.
+func (tx *Transaction) RemoveKeg(keg string) error {
+	backup := tx.backupPath(keg)
+	if err := tx.record(journalEntry{Op: journalKeg, Path: keg, Previous: backup}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0o775); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	if err := os.Rename(keg, backup); err != nil {
+		return fmt.Errorf("moving keg aside: %w", err)
+	}
+	return nil
+}
+
+// Backup moves the file at path into the backup directory of the Transaction,
+// so it is restored if the Transaction is rolled back. It is called before the
+// file is rewritten or replaced.
+//
+// Backup implements symlink.Recorder.
+func (tx *Transaction) Backup(path string) error {
+	dir := filepath.Join(tx.prefix.Cellar(), backupDirPrefix+tx.id)
+	if err := os.MkdirAll(dir, 0o775); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(dir, "file-")
+	if err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	backup := filepath.Join(dir, filepath.Base(path))
+
+	if err := tx.record(journalEntry{Op: journalFile, Path: path, Previous: backup}); err != nil {
+		return err
+	}
+	if err := os.Rename(path, backup); err != nil {
+		return fmt.Errorf("backing up file: %w", err)
+	}
+	return nil
+}
+
+// RemoveLink removes the symlink at path as part of the Transaction.
+func (tx *Transaction) RemoveLink(path string) error {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return fmt.Errorf("reading link %s: %w", path, err)
+	}
+	if err := tx.RecordLink(path, "", target); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing link %s: %w", path, err)
+	}
+	return nil
+}
+
+// RecordLink implements symlink.Recorder.
+func (tx *Transaction) RecordLink(newname, target, previous string) error {
+	return tx.record(journalEntry{Op: journalLink, Path: newname, Target: target, Previous: previous})
+}
+
+// RecordDir implements symlink.Recorder.
+func (tx *Transaction) RecordDir(path string) error {
+	return tx.record(journalEntry{Op: journalDir, Path: path})
+}
+
+// record appends an entry to the journal.
+//
+// Recording to a nil Transaction does nothing.
+func (tx *Transaction) record(e journalEntry) error {
+	if tx == nil {
+		return nil
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.write(e)
+}
+
+// write appends an entry to the journal while the lock is held.
+func (tx *Transaction) write(e journalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+
+	_, err = tx.journal.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+
+	tx.entries = append(tx.entries, e)
+	return nil
+}
+
+// backupPath produces the path a replaced keg is moved to.
+func (tx *Transaction) backupPath(keg string) string {
+	return filepath.Join(tx.prefix.Cellar(), backupDirPrefix+tx.id, Keg(keg).Name(), Keg(keg).Version())
+}
+
+// Commit completes the Transaction, removing replaced kegs and the journal.
+//
+// The commit is recorded in the journal before anything is removed,
+// so Recover completes a commit that was interrupted instead of rolling it back.
+func (tx *Transaction) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if err := tx.write(journalEntry{Op: journalCommit}); err != nil {
+		return err
+	}
+	if err := tx.journal.Sync(); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+
+	return tx.finish()
+}
+
+// finish removes the replaced kegs and leftover staging directories of a
+// committed Transaction, then the journal.
+//
+// The journal is kept if anything could not be removed, so Recover can retry.
+func (tx *Transaction) finish() error {
+	var errs error
+	for _, e := range tx.entries {
+		switch e.Op {
+		// Remove the replaced keg
+		case journalKeg:
+			if e.Previous != "" {
+				errs = errors.Join(errs, os.RemoveAll(e.Previous))
+			}
+		// Remove leftover staging directories
+		case journalStage:
+			errs = errors.Join(errs, os.RemoveAll(e.Path))
+		}
+	}
+
+	if errs != nil {
+		if tx.journal != nil {
+			errs = errors.Join(errs, tx.journal.Close())
+		}
+		return fmt.Errorf("committing transaction: %w", errs)
+	}
+
+	return tx.close()
+}
+
+// Rollback undoes all changes recorded by the Transaction.
+//
+// The journal is kept if any change could not be undone,
+// so the rollback can be retried by Recover.
+func (tx *Transaction) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	var errs error
+	for _, e := range slices.Backward(tx.entries) {
+		errs = errors.Join(errs, undo(e))
+	}
+
+	if errs != nil {
+		if tx.journal != nil {
+			errs = errors.Join(errs, tx.journal.Close())
+		}
+		return fmt.Errorf("rolling back transaction: %w", errs)
+	}
+
+	return tx.close()
+}
+
+// close closes and removes the journal and the backup directory.
+func (tx *Transaction) close() error {
+	var errs error
+	if tx.journal != nil {
+		errs = tx.journal.Close()
+	}
+	return errors.Join(errs,
+		os.RemoveAll(filepath.Join(tx.prefix.Cellar(), backupDirPrefix+tx.id)),
+		os.Remove(tx.path),
+	)
+}
+
+// undo undoes a single journal entry.
+func undo(e journalEntry) error {
+	switch e.Op {
+	case journalLink:
+		// Only remove the symlink if it is still the one that was created
+		if target, err := os.Readlink(e.Path); err == nil && target == e.Target {
+			if err := os.Remove(e.Path); err != nil {
+				return fmt.Errorf("removing symlink: %w", err)
+			}
+		}
+		// Restore the replaced symlink
+		if e.Previous != "" {
+			if _, err := os.Lstat(e.Path); errors.Is(err, fs.ErrNotExist) {
+				if err := os.Symlink(e.Previous, e.Path); err != nil {
+					return fmt.Errorf("restoring symlink: %w", err)
+				}
+			}
+		}
+		return nil
+	case journalDir:
+		removeEmptyDirs(e.Path)
+		return nil
+	case journalKeg:
+		switch _, err := os.Lstat(e.Previous); {
+		// No keg was replaced
+		case e.Previous == "":
+			return os.RemoveAll(e.Path)
+		// The replaced keg was never moved aside
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return fmt.Errorf("checking replaced keg: %w", err)
+		// Move the replaced keg back into place
+		default:
+			if err := os.RemoveAll(e.Path); err != nil {
+				return fmt.Errorf("removing keg: %w", err)
+			}
+			if err := os.Rename(e.Previous, e.Path); err != nil {
+				return fmt.Errorf("restoring keg: %w", err)
+			}
+			return nil
+		}
+	case journalMove:
+		switch _, err := os.Lstat(e.Path); {
+		// The keg was never moved
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return fmt.Errorf("checking moved keg: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(e.Previous), 0o775); err != nil {
+			return fmt.Errorf("restoring rack: %w", err)
+		}
+		if err := os.Rename(e.Path, e.Previous); err != nil {
+			return fmt.Errorf("restoring keg: %w", err)
+		}
+		return nil
+	case journalFile:
+		// The file was never moved aside
+		if _, err := os.Lstat(e.Previous); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err := os.Rename(e.Previous, e.Path); err != nil {
+			return fmt.Errorf("restoring file: %w", err)
+		}
+		return nil
+	case journalStage:
+		return os.RemoveAll(e.Path)
+	default:
+		return nil
+	}
+}
+
+// removeEmptyDirs removes the directory tree at root, leaving any directories that are not empty.
+func removeEmptyDirs(root string) {
+	dirs := []string{}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Remove children before parents
+	for _, dir := range slices.Backward(dirs) {
+		_ = os.Remove(dir)
+	}
+}
+
+// mkdirAll creates a directory and any missing parents,
+// recording the topmost directory created.
+func mkdirAll(path string, rec symlink.Recorder) error {
+	top := ""
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		_, err := os.Lstat(dir)
+		if err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		top = dir
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	// Directory already exists
+	if top == "" {
+		return nil
+	}
+
+	if rec != nil {
+		if err := rec.RecordDir(top); err != nil {
+			return err
+		}
+	}
+
+	return os.MkdirAll(path, 0o775)
+}
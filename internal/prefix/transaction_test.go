@@ -0,0 +1,382 @@
+package prefix
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testBottle creates a gzipped bottle archive with a single executable in the keg.
+func testBottle(t *testing.T, name, version, content string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, hdr := range []*tar.Header{
+		{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/" + version + "/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/" + version + "/bin/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: name + "/" + version + "/bin/" + name, Typeflag: tar.TypeReg, Mode: 0o755, Size: int64(len(content))},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := errors.Join(tw.Close(), gzw.Close()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testPrefix creates a Prefix with "foo" 1.0 poured and linked.
+func testPrefix(t *testing.T) Prefix {
+	t.Helper()
+	p := Prefix(t.TempDir())
+	if err := p.Pour(bytes.NewReader(testBottle(t, "foo", "1.0", "old"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Link("foo", "1.0", &LinkOptions{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// crash simulates the process running tx exiting without finishing it.
+func crash(t *testing.T, tx *Transaction) {
+	t.Helper()
+	if err := tx.journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(tx.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PIDs never exceed 2^22
+	data = bytes.Replace(data, []byte(`"pid":`+strconv.Itoa(os.Getpid())), []byte(`"pid":99999999`), 1)
+	if err := os.WriteFile(tx.path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pourAndLink replaces "foo" 1.0 and installs "bar" 1.0 as part of tx.
+func pourAndLink(t *testing.T, p Prefix, tx *Transaction) {
+	t.Helper()
+	ctx := context.Background()
+	if err := tx.Pour(ctx, bytes.NewReader(testBottle(t, "foo", "1.0", "new")), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Pour(ctx, bytes.NewReader(testBottle(t, "bar", "1.0", "bar")), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Link("bar", "1.0", &LinkOptions{Name: "bar", Recorder: tx}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertContent checks the content of the executable in a keg.
+func assertContent(t *testing.T, p Prefix, name, want string) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(p.KegPath(name, "1.0"), "bin", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("%s content = %q, want %q", name, got, want)
+	}
+}
+
+// assertNotExist checks that none of the paths exist.
+func assertNotExist(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists, err = %v", path, err)
+		}
+	}
+}
+
+// assertFinished checks that no journals, backups, or staging directories are left.
+func assertFinished(t *testing.T, p Prefix) {
+	t.Helper()
+	journals, _ := filepath.Glob(filepath.Join(p.Transactions(), "*.journal"))
+	hidden, _ := filepath.Glob(filepath.Join(p.Cellar(), ".hops-*"))
+	if len(journals) > 0 || len(hidden) > 0 {
+		t.Errorf("leftover journals %v, directories %v", journals, hidden)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	p := testPrefix(t)
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pourAndLink(t, p, tx)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, p, "foo", "new")
+	assertContent(t, p, "bar", "bar")
+	if _, err := os.Stat(filepath.Join(p.String(), "bin", "bar")); err != nil {
+		t.Errorf("bar is not linked: %v", err)
+	}
+	assertFinished(t, p)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	p := testPrefix(t)
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pourAndLink(t, p, tx)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, p, "foo", "old")
+	if _, err := os.Stat(filepath.Join(p.String(), "bin", "foo")); err != nil {
+		t.Errorf("foo is no longer linked: %v", err)
+	}
+	assertNotExist(t, filepath.Join(p.Cellar(), "bar"), filepath.Join(p.String(), "bin", "bar"), p.OptRecord("bar"))
+	assertFinished(t, p)
+}
+
+func TestRecover(t *testing.T) {
+	t.Run("interrupted", func(t *testing.T) {
+		p := testPrefix(t)
+		tx, err := p.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pourAndLink(t, p, tx)
+		crash(t, tx)
+
+		if err := p.Recover(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertContent(t, p, "foo", "old")
+		assertNotExist(t, filepath.Join(p.Cellar(), "bar"), filepath.Join(p.String(), "bin", "bar"), p.OptRecord("bar"))
+		assertFinished(t, p)
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		p := testPrefix(t)
+		tx, err := p.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pourAndLink(t, p, tx)
+		// Interrupted after the commit was recorded
+		if err := tx.record(journalEntry{Op: journalCommit}); err != nil {
+			t.Fatal(err)
+		}
+		crash(t, tx)
+
+		if err := p.Recover(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertContent(t, p, "foo", "new")
+		assertContent(t, p, "bar", "bar")
+		if _, err := os.Stat(filepath.Join(p.String(), "bin", "bar")); err != nil {
+			t.Errorf("bar is not linked: %v", err)
+		}
+		assertFinished(t, p)
+	})
+
+	t.Run("running", func(t *testing.T) {
+		p := testPrefix(t)
+		tx, err := p.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pourAndLink(t, p, tx)
+
+		if err := p.Recover(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The transaction of this process is left alone
+		assertContent(t, p, "bar", "bar")
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		assertFinished(t, p)
+	})
+}
+
+func TestTransactionPourPrepare(t *testing.T) {
+	p := testPrefix(t)
+	tx, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errPrepare := errors.New("prepare failed")
+	err = tx.Pour(context.Background(), bytes.NewReader(testBottle(t, "foo", "1.0", "new")), func(keg string) error {
+		if keg == p.KegPath("foo", "1.0") {
+			t.Errorf("prepare called with the installed keg %s", keg)
+		}
+		return errPrepare
+	})
+	if !errors.Is(err, errPrepare) {
+		t.Fatalf("Pour() error = %v, want %v", err, errPrepare)
+	}
+
+	// The installed keg is only replaced once it is prepared
+	assertContent(t, p, "foo", "old")
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	assertFinished(t, p)
+}
+
+func TestTransactionMove(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		name := "rollback"
+		if commit {
+			name = "commit"
+		}
+		t.Run(name, func(t *testing.T) {
+			p := testPrefix(t)
+			oldKeg, newKeg := p.KegPath("foo", "1.0"), p.KegPath("bar", "1.0")
+			tx, err := p.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tx.Move(oldKeg, newKeg); err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(newKeg, "bin", "foo")
+			if err := tx.Backup(file); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte("new"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(p.Cellar(), "foo")); err != nil {
+				t.Fatal(err)
+			}
+
+			if commit {
+				if err := tx.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				if got, err := os.ReadFile(file); err != nil || string(got) != "new" {
+					t.Errorf("moved file = %q, %v, want %q", got, err, "new")
+				}
+				assertNotExist(t, filepath.Join(p.Cellar(), "foo"))
+			} else {
+				if err := tx.Rollback(); err != nil {
+					t.Fatal(err)
+				}
+				assertContent(t, p, "foo", "old")
+				assertNotExist(t, filepath.Join(p.Cellar(), "bar"))
+			}
+			assertFinished(t, p)
+		})
+	}
+}
+
+func TestTransactionRemoveKeg(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		name := "rollback"
+		if commit {
+			name = "commit"
+		}
+		t.Run(name, func(t *testing.T) {
+			p := testPrefix(t)
+			keg := p.KegPath("foo", "1.0")
+			tx, err := p.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tx.RemoveKeg(keg); err != nil {
+				t.Fatal(err)
+			}
+			assertNotExist(t, keg)
+
+			if commit {
+				if err := tx.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				assertNotExist(t, keg)
+			} else {
+				if err := tx.Rollback(); err != nil {
+					t.Fatal(err)
+				}
+				assertContent(t, p, "foo", "old")
+			}
+			if backups, _ := filepath.Glob(filepath.Join(p.Cellar(), backupDirPrefix+"*")); len(backups) > 0 {
+				t.Errorf("leftover backups %v", backups)
+			}
+			assertFinished(t, p)
+		})
+	}
+}
+
+func TestTransactionOverwrite(t *testing.T) {
+	for _, commit := range []bool{true, false} {
+		name := "rollback"
+		if commit {
+			name = "commit"
+		}
+		t.Run(name, func(t *testing.T) {
+			p := testPrefix(t)
+			// A file of the user is in the way of the link
+			file := filepath.Join(p.String(), "bin", "bar")
+			if err := os.WriteFile(file, []byte("user"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := p.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Pour(context.Background(), bytes.NewReader(testBottle(t, "bar", "1.0", "bar")), nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := p.Link("bar", "1.0", &LinkOptions{Name: "bar", Overwrite: true, Recorder: tx}); err != nil {
+				t.Fatal(err)
+			}
+
+			if commit {
+				if err := tx.Commit(); err != nil {
+					t.Fatal(err)
+				}
+				if target, err := os.Readlink(file); err != nil {
+					t.Errorf("bar is not linked: %v", err)
+				} else if got := filepath.Join(filepath.Dir(file), target); got != filepath.Join(p.KegPath("bar", "1.0"), "bin", "bar") {
+					t.Errorf("bar links to %s", got)
+				}
+			} else {
+				if err := tx.Rollback(); err != nil {
+					t.Fatal(err)
+				}
+				info, err := os.Lstat(file)
+				if err != nil || !info.Mode().IsRegular() {
+					t.Fatalf("overwritten file was not restored: %v", err)
+				}
+				if got, _ := os.ReadFile(file); string(got) != "user" {
+					t.Errorf("restored file = %q, want %q", got, "user")
+				}
+			}
+			assertFinished(t, p)
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package pretty
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/act3-ai/hops/internal/formula"
 	"github.com/act3-ai/hops/internal/o"
 	"github.com/act3-ai/hops/internal/prefix"
+	"github.com/act3-ai/hops/internal/services"
 )
 
 // Caveats prints formula caveats.
@@ -29,22 +30,20 @@ func Caveats(f formula.PlatformFormula, p prefix.Prefix) string {
 		lines = append(lines, komsg)
 	}
 
-	if service := f.Service(); service != nil {
-		args, err := service.RunArgs("macos")
-		if err != nil {
-			o.Die("could not parse run args: " + err.Error())
-		} else if len(args) > 0 {
+	if f.Service() != nil {
+		svc, err := services.New(f, p, services.SystemOS())
+		switch {
+		// The service does not run on this system
+		case errors.Is(err, services.ErrNoService):
+		case err != nil:
+			o.Poo("could not parse service: " + err.Error())
+		default:
 			lines = append(lines,
 				"",
 				fmt.Sprintf("To start %s now and restart at login:", f.Name()),
-				"  brew services start "+f.Name(),
-			)
-			for i, arg := range args {
-				args[i] = os.ExpandEnv(arg)
-			}
-			lines = append(lines,
+				"  hops services start "+f.Name(),
 				"Or, if you don't want/need a background service you can just run:",
-				"  "+strings.Join(args, " "),
+				"  "+strings.Join(svc.Args, " "),
 			)
 		}
 	}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+)
+
+// Launchd controls services as launchd agents.
+type Launchd struct {
+	Dir string // directory of launch agents
+	run runner
+}
+
+// NewLaunchd creates a Launchd manager for the user's agents.
+func NewLaunchd() *Launchd {
+	return &Launchd{
+		Dir: filepath.Join(xdg.Home, "Library", "LaunchAgents"),
+		run: execRunner,
+	}
+}
+
+// Label produces the launchd label of the service.
+//
+//	homebrew.mxcl.postgresql@16
+func Label(s *Service) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "homebrew.mxcl." + s.Formula
+}
+
+// plistPath produces the path of the service's property list.
+func (m *Launchd) plistPath(s *Service) string {
+	return filepath.Join(m.Dir, Label(s)+".plist")
+}
+
+// Files implements Manager.
+func (m *Launchd) Files(s *Service) (map[string]string, error) {
+	plist, err := LaunchdPlist(s)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{m.plistPath(s): plist}, nil
+}
+
+// domain produces the launchd domain of the user's agents.
+func domain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+// Start implements Manager.
+func (m *Launchd) Start(ctx context.Context, s *Service) error {
+	files, err := m.Files(s)
+	if err != nil {
+		return err
+	}
+	if err := writeFiles(files); err != nil {
+		return err
+	}
+
+	if _, err := m.run(ctx, "launchctl", "enable", domain()+"/"+Label(s)); err != nil {
+		return err
+	}
+	// Bootstrapping fails if the agent is already loaded
+	if _, err := m.run(ctx, "launchctl", "print", domain()+"/"+Label(s)); err == nil {
+		return nil
+	}
+	_, err = m.run(ctx, "launchctl", "bootstrap", domain(), m.plistPath(s))
+	return err
+}
+
+// Restart implements Manager.
+func (m *Launchd) Restart(ctx context.Context, s *Service) error {
+	if err := m.Start(ctx, s); err != nil {
+		return err
+	}
+	_, err := m.run(ctx, "launchctl", "kickstart", "-k", domain()+"/"+Label(s))
+	return err
+}
+
+// Stop implements Manager.
+func (m *Launchd) Stop(ctx context.Context, s *Service) error {
+	if _, err := m.run(ctx, "launchctl", "print", domain()+"/"+Label(s)); err == nil {
+		if _, err := m.run(ctx, "launchctl", "bootout", domain()+"/"+Label(s)); err != nil {
+			return err
+		}
+	}
+	return removeFiles(map[string]string{m.plistPath(s): ""})
+}
+
+// Status implements Manager.
+func (m *Launchd) Status(ctx context.Context, s *Service) (Status, error) {
+	_, err := os.Stat(m.plistPath(s))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return StatusNone, nil
+	case err != nil:
+		return "", fmt.Errorf("checking service: %w", err)
+	}
+
+	out, err := m.run(ctx, "launchctl", "print", domain()+"/"+Label(s))
+	switch {
+	// The agent is not loaded
+	case err != nil:
+		return StatusStopped, nil
+	case strings.Contains(string(out), "state = running"):
+		return StatusStarted, nil
+	case s.Scheduled():
+		return StatusScheduled, nil
+	case strings.Contains(string(out), "last exit code = 0"):
+		return StatusStopped, nil
+	case strings.Contains(string(out), "last exit code"):
+		return StatusError, nil
+	default:
+		return StatusStopped, nil
+	}
+}
+
+// LaunchdPlist renders the service as a launchd property list.
+func LaunchdPlist(s *Service) (string, error) {
+	p := &plistWriter{}
+	p.line(`<?xml version="1.0" encoding="UTF-8"?>`)
+	p.line(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">`)
+	p.line(`<plist version="1.0">`)
+	p.line("<dict>")
+	p.depth++
+
+	p.key("Label")
+	p.string(Label(s))
+
+	p.key("ProgramArguments")
+	p.line("<array>")
+	p.depth++
+	for _, arg := range s.Args {
+		p.string(arg)
+	}
+	p.depth--
+	p.line("</array>")
+
+	if s.RunType == common.RunTypeImmediate {
+		p.key("RunAtLoad")
+		p.bool(true)
+	}
+
+	switch {
+	case s.KeepAlive.Always:
+		p.key("KeepAlive")
+		p.bool(true)
+	case s.KeepAlive.SuccessfulExit || s.KeepAlive.Crashed:
+		p.key("KeepAlive")
+		p.line("<dict>")
+		p.depth++
+		if s.KeepAlive.SuccessfulExit {
+			// Restart after successful exits
+			p.key("SuccessfulExit")
+			p.bool(false)
+		}
+		if s.KeepAlive.Crashed {
+			p.key("Crashed")
+			p.bool(true)
+		}
+		p.depth--
+		p.line("</dict>")
+	}
+
+	switch s.RunType {
+	case common.RunTypeInterval:
+		if s.Interval <= 0 {
+			return "", fmt.Errorf("%s: interval service has no interval", s.Formula)
+		}
+		p.key("StartInterval")
+		p.line("<integer>" + strconv.Itoa(s.Interval) + "</integer>")
+	case common.RunTypeCron:
+		err := p.calendarInterval(s.Cron)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", s.Formula, err)
+		}
+	}
+
+	if len(s.Environment) > 0 {
+		p.key("EnvironmentVariables")
+		p.line("<dict>")
+		p.depth++
+		for _, k := range s.sortedEnvironment() {
+			p.key(k)
+			p.string(s.Environment[k])
+		}
+		p.depth--
+		p.line("</dict>")
+	}
+
+	for _, path := range []struct{ key, value string }{
+		{"WorkingDirectory", s.WorkingDir},
+		{"StandardInPath", s.InputPath},
+		{"StandardOutPath", s.LogPath},
+		{"StandardErrorPath", s.ErrorLogPath},
+	} {
+		if path.value != "" {
+			p.key(path.key)
+			p.string(path.value)
+		}
+	}
+
+	switch s.ProcessType {
+	case common.ProcessTypeInteractive:
+		p.key("ProcessType")
+		p.string("Interactive")
+	case common.ProcessTypeBackground:
+		p.key("ProcessType")
+		p.string("Background")
+	}
+
+	p.depth--
+	p.line("</dict>")
+	p.line("</plist>")
+	return p.String(), nil
+}
+
+// plistWriter writes an indented property list.
+type plistWriter struct {
+	strings.Builder
+	depth int
+}
+
+// line writes an indented line.
+func (p *plistWriter) line(s string) {
+	p.WriteString(strings.Repeat("\t", p.depth) + s + "\n")
+}
+
+// key writes a dictionary key.
+func (p *plistWriter) key(k string) {
+	p.line("<key>" + escapeXML(k) + "</key>")
+}
+
+// string writes a string value.
+func (p *plistWriter) string(s string) {
+	p.line("<string>" + escapeXML(s) + "</string>")
+}
+
+// bool writes a boolean value.
+func (p *plistWriter) bool(b bool) {
+	if b {
+		p.line("<true/>")
+	} else {
+		p.line("<false/>")
+	}
+}
+
+// calendarInterval writes the StartCalendarInterval of a cron schedule.
+//
+// Only single values and wildcards are supported in each field.
+func (p *plistWriter) calendarInterval(cron string) error {
+	fields, err := cronFields(cron)
+	if err != nil {
+		return err
+	}
+
+	p.key("StartCalendarInterval")
+	p.line("<dict>")
+	p.depth++
+	for i, key := range []string{"Minute", "Hour", "Day", "Month", "Weekday"} {
+		if fields[i] == "*" {
+			continue
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return fmt.Errorf("unsupported cron %s %q for launchd", strings.ToLower(key), fields[i])
+		}
+		p.key(key)
+		p.line("<integer>" + strconv.Itoa(n) + "</integer>")
+	}
+	p.depth--
+	p.line("</dict>")
+	return nil
+}
+
+// escapeXML escapes text for XML.
+func escapeXML(s string) string {
+	b := &strings.Builder{}
+	_ = xml.EscapeText(b, []byte(s))
+	return b.String()
+}
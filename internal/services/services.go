@@ -0,0 +1,207 @@
+// Package services generates and controls the services of formulae.
+//
+// Services are defined by a formula's service block. On Linux, services are
+// rendered as systemd user units (and timers for scheduled services). On
+// macOS, services are rendered as launchd agents.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/prefix"
+)
+
+// Service name keys of the service block.
+const (
+	OSLinux = "linux" // Linux services, rendered for systemd
+	OSMacOS = "macos" // macOS services, rendered for launchd
+)
+
+// ErrNoService is returned for formulae without a service block.
+var ErrNoService = errors.New("formula does not define a service")
+
+// Service is a formula's service with its paths resolved against a prefix.
+type Service struct {
+	Formula string // name of the formula
+	Name    string // name of the service, overriding the default name
+
+	Args         []string              // command and arguments
+	RunType      common.ServiceRunType // when the command runs
+	Interval     int                   // seconds between runs of interval services
+	Cron         string                // schedule of cron services
+	Environment  map[string]string     // environment variables
+	KeepAlive    common.KeepAliveConfig
+	WorkingDir   string
+	InputPath    string
+	LogPath      string
+	ErrorLogPath string
+	RequireRoot  bool
+	ProcessType  common.ProcessType
+}
+
+// New resolves the service of a formula for an operating system, one of
+// OSLinux or OSMacOS.
+//
+// The service block refers to the prefix and home directory with the
+// placeholders $HOMEBREW_PREFIX and $HOME, so paths such as opt_prefix and
+// var are resolved against the given prefix.
+func New(f formula.PlatformFormula, p prefix.Prefix, osName string) (*Service, error) {
+	src := f.Service()
+	if src == nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), ErrNoService)
+	}
+
+	args, err := src.RunArgs(osName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: %w for %s", f.Name(), ErrNoService, osName)
+	}
+
+	r := placeholders(p)
+	for i := range args {
+		args[i] = r.Replace(args[i])
+	}
+
+	env := make(map[string]string, len(src.EnvironmentVariables))
+	for k, v := range src.EnvironmentVariables {
+		env[k] = r.Replace(v)
+	}
+
+	runType := src.RunType
+	if runType == "" {
+		runType = common.RunTypeImmediate
+	}
+
+	return &Service{
+		Formula:      f.Name(),
+		Name:         src.Name[osName],
+		Args:         args,
+		RunType:      runType,
+		Interval:     src.Interval,
+		Cron:         src.Cron,
+		Environment:  env,
+		KeepAlive:    src.KeepAlive,
+		WorkingDir:   r.Replace(src.WorkingDir),
+		InputPath:    r.Replace(src.InputPath),
+		LogPath:      r.Replace(src.LogPath),
+		ErrorLogPath: r.Replace(src.ErrorLogPath),
+		RequireRoot:  src.RequireRoot,
+		ProcessType:  src.ProcessType,
+	}, nil
+}
+
+// placeholders replaces the path placeholders of service blocks.
+func placeholders(p prefix.Prefix) *strings.Replacer {
+	home, _ := os.UserHomeDir()
+	return strings.NewReplacer(
+		"$HOMEBREW_PREFIX", p.String(),
+		"$HOMEBREW_CELLAR", p.Cellar(),
+		"$HOME", home,
+		"~/", home+"/",
+	)
+}
+
+// Scheduled reports whether the service runs on a schedule instead of continuously.
+func (s *Service) Scheduled() bool {
+	return s.RunType == common.RunTypeInterval || s.RunType == common.RunTypeCron
+}
+
+// sortedEnvironment lists the environment variable names in sorted order.
+func (s *Service) sortedEnvironment() []string {
+	return slices.Sorted(maps.Keys(s.Environment))
+}
+
+// Status is the status of a service.
+type Status string
+
+// Status values, as reported by brew services.
+const (
+	StatusNone      Status = "none"      // the service is not loaded
+	StatusStarted   Status = "started"   // the service is running
+	StatusScheduled Status = "scheduled" // the service will run on its schedule
+	StatusStopped   Status = "stopped"   // the service is loaded but not running
+	StatusError     Status = "error"     // the service failed
+)
+
+// Manager controls services with a service manager.
+type Manager interface {
+	// Files produces the files defining the service, keyed by path.
+	Files(s *Service) (map[string]string, error)
+
+	// Start writes the service's files and starts the service.
+	Start(ctx context.Context, s *Service) error
+
+	// Restart writes the service's files and restarts the service.
+	Restart(ctx context.Context, s *Service) error
+
+	// Stop stops the service and removes its files.
+	Stop(ctx context.Context, s *Service) error
+
+	// Status reports the status of the service.
+	Status(ctx context.Context, s *Service) (Status, error)
+}
+
+// SystemOS produces the service block key for this system.
+func SystemOS() string {
+	if runtime.GOOS == "darwin" {
+		return OSMacOS
+	}
+	return OSLinux
+}
+
+// SystemManager produces the service manager for this system.
+func SystemManager() Manager {
+	if runtime.GOOS == "darwin" {
+		return NewLaunchd()
+	}
+	return NewSystemd()
+}
+
+// runner runs a service manager command.
+type runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// execRunner runs a command, including its output in the error.
+func execRunner(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return out, fmt.Errorf("running %s %s: %w\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// writeFiles writes the service's files.
+func writeFiles(files map[string]string) error {
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("creating service directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(files[path]), 0o644); err != nil {
+			return fmt.Errorf("writing service file: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeFiles removes the service's files.
+func removeFiles(files map[string]string) error {
+	var errs error
+	for path := range files {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = errors.Join(errs, fmt.Errorf("removing service file: %w", err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc/v2"
+
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+	brewv1 "github.com/act3-ai/hops/internal/apis/formulae.brew.sh/v1"
+	"github.com/act3-ai/hops/internal/formula"
+	"github.com/act3-ai/hops/internal/platform"
+	"github.com/act3-ai/hops/internal/prefix"
+)
+
+// redis is the service of the redis formula.
+var redis = &Service{
+	Formula:      "redis",
+	Args:         []string{"/home/linuxbrew/.linuxbrew/opt/redis/bin/redis-server", "/home/linuxbrew/.linuxbrew/etc/redis.conf"},
+	RunType:      common.RunTypeImmediate,
+	KeepAlive:    common.KeepAliveConfig{Always: true},
+	WorkingDir:   "/home/linuxbrew/.linuxbrew/var",
+	LogPath:      "/home/linuxbrew/.linuxbrew/var/log/redis.log",
+	ErrorLogPath: "/home/linuxbrew/.linuxbrew/var/log/redis.log",
+}
+
+// backup is a scheduled service with an unusual command and environment.
+var backup = &Service{
+	Formula:     "backup",
+	Args:        []string{"/opt/backup/bin/backup", "--label", "daily run", "$HOME", "100%"},
+	RunType:     common.RunTypeCron,
+	Cron:        "30 2 * * 1-5",
+	Environment: map[string]string{"TZ": "UTC", "GREETING": `say "hi"`},
+}
+
+func TestNew(t *testing.T) {
+	info := &brewv1.Info{}
+	err := json.Unmarshal([]byte(`{
+		"name": "redis",
+		"versions": {"stable": "7.2.5"},
+		"service": {
+			"run": ["$HOMEBREW_PREFIX/opt/redis/bin/redis-server", "$HOMEBREW_PREFIX/etc/redis.conf"],
+			"keep_alive": {"always": true},
+			"working_dir": "$HOMEBREW_PREFIX/var",
+			"log_path": "$HOMEBREW_PREFIX/var/log/redis.log",
+			"error_log_path": "$HOMEBREW_PREFIX/var/log/redis.log"
+		}
+	}`), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := formula.FromV1(info).ForPlatform(platform.X8664Linux)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := New(f, prefix.Prefix("/home/linuxbrew/.linuxbrew"), OSLinux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit, want := SystemdUnit(got), SystemdUnit(redis); unit != want {
+		t.Errorf("New() resolved to unit:\n%s\nwant:\n%s", unit, want)
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	tests := []struct {
+		name string
+		svc  *Service
+		want string
+	}{
+		{
+			name: "Immediate",
+			svc:  redis,
+			want: heredoc.Doc(`
+				[Unit]
+				Description=Homebrew generated unit for redis
+
+				[Install]
+				WantedBy=default.target
+
+				[Service]
+				Type=simple
+				ExecStart=/home/linuxbrew/.linuxbrew/opt/redis/bin/redis-server /home/linuxbrew/.linuxbrew/etc/redis.conf
+				Restart=always
+				WorkingDirectory=/home/linuxbrew/.linuxbrew/var
+				StandardOutput=append:/home/linuxbrew/.linuxbrew/var/log/redis.log
+				StandardError=append:/home/linuxbrew/.linuxbrew/var/log/redis.log
+				`),
+		},
+		{
+			name: "Scheduled",
+			svc:  backup,
+			want: heredoc.Doc(`
+				[Unit]
+				Description=Homebrew generated unit for backup
+
+				[Service]
+				Type=oneshot
+				ExecStart=/opt/backup/bin/backup --label "daily run" $$HOME 100%%
+				Environment="GREETING=say \"hi\""
+				Environment=TZ=UTC
+				`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SystemdUnit(tt.svc); got != tt.want {
+				t.Errorf("SystemdUnit() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdTimer(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     *Service
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Cron",
+			svc:  backup,
+			want: heredoc.Doc(`
+				[Unit]
+				Description=Homebrew generated timer for backup
+
+				[Install]
+				WantedBy=timers.target
+
+				[Timer]
+				Unit=homebrew.backup.service
+				OnCalendar=Mon..Fri *-*-* 02:30:00
+				Persistent=true
+				`),
+		},
+		{
+			name: "Interval",
+			svc:  &Service{Formula: "sync", Name: "sync-agent", RunType: common.RunTypeInterval, Interval: 300},
+			want: heredoc.Doc(`
+				[Unit]
+				Description=Homebrew generated timer for sync
+
+				[Install]
+				WantedBy=timers.target
+
+				[Timer]
+				Unit=sync-agent.service
+				OnActiveSec=300
+				OnUnitActiveSec=300
+				`),
+		},
+		{
+			name:    "Immediate",
+			svc:     redis,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SystemdTimer(tt.svc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SystemdTimer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SystemdTimer() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_onCalendar(t *testing.T) {
+	tests := []struct {
+		cron    string
+		want    string
+		wantErr bool
+	}{
+		{cron: "0 * * * *", want: "*-*-* *:00:00"},
+		{cron: "*/15 * * * *", want: "*-*-* *:0/15:00"},
+		{cron: "5 4 1 1,7 *", want: "*-1,7-1 04:05:00"},
+		{cron: "0 0 * * 0,6", want: "Sun,Sat *-*-* 00:00:00"},
+		{cron: "0 0 * *", wantErr: true},
+		{cron: "0 0 * * mon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cron, func(t *testing.T) {
+			got, err := onCalendar(tt.cron)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("onCalendar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("onCalendar() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	want := heredoc.Doc(`
+		<?xml version="1.0" encoding="UTF-8"?>
+		<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+		<plist version="1.0">
+		<dict>
+			<key>Label</key>
+			<string>homebrew.mxcl.backup</string>
+			<key>ProgramArguments</key>
+			<array>
+				<string>/opt/backup/bin/backup</string>
+				<string>--label</string>
+				<string>daily run</string>
+				<string>$HOME</string>
+				<string>100%</string>
+			</array>
+			<key>StartCalendarInterval</key>
+			<dict>
+				<key>Minute</key>
+				<integer>30</integer>
+				<key>Hour</key>
+				<integer>2</integer>
+			</dict>
+			<key>EnvironmentVariables</key>
+			<dict>
+				<key>GREETING</key>
+				<string>say &#34;hi&#34;</string>
+				<key>TZ</key>
+				<string>UTC</string>
+			</dict>
+		</dict>
+		</plist>
+		`)
+
+	// Weekday ranges cannot be expressed by a single calendar interval
+	svc := *backup
+	svc.Cron = "30 2 * * *"
+
+	got, err := LaunchdPlist(&svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("LaunchdPlist() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := LaunchdPlist(backup); err == nil {
+		t.Errorf("LaunchdPlist() with weekday range succeeded, want error")
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+
+	"github.com/act3-ai/hops/internal/apis/formulae.brew.sh/common"
+)
+
+// Systemd controls services as systemd user units.
+type Systemd struct {
+	Dir string // directory of user units
+	run runner
+}
+
+// NewSystemd creates a Systemd manager for the user's units.
+func NewSystemd() *Systemd {
+	return &Systemd{
+		Dir: filepath.Join(xdg.ConfigHome, "systemd", "user"),
+		run: execRunner,
+	}
+}
+
+// UnitName produces the name of the service's units, without a suffix.
+//
+//	homebrew.postgresql@16
+func UnitName(s *Service) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "homebrew." + s.Formula
+}
+
+// Files implements Manager.
+func (m *Systemd) Files(s *Service) (map[string]string, error) {
+	files := map[string]string{
+		filepath.Join(m.Dir, UnitName(s)+".service"): SystemdUnit(s),
+	}
+	if s.Scheduled() {
+		timer, err := SystemdTimer(s)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Join(m.Dir, UnitName(s)+".timer")] = timer
+	}
+	return files, nil
+}
+
+// unit produces the unit that is enabled to start the service.
+func (m *Systemd) unit(s *Service) string {
+	if s.Scheduled() {
+		return UnitName(s) + ".timer"
+	}
+	return UnitName(s) + ".service"
+}
+
+// systemctl runs a systemctl command for the user's units.
+func (m *Systemd) systemctl(ctx context.Context, args ...string) ([]byte, error) {
+	return m.run(ctx, "systemctl", append([]string{"--user"}, args...)...)
+}
+
+// load writes the service's units and reloads them.
+func (m *Systemd) load(ctx context.Context, s *Service) error {
+	if s.RequireRoot {
+		return fmt.Errorf("%s must run as root, which systemd user units cannot do", s.Formula)
+	}
+
+	files, err := m.Files(s)
+	if err != nil {
+		return err
+	}
+	if err := writeFiles(files); err != nil {
+		return err
+	}
+	_, err = m.systemctl(ctx, "daemon-reload")
+	return err
+}
+
+// Start implements Manager.
+func (m *Systemd) Start(ctx context.Context, s *Service) error {
+	if err := m.load(ctx, s); err != nil {
+		return err
+	}
+	_, err := m.systemctl(ctx, "enable", "--now", m.unit(s))
+	return err
+}
+
+// Restart implements Manager.
+func (m *Systemd) Restart(ctx context.Context, s *Service) error {
+	if err := m.load(ctx, s); err != nil {
+		return err
+	}
+	if _, err := m.systemctl(ctx, "enable", m.unit(s)); err != nil {
+		return err
+	}
+	_, err := m.systemctl(ctx, "restart", m.unit(s))
+	return err
+}
+
+// Stop implements Manager.
+func (m *Systemd) Stop(ctx context.Context, s *Service) error {
+	files, err := m.Files(s)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.systemctl(ctx, "disable", "--now", m.unit(s)); err != nil {
+		return err
+	}
+	if err := removeFiles(files); err != nil {
+		return err
+	}
+	_, err = m.systemctl(ctx, "daemon-reload")
+	return err
+}
+
+// Status implements Manager.
+func (m *Systemd) Status(ctx context.Context, s *Service) (Status, error) {
+	_, err := os.Stat(filepath.Join(m.Dir, UnitName(s)+".service"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return StatusNone, nil
+	case err != nil:
+		return "", fmt.Errorf("checking service: %w", err)
+	}
+
+	// is-active exits non-zero for inactive units, so only its output is used
+	out, _ := m.systemctl(ctx, "is-active", m.unit(s))
+	switch strings.TrimSpace(string(out)) {
+	case "active", "activating", "reloading":
+		if s.Scheduled() {
+			return StatusScheduled, nil
+		}
+		return StatusStarted, nil
+	case "failed":
+		return StatusError, nil
+	default:
+		return StatusStopped, nil
+	}
+}
+
+// SystemdUnit renders the service as a systemd service unit.
+func SystemdUnit(s *Service) string {
+	lines := []string{
+		"[Unit]",
+		"Description=Homebrew generated unit for " + s.Formula,
+		"",
+	}
+
+	// Scheduled services are started by their timer
+	if !s.Scheduled() {
+		lines = append(lines,
+			"[Install]",
+			"WantedBy=default.target",
+			"",
+		)
+	}
+
+	lines = append(lines, "[Service]")
+	if s.Scheduled() {
+		lines = append(lines, "Type=oneshot")
+	} else {
+		lines = append(lines, "Type=simple")
+	}
+
+	args := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		// Variables are not expanded in arguments
+		args[i] = systemdQuote(strings.ReplaceAll(arg, "$", "$$"))
+	}
+	lines = append(lines, "ExecStart="+strings.Join(args, " "))
+
+	switch {
+	case s.KeepAlive.Always:
+		lines = append(lines, "Restart=always")
+	case s.KeepAlive.SuccessfulExit:
+		lines = append(lines, "Restart=on-success")
+	case s.KeepAlive.Crashed:
+		lines = append(lines, "Restart=on-abnormal")
+	}
+
+	if s.WorkingDir != "" {
+		lines = append(lines, "WorkingDirectory="+systemdEscape(s.WorkingDir))
+	}
+	if s.InputPath != "" {
+		lines = append(lines, "StandardInput=file:"+systemdEscape(s.InputPath))
+	}
+	if s.LogPath != "" {
+		lines = append(lines, "StandardOutput=append:"+systemdEscape(s.LogPath))
+	}
+	if s.ErrorLogPath != "" {
+		lines = append(lines, "StandardError=append:"+systemdEscape(s.ErrorLogPath))
+	}
+	for _, k := range s.sortedEnvironment() {
+		lines = append(lines, "Environment="+systemdQuote(k+"="+s.Environment[k]))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// SystemdTimer renders the schedule of an interval or cron service as a systemd timer.
+func SystemdTimer(s *Service) (string, error) {
+	lines := []string{
+		"[Unit]",
+		"Description=Homebrew generated timer for " + s.Formula,
+		"",
+		"[Install]",
+		"WantedBy=timers.target",
+		"",
+		"[Timer]",
+		"Unit=" + UnitName(s) + ".service",
+	}
+
+	switch s.RunType {
+	case common.RunTypeInterval:
+		if s.Interval <= 0 {
+			return "", fmt.Errorf("%s: interval service has no interval", s.Formula)
+		}
+		interval := strconv.Itoa(s.Interval)
+		lines = append(lines,
+			"OnActiveSec="+interval,
+			"OnUnitActiveSec="+interval,
+		)
+	case common.RunTypeCron:
+		calendar, err := onCalendar(s.Cron)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", s.Formula, err)
+		}
+		lines = append(lines,
+			"OnCalendar="+calendar,
+			"Persistent=true",
+		)
+	default:
+		return "", fmt.Errorf("%s: %s services do not have a timer", s.Formula, s.RunType)
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// weekdays names the days of the week for systemd, from 0 (and 7) as Sunday.
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// onCalendar converts a cron schedule to a systemd calendar event.
+//
+//	"30 2 * * 1-5" -> "Mon..Fri *-*-* 02:30:00"
+func onCalendar(cron string) (string, error) {
+	fields, err := cronFields(cron)
+	if err != nil {
+		return "", err
+	}
+	minute, hour, day, month, weekday := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	calendar := fmt.Sprintf("*-%s-%s %s:%s:00",
+		calendarField(month, "1", false),
+		calendarField(day, "1", false),
+		calendarField(hour, "0", true),
+		calendarField(minute, "0", true),
+	)
+	if weekday == "*" {
+		return calendar, nil
+	}
+
+	// Weekdays are named
+	days := strings.Split(weekday, ",")
+	for i, d := range days {
+		from, to, isRange := strings.Cut(d, "-")
+		f, err := strconv.Atoi(from)
+		if err != nil || f < 0 || f > 7 {
+			return "", fmt.Errorf("unsupported cron weekday %q", d)
+		}
+		days[i] = weekdays[f]
+		if isRange {
+			t, err := strconv.Atoi(to)
+			if err != nil || t < 0 || t > 7 {
+				return "", fmt.Errorf("unsupported cron weekday %q", d)
+			}
+			days[i] += ".." + weekdays[t]
+		}
+	}
+	return strings.Join(days, ",") + " " + calendar, nil
+}
+
+// cronFields splits a cron schedule into its five fields.
+func cronFields(cron string) ([]string, error) {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have five fields", cron)
+	}
+	return fields, nil
+}
+
+// calendarField converts a cron field to a systemd calendar field.
+func calendarField(field, first string, pad bool) string {
+	values := strings.Split(field, ",")
+	for i, v := range values {
+		switch {
+		case v == "*":
+		// Steps start from the first value
+		case strings.HasPrefix(v, "*/"):
+			values[i] = first + "/" + strings.TrimPrefix(v, "*/")
+		default:
+			v = strings.Replace(v, "-", "..", 1)
+			if pad {
+				if n, err := strconv.Atoi(v); err == nil {
+					v = fmt.Sprintf("%02d", n)
+				}
+			}
+			values[i] = v
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// systemdEscape escapes specifiers in a systemd setting.
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes a value for Environment, or for ExecStart once
+// variables are escaped.
+func systemdQuote(arg string) string {
+	arg = systemdEscape(arg)
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\;") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,427 @@
+// Package elfutil rewrites the dynamic linking information of ELF files.
+package elfutil
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// IsELF reports whether data starts with the ELF magic number.
+func IsELF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(elf.ELFMAG))
+}
+
+// Relocate rewrites the interpreter (PT_INTERP) and library search paths
+// (DT_RPATH and DT_RUNPATH) of an ELF file using the replace function.
+//
+// Paths that fit in their original space are patched in place. Paths that
+// grow are moved to a new loadable segment appended to the file, which
+// takes the place of a PT_NOTE program header.
+//
+// The returned data is only valid if changed is true.
+func Relocate(data []byte, replace func(string) string) (patched []byte, changed bool, err error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing ELF file: %w", err)
+	}
+	defer f.Close()
+
+	e := newEditor(f, bytes.Clone(data))
+
+	if err := e.relocateInterp(replace); err != nil {
+		return nil, false, err
+	}
+	if err := e.relocateDynamic(replace); err != nil {
+		return nil, false, err
+	}
+
+	if !e.changed {
+		return data, false, nil
+	}
+
+	if err := e.grow(); err != nil {
+		return nil, false, err
+	}
+
+	return e.data, true, nil
+}
+
+// editor holds the state of an ELF file being rewritten.
+type editor struct {
+	file    *elf.File
+	data    []byte
+	order   binary.ByteOrder
+	is64    bool
+	changed bool
+
+	phoff     uint64
+	phentsize uint64
+	shoff     uint64
+	shentsize uint64
+	progs     []elf.ProgHeader
+
+	strtab       uint64     // file offset of the dynamic string table
+	strtabAddr   uint64     // virtual address of the dynamic string table
+	strsz        uint64     // size of the dynamic string table
+	growStrings  []dynEntry // dynamic entries whose string no longer fits
+	growInterp   string     // interpreter that no longer fits
+	interpIndex  int        // index of the PT_INTERP program header
+	dynamicIndex int        // index of the PT_DYNAMIC program header
+}
+
+// dynEntry is a DT_RPATH or DT_RUNPATH entry of the dynamic section.
+type dynEntry struct {
+	off   uint64 // file offset of the entry
+	value string // replacement value
+}
+
+func newEditor(f *elf.File, data []byte) *editor {
+	e := &editor{
+		file:         f,
+		data:         data,
+		order:        f.ByteOrder,
+		is64:         f.Class == elf.ELFCLASS64,
+		interpIndex:  -1,
+		dynamicIndex: -1,
+	}
+
+	// Read the header fields not exposed by debug/elf
+	if e.is64 {
+		e.phoff = e.order.Uint64(data[32:])
+		e.shoff = e.order.Uint64(data[40:])
+		e.phentsize = uint64(e.order.Uint16(data[54:]))
+		e.shentsize = uint64(e.order.Uint16(data[58:]))
+	} else {
+		e.phoff = uint64(e.order.Uint32(data[28:]))
+		e.shoff = uint64(e.order.Uint32(data[32:]))
+		e.phentsize = uint64(e.order.Uint16(data[42:]))
+		e.shentsize = uint64(e.order.Uint16(data[46:]))
+	}
+
+	for i, prog := range f.Progs {
+		e.progs = append(e.progs, prog.ProgHeader)
+		switch prog.Type {
+		case elf.PT_INTERP:
+			e.interpIndex = i
+		case elf.PT_DYNAMIC:
+			e.dynamicIndex = i
+		}
+	}
+
+	return e
+}
+
+// relocateInterp rewrites the program interpreter.
+func (e *editor) relocateInterp(replace func(string) string) error {
+	if e.interpIndex < 0 {
+		return nil
+	}
+
+	prog := e.progs[e.interpIndex]
+	if prog.Off+prog.Filesz > uint64(len(e.data)) {
+		return errors.New("PT_INTERP segment is out of bounds")
+	}
+
+	space := e.data[prog.Off : prog.Off+prog.Filesz]
+	old := cstring(space)
+	value := replace(old)
+
+	switch {
+	// Unchanged
+	case value == old:
+	// Fits in place, leaving room for the NUL terminator
+	case len(value) < len(space):
+		writeString(space, value)
+		e.changed = true
+	// Must be moved
+	default:
+		e.growInterp = value
+		e.changed = true
+	}
+
+	return nil
+}
+
+// relocateDynamic rewrites the library search paths.
+func (e *editor) relocateDynamic(replace func(string) string) error {
+	if e.dynamicIndex < 0 {
+		return nil
+	}
+
+	prog := e.progs[e.dynamicIndex]
+	entsize := uint64(8)
+	if e.is64 {
+		entsize = 16
+	}
+
+	type rawEntry struct {
+		off uint64
+		tag elf.DynTag
+		val uint64
+	}
+
+	var entries []rawEntry
+	for off := prog.Off; off+entsize <= prog.Off+prog.Filesz && off+entsize <= uint64(len(e.data)); off += entsize {
+		var tag elf.DynTag
+		var val uint64
+		if e.is64 {
+			tag = elf.DynTag(e.order.Uint64(e.data[off:]))
+			val = e.order.Uint64(e.data[off+8:])
+		} else {
+			tag = elf.DynTag(e.order.Uint32(e.data[off:]))
+			val = uint64(e.order.Uint32(e.data[off+4:]))
+		}
+		if tag == elf.DT_NULL {
+			break
+		}
+		switch tag {
+		case elf.DT_STRTAB:
+			e.strtabAddr = val
+		case elf.DT_STRSZ:
+			e.strsz = val
+		case elf.DT_RPATH, elf.DT_RUNPATH:
+			entries = append(entries, rawEntry{off: off, tag: tag, val: val})
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	strtab, ok := e.addrToOffset(e.strtabAddr)
+	if !ok || strtab+e.strsz > uint64(len(e.data)) {
+		return errors.New("dynamic string table is out of bounds")
+	}
+	e.strtab = strtab
+
+	for _, entry := range entries {
+		if entry.val >= e.strsz {
+			return fmt.Errorf("%s entry is out of bounds", entry.tag)
+		}
+		space := e.data[strtab+entry.val : strtab+e.strsz]
+		old := cstring(space)
+		value := replace(old)
+
+		switch {
+		// Unchanged
+		case value == old:
+		// Fits in place
+		case len(value) <= len(old):
+			writeString(space[:len(old)+1], value)
+			e.changed = true
+		// Must be moved
+		default:
+			e.growStrings = append(e.growStrings, dynEntry{off: entry.off, value: value})
+			e.changed = true
+		}
+	}
+
+	return nil
+}
+
+// grow appends a loadable segment holding the values that do not fit in place.
+func (e *editor) grow() error {
+	if len(e.growStrings) == 0 && e.growInterp == "" {
+		return nil
+	}
+
+	// Pick a PT_NOTE program header to turn into the new PT_LOAD
+	noteIndex := -1
+	for i, prog := range e.progs {
+		if prog.Type == elf.PT_NOTE {
+			noteIndex = i
+		}
+	}
+	if noteIndex < 0 {
+		return errors.New("no room to add a segment: file has no PT_NOTE program header")
+	}
+
+	// Page alignment and the end of the address space
+	pageSize := uint64(0x1000)
+	var end uint64
+	for _, prog := range e.progs {
+		if prog.Type != elf.PT_LOAD {
+			continue
+		}
+		pageSize = max(pageSize, prog.Align)
+		end = max(end, prog.Vaddr+prog.Memsz)
+	}
+	off := alignUp(uint64(len(e.data)), pageSize)
+	addr := alignUp(end, pageSize)
+
+	// Assemble the segment contents
+	var content []byte
+	if len(e.growStrings) > 0 {
+		// Copy the dynamic string table so existing offsets stay valid
+		content = append(content, e.data[e.strtab:e.strtab+e.strsz]...)
+		for _, entry := range e.growStrings {
+			e.putDyn(entry.off+e.wordSize(), uint64(len(content)))
+			content = append(content, entry.value...)
+			content = append(content, 0)
+		}
+		if err := e.moveStrtab(addr, off, uint64(len(content))); err != nil {
+			return err
+		}
+	}
+	if e.growInterp != "" {
+		start := uint64(len(content))
+		content = append(content, e.growInterp...)
+		content = append(content, 0)
+
+		interp := &e.progs[e.interpIndex]
+		interp.Off = off + start
+		interp.Vaddr = addr + start
+		interp.Paddr = addr + start
+		interp.Filesz = uint64(len(e.growInterp) + 1)
+		interp.Memsz = interp.Filesz
+		e.updateSection(".interp", interp.Vaddr, interp.Off, interp.Filesz)
+	}
+
+	// Replace the PT_NOTE with a PT_LOAD placed after the last PT_LOAD,
+	// loaders require PT_LOAD headers sorted by address
+	load := elf.ProgHeader{
+		Type:   elf.PT_LOAD,
+		Flags:  elf.PF_R,
+		Off:    off,
+		Vaddr:  addr,
+		Paddr:  addr,
+		Filesz: uint64(len(content)),
+		Memsz:  uint64(len(content)),
+		Align:  pageSize,
+	}
+	progs := append(e.progs[:noteIndex:noteIndex], e.progs[noteIndex+1:]...)
+	last := 0
+	for i, prog := range progs {
+		if prog.Type == elf.PT_LOAD {
+			last = i + 1
+		}
+	}
+	progs = append(progs[:last:last], append([]elf.ProgHeader{load}, progs[last:]...)...)
+	e.progs = progs
+
+	for i, prog := range e.progs {
+		e.putProg(e.phoff+uint64(i)*e.phentsize, prog)
+	}
+
+	padded := make([]byte, off, off+uint64(len(content)))
+	copy(padded, e.data)
+	e.data = append(padded, content...)
+
+	return nil
+}
+
+// moveStrtab points the dynamic section at a relocated string table.
+func (e *editor) moveStrtab(addr, off, size uint64) error {
+	prog := e.progs[e.dynamicIndex]
+	entsize := 2 * e.wordSize()
+	for pos := prog.Off; pos+entsize <= prog.Off+prog.Filesz; pos += entsize {
+		var tag elf.DynTag
+		if e.is64 {
+			tag = elf.DynTag(e.order.Uint64(e.data[pos:]))
+		} else {
+			tag = elf.DynTag(e.order.Uint32(e.data[pos:]))
+		}
+		switch tag {
+		case elf.DT_NULL:
+			e.updateSection(".dynstr", addr, off, size)
+			return nil
+		case elf.DT_STRTAB:
+			e.putDyn(pos+e.wordSize(), addr)
+		case elf.DT_STRSZ:
+			e.putDyn(pos+e.wordSize(), size)
+		}
+	}
+	return errors.New("dynamic section is not terminated")
+}
+
+// updateSection updates the location of a section header, if present.
+func (e *editor) updateSection(name string, addr, off, size uint64) {
+	for i, s := range e.file.Sections {
+		if s.Name != name {
+			continue
+		}
+		pos := e.shoff + uint64(i)*e.shentsize
+		if e.is64 {
+			e.order.PutUint64(e.data[pos+16:], addr)
+			e.order.PutUint64(e.data[pos+24:], off)
+			e.order.PutUint64(e.data[pos+32:], size)
+		} else {
+			e.order.PutUint32(e.data[pos+12:], uint32(addr))
+			e.order.PutUint32(e.data[pos+16:], uint32(off))
+			e.order.PutUint32(e.data[pos+20:], uint32(size))
+		}
+		return
+	}
+}
+
+// putProg encodes a program header at the given offset.
+func (e *editor) putProg(pos uint64, prog elf.ProgHeader) {
+	b := e.data[pos:]
+	if e.is64 {
+		e.order.PutUint32(b[0:], uint32(prog.Type))
+		e.order.PutUint32(b[4:], uint32(prog.Flags))
+		e.order.PutUint64(b[8:], prog.Off)
+		e.order.PutUint64(b[16:], prog.Vaddr)
+		e.order.PutUint64(b[24:], prog.Paddr)
+		e.order.PutUint64(b[32:], prog.Filesz)
+		e.order.PutUint64(b[40:], prog.Memsz)
+		e.order.PutUint64(b[48:], prog.Align)
+		return
+	}
+	e.order.PutUint32(b[0:], uint32(prog.Type))
+	e.order.PutUint32(b[4:], uint32(prog.Off))
+	e.order.PutUint32(b[8:], uint32(prog.Vaddr))
+	e.order.PutUint32(b[12:], uint32(prog.Paddr))
+	e.order.PutUint32(b[16:], uint32(prog.Filesz))
+	e.order.PutUint32(b[20:], uint32(prog.Memsz))
+	e.order.PutUint32(b[24:], uint32(prog.Flags))
+	e.order.PutUint32(b[28:], uint32(prog.Align))
+}
+
+// putDyn encodes a word at the given offset.
+func (e *editor) putDyn(pos, val uint64) {
+	if e.is64 {
+		e.order.PutUint64(e.data[pos:], val)
+		return
+	}
+	e.order.PutUint32(e.data[pos:], uint32(val))
+}
+
+// wordSize returns the size of an address in bytes.
+func (e *editor) wordSize() uint64 {
+	if e.is64 {
+		return 8
+	}
+	return 4
+}
+
+// addrToOffset converts a virtual address to a file offset.
+func (e *editor) addrToOffset(addr uint64) (uint64, bool) {
+	for _, prog := range e.progs {
+		if prog.Type == elf.PT_LOAD && addr >= prog.Vaddr && addr < prog.Vaddr+prog.Filesz {
+			return addr - prog.Vaddr + prog.Off, true
+		}
+	}
+	return 0, false
+}
+
+// cstring returns the NUL-terminated string at the start of b.
+func cstring(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return string(b[:i])
+	}
+	return string(b)
+}
+
+// writeString writes s into space and fills the remainder with NUL bytes.
+func writeString(space []byte, s string) {
+	n := copy(space, s)
+	clear(space[n:])
+}
+
+func alignUp(v, align uint64) uint64 {
+	return (v + align - 1) / align * align
+}
@@ -0,0 +1,216 @@
+package elfutil
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testBase = 0x400000 // virtual address of the test file
+
+// testELF builds a minimal 64-bit executable with an interpreter, a runpath,
+// and optionally a PT_NOTE program header.
+func testELF(interp, runpath string, note bool) []byte {
+	const (
+		ehsize    = 64
+		phentsize = 56
+	)
+	phnum := 3
+	if note {
+		phnum++
+	}
+
+	interpOff := uint64(ehsize + phnum*phentsize)
+	strtabOff := interpOff + uint64(len(interp)+1)
+	strtab := "\x00" + runpath + "\x00"
+	dynamicOff := strtabOff + uint64(len(strtab))
+	noteOff := dynamicOff + 4*16
+	size := noteOff + 16
+
+	data := make([]byte, size)
+	le := binary.LittleEndian
+	copy(data, elf.ELFMAG)
+	data[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	data[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	data[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	le.PutUint16(data[16:], uint16(elf.ET_EXEC))
+	le.PutUint16(data[18:], uint16(elf.EM_X86_64))
+	le.PutUint32(data[20:], uint32(elf.EV_CURRENT))
+	le.PutUint64(data[24:], testBase)
+	le.PutUint64(data[32:], ehsize)
+	le.PutUint16(data[52:], ehsize)
+	le.PutUint16(data[54:], phentsize)
+	le.PutUint16(data[56:], uint16(phnum))
+	le.PutUint16(data[58:], 64)
+
+	progs := []elf.ProgHeader{
+		{Type: elf.PT_INTERP, Flags: elf.PF_R, Off: interpOff, Filesz: uint64(len(interp) + 1)},
+		{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_X, Off: 0, Filesz: size, Align: 0x1000},
+		{Type: elf.PT_DYNAMIC, Flags: elf.PF_R, Off: dynamicOff, Filesz: 4 * 16},
+	}
+	if note {
+		progs = append(progs, elf.ProgHeader{Type: elf.PT_NOTE, Flags: elf.PF_R, Off: noteOff, Filesz: 16, Align: 4})
+	}
+	for i, prog := range progs {
+		prog.Vaddr = testBase + prog.Off
+		prog.Paddr = prog.Vaddr
+		prog.Memsz = prog.Filesz
+		b := data[ehsize+i*phentsize:]
+		le.PutUint32(b[0:], uint32(prog.Type))
+		le.PutUint32(b[4:], uint32(prog.Flags))
+		le.PutUint64(b[8:], prog.Off)
+		le.PutUint64(b[16:], prog.Vaddr)
+		le.PutUint64(b[24:], prog.Paddr)
+		le.PutUint64(b[32:], prog.Filesz)
+		le.PutUint64(b[40:], prog.Memsz)
+		le.PutUint64(b[48:], prog.Align)
+	}
+
+	copy(data[interpOff:], interp)
+	copy(data[strtabOff:], strtab)
+	for i, entry := range [][2]uint64{
+		{uint64(elf.DT_STRTAB), testBase + strtabOff},
+		{uint64(elf.DT_STRSZ), uint64(len(strtab))},
+		{uint64(elf.DT_RUNPATH), 1},
+		{uint64(elf.DT_NULL), 0},
+	} {
+		le.PutUint64(data[dynamicOff+uint64(i)*16:], entry[0])
+		le.PutUint64(data[dynamicOff+uint64(i)*16+8:], entry[1])
+	}
+
+	return data
+}
+
+// readLinkage reads the interpreter and runpath of an ELF file.
+func readLinkage(t *testing.T, data []byte) (interp, runpath string) {
+	t.Helper()
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parsing relocated file: %v", err)
+	}
+	defer f.Close()
+
+	toOffset := func(addr uint64) uint64 {
+		for _, prog := range f.Progs {
+			if prog.Type == elf.PT_LOAD && addr >= prog.Vaddr && addr < prog.Vaddr+prog.Filesz {
+				return addr - prog.Vaddr + prog.Off
+			}
+		}
+		t.Fatalf("address %#x is not loaded", addr)
+		return 0
+	}
+
+	var strtab, runpathOff uint64
+	for _, prog := range f.Progs {
+		switch prog.Type {
+		case elf.PT_INTERP:
+			interp = cstring(data[prog.Off : prog.Off+prog.Filesz])
+		case elf.PT_DYNAMIC:
+			for off := prog.Off; off < prog.Off+prog.Filesz; off += 16 {
+				val := f.ByteOrder.Uint64(data[off+8:])
+				switch elf.DynTag(f.ByteOrder.Uint64(data[off:])) {
+				case elf.DT_STRTAB:
+					strtab = toOffset(val)
+				case elf.DT_RUNPATH:
+					runpathOff = val
+				}
+			}
+		}
+	}
+	return interp, cstring(data[strtab+runpathOff:])
+}
+
+func TestIsELF(t *testing.T) {
+	if !IsELF(testELF("/lib/ld.so", "/lib", true)) {
+		t.Error("IsELF() = false for an ELF file")
+	}
+	if IsELF([]byte("#!/bin/sh\n")) {
+		t.Error("IsELF() = true for a script")
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	const (
+		interp  = "@@HOMEBREW_PREFIX@@/lib/ld.so"
+		runpath = "@@HOMEBREW_PREFIX@@/lib:$ORIGIN/../lib"
+	)
+	long := "/" + strings.Repeat("long/", 20) + "prefix"
+
+	tests := []struct {
+		name        string
+		prefix      string
+		note        bool
+		wantChanged bool
+		wantErr     bool
+	}{
+		{name: "unchanged", prefix: "@@HOMEBREW_PREFIX@@", note: true},
+		{name: "in place", prefix: "/usr", note: true, wantChanged: true},
+		{name: "grown", prefix: long, note: true, wantChanged: true},
+		{name: "in place without note", prefix: "/usr", wantChanged: true},
+		{name: "grown without note", prefix: long, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testELF(interp, runpath, tt.note)
+			original := bytes.Clone(data)
+			replace := strings.NewReplacer("@@HOMEBREW_PREFIX@@", tt.prefix).Replace
+
+			patched, changed, err := Relocate(data, replace)
+			switch {
+			case (err != nil) != tt.wantErr:
+				t.Fatalf("Relocate() error = %v, want error %t", err, tt.wantErr)
+			case err != nil:
+				return
+			case changed != tt.wantChanged:
+				t.Fatalf("Relocate() changed = %t, want %t", changed, tt.wantChanged)
+			case !bytes.Equal(data, original):
+				t.Error("Relocate() modified its input")
+			}
+			if !changed {
+				return
+			}
+
+			gotInterp, gotRunpath := readLinkage(t, patched)
+			if want := replace(interp); gotInterp != want {
+				t.Errorf("interpreter = %q, want %q", gotInterp, want)
+			}
+			if want := replace(runpath); gotRunpath != want {
+				t.Errorf("runpath = %q, want %q", gotRunpath, want)
+			}
+		})
+	}
+}
+
+func TestRelocateGrowSegment(t *testing.T) {
+	data := testELF("/lib/ld.so", "@@HOMEBREW_PREFIX@@/lib", true)
+	patched, _, err := Relocate(data, strings.NewReplacer("@@HOMEBREW_PREFIX@@", "/"+strings.Repeat("x", 100)).Replace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(patched))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The PT_NOTE becomes a page-aligned PT_LOAD after the existing PT_LOAD
+	types := []elf.ProgType{}
+	for _, prog := range f.Progs {
+		types = append(types, prog.Type)
+	}
+	want := []elf.ProgType{elf.PT_INTERP, elf.PT_LOAD, elf.PT_LOAD, elf.PT_DYNAMIC}
+	if !slices.Equal(types, want) {
+		t.Fatalf("program headers = %v, want %v", types, want)
+	}
+	load := f.Progs[2]
+	if load.Off%0x1000 != 0 || load.Vaddr%0x1000 != 0 || load.Vaddr < f.Progs[1].Vaddr+f.Progs[1].Memsz {
+		t.Errorf("new segment at offset %#x, address %#x is misplaced", load.Off, load.Vaddr)
+	}
+	if got := uint64(len(patched)); got != load.Off+load.Filesz {
+		t.Errorf("file size = %d, want %d", got, load.Off+load.Filesz)
+	}
+}
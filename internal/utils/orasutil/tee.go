@@ -0,0 +1,98 @@
+package orasutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// errIncompleteRead is reported to the destination when a TeeFetch reader is closed early.
+var errIncompleteRead = errors.New("content was not read to the end")
+
+// TeeFetch fetches the content described by the descriptor from the source,
+// pushing it to the destination as it is read.
+//
+// The content is verified against the size and the digest when it is read to
+// the end. The push only completes if the content is read to the end.
+func TeeFetch(ctx context.Context, src content.ReadOnlyStorage, dst content.Storage, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	rc, err := src.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	pushed := make(chan error, 1)
+	go func() {
+		err := dst.Push(ctx, desc, pr)
+		pr.CloseWithError(err) // unblock writes if the push ends early
+		pushed <- err
+	}()
+
+	return &teeReader{
+		src:    rc,
+		vr:     content.NewVerifyReader(rc, desc),
+		pw:     pw,
+		pushed: pushed,
+	}, nil
+}
+
+// teeReader reads verified content while pushing it to a destination.
+type teeReader struct {
+	src    io.ReadCloser
+	vr     *content.VerifyReader
+	pw     *io.PipeWriter
+	pushed chan error
+	done   bool // the push has finished
+}
+
+// Read implements io.Reader.
+func (t *teeReader) Read(p []byte) (int, error) {
+	n, err := t.vr.Read(p)
+	if n > 0 && !t.done {
+		if _, werr := t.pw.Write(p[:n]); werr != nil {
+			t.done = true
+			<-t.pushed
+			// Content pushed concurrently by someone else
+			if !errors.Is(werr, errdef.ErrAlreadyExists) {
+				return n, fmt.Errorf("pushing content: %w", werr)
+			}
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	// Content has been read to the end
+	if verr := t.vr.Verify(); verr != nil {
+		return n, errors.Join(verr, t.finish(verr))
+	}
+	if perr := t.finish(nil); perr != nil {
+		return n, fmt.Errorf("pushing content: %w", perr)
+	}
+	return n, io.EOF
+}
+
+// finish ends the push, returning the push error.
+func (t *teeReader) finish(cause error) error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	_ = t.pw.CloseWithError(cause)
+	err := <-t.pushed
+	if errors.Is(err, errdef.ErrAlreadyExists) || errors.Is(err, cause) {
+		return nil
+	}
+	return err
+}
+
+// Close implements io.Closer.
+func (t *teeReader) Close() error {
+	_ = t.finish(errIncompleteRead)
+	return t.src.Close()
+}
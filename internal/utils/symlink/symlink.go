@@ -30,12 +30,25 @@ var MkdirAll CreateOption = func(_, newname string) error {
 	return nil
 }
 
+// Recorder records symlinks and directories before they are created.
+type Recorder interface {
+	// RecordLink records that a symlink to target will be created at newname.
+	// If an existing symlink is being replaced, previous is its target.
+	// An empty target records that the symlink to previous will be removed.
+	RecordLink(newname, target, previous string) error
+	// RecordDir records that a directory will be created at path.
+	RecordDir(path string) error
+	// Backup moves the file at path aside so it can be restored.
+	Backup(path string) error
+}
+
 // Options contains options for creating symlinks.
 type Options struct {
-	Name        string // Name to prefix dry-run messages with
-	MkdirParent bool   // Create parent directory of symlink if it does not exist
-	Overwrite   bool   // Delete files that already exist in the prefix while linking
-	DryRun      bool   // List files which would be linked or overwritten without actually linking or deleting any files
+	Name        string   // Name to prefix dry-run messages with
+	MkdirParent bool     // Create parent directory of symlink if it does not exist
+	Overwrite   bool     // Delete files that already exist in the prefix while linking
+	DryRun      bool     // List files which would be linked or overwritten without actually linking or deleting any files
+	Recorder    Recorder // Records created symlinks so they can be undone
 }
 
 // Relative creates a relative symlink at newname to the location specified by oldname.
@@ -54,7 +67,7 @@ func Relative(oldname, newname string, opts *Options) error {
 		fmt.Println(prefix + msg)
 	}
 
-	_, err = os.Lstat(newname)
+	info, err := os.Lstat(newname)
 	switch {
 	// Symlink does not exist
 	case errors.Is(err, os.ErrNotExist):
@@ -72,6 +85,27 @@ func Relative(oldname, newname string, opts *Options) error {
 			return nil
 		}
 
+		// Record before removing so a replaced symlink or file can be restored
+		if opts.Recorder != nil {
+			previous := ""
+			switch {
+			case info.Mode().Type() == os.ModeSymlink:
+				previous, err = os.Readlink(newname)
+				if err != nil {
+					return fmt.Errorf("reading existing symlink %s: %w", newname, err)
+				}
+			case info.Mode().IsRegular():
+				err = opts.Recorder.Backup(newname)
+				if err != nil {
+					return fmt.Errorf("backing up %s: %w", newname, err)
+				}
+			}
+			err = opts.Recorder.RecordLink(newname, relativeOldname, previous)
+			if err != nil {
+				return fmt.Errorf("recording symlink %s: %w", newname, err)
+			}
+		}
+
 		// Remove current file at target
 		err := os.Remove(newname)
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -94,6 +128,14 @@ func Relative(oldname, newname string, opts *Options) error {
 		}
 	}
 
+	// Record new symlinks, replaced symlinks were recorded above
+	if opts.Recorder != nil && info == nil {
+		err := opts.Recorder.RecordLink(newname, relativeOldname, "")
+		if err != nil {
+			return fmt.Errorf("recording symlink %s: %w", newname, err)
+		}
+	}
+
 	// Create symlink with the relative path
 	return os.Symlink(relativeOldname, newname)
 }
@@ -13,14 +13,6 @@ import (
 	"golang.org/x/term"
 )
 
-// FmtSemver forces a string formatted as a semver version with "v" prefix.
-func FmtSemver(s string) string {
-	if s == "" {
-		return ""
-	}
-	return "v" + strings.TrimPrefix(s, "v")
-}
-
 // TerminalWidth returns the width of the terminal, using fallback if it can't determine width.
 func TerminalWidth(fallback int) int {
 	w := termenv.DefaultOutput().Writer()